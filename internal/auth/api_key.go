@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+)
+
+// API keys look like gm_<prefix>_<secret>. The prefix is stored in plain
+// text to find the key, the whole key is stored only as a SHA-256 hash:
+// keys are random, so a slow password hash would add nothing.
+const (
+	apiKeyScheme       = "gm"
+	apiKeyPrefixLength = 8
+	apiKeySecretLength = 32
+)
+
+var apiKeyEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+func GenerateAPIKey() (key string, prefix string, err error) {
+	prefix, err = randomAPIKeyPart(apiKeyPrefixLength)
+	if err != nil {
+		return "", "", err
+	}
+
+	secret, err := randomAPIKeyPart(apiKeySecretLength)
+	if err != nil {
+		return "", "", err
+	}
+
+	return apiKeyScheme + "_" + prefix + "_" + secret, prefix, nil
+}
+
+func ParseAPIKeyPrefix(key string) (string, bool) {
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != apiKeyScheme || len(parts[1]) != apiKeyPrefixLength || len(parts[2]) != apiKeySecretLength {
+		return "", false
+	}
+
+	return parts[1], true
+}
+
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomAPIKeyPart(length int) (string, error) {
+	b := make([]byte, length*5/8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return apiKeyEncoding.EncodeToString(b)[:length], nil
+}
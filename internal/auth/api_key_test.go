@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyPrefix(t *testing.T) {
+	key, prefix, err := GenerateAPIKey()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		key        string
+		wantPrefix string
+		wantOK     bool
+	}{
+		{"generated key", key, prefix, true},
+		{"wrong scheme", "sk_" + key[3:], "", false},
+		{"truncated secret", key[:len(key)-1], "", false},
+		{"bearer token", "eyJhbGciOiJIUzI1NiJ9.e30.sig", "", false},
+		{"empty", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotPrefix, ok := ParseAPIKeyPrefix(tt.key)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantPrefix, gotPrefix)
+		})
+	}
+}
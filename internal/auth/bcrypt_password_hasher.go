@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcrypt keeps its native modular crypt format ($2a$<cost>$<salt+hash>),
+// which is already PHC-compatible and matches the hashes stored so far.
+var bcryptHashIDs = []string{"2a", "2b", "2y"}
+
+// BcryptMaxPasswordBytes is the longest password bcrypt hashes, longer ones are
+// refused by Hash and silently truncated by Verify.
+const BcryptMaxPasswordBytes = 72
+
+type BcryptPasswordHasher struct {
+	cost int
+}
+
+func NewBcryptPasswordHasher(cost int) (*BcryptPasswordHasher, error) {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, bcrypt.InvalidCostError(cost)
+	}
+
+	return &BcryptPasswordHasher{
+		cost: cost,
+	}, nil
+}
+
+func (h *BcryptPasswordHasher) Hash(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	return string(bytes), err
+}
+
+func (h *BcryptPasswordHasher) Verify(password string, encodedHash string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (h *BcryptPasswordHasher) NeedsRehash(encodedHash string) bool {
+	cost, err := bcrypt.Cost([]byte(encodedHash))
+	if err != nil {
+		return true
+	}
+
+	return cost != h.cost
+}
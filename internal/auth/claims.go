@@ -1,8 +1,41 @@
 package auth
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+)
+
+const (
+	tokenTypeAccess       = ""
+	tokenTypeMFAChallenge = "mfa_challenge"
+	tokenTypeOIDCLogin    = "oidc_login"
+)
 
 type Claims struct {
 	jwt.RegisteredClaims
-	UserID int
+	UserID    int
+	SessionID int         `json:",omitempty"`
+	Role      models.Role `json:",omitempty"`
+	TokenType string      `json:",omitempty"`
+}
+
+// GetRole returns the role the token was issued for. Tokens issued
+// before roles were introduced carry none and belong to regular users.
+func (c *Claims) GetRole() models.Role {
+	if c.Role == "" {
+		return models.RoleUser
+	}
+
+	return c.Role
+}
+
+// OIDCLoginClaims hold a pending OpenID Connect login. The token travels in a
+// cookie, so the callback only completes in the browser that started the flow.
+type OIDCLoginClaims struct {
+	jwt.RegisteredClaims
+	State        string
+	Nonce        string
+	CodeVerifier string
+	LinkUserID   int    `json:",omitempty"`
+	TokenType    string `json:",omitempty"`
 }
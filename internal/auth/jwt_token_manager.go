@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/rovany706/loyalty-gopher/internal/models"
 )
 
 type JWTTokenManager struct {
@@ -37,13 +38,47 @@ func generateSecretKey() ([]byte, error) {
 	return b, nil
 }
 
-func (auth *JWTTokenManager) CreateToken(userID int) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(tokenExpiryTime)),
-		},
-		UserID: userID,
-	})
+func (auth *JWTTokenManager) CreateToken(userID int, role models.Role, sessionID int) (string, error) {
+	return auth.createToken(Claims{
+		UserID:    userID,
+		SessionID: sessionID,
+		Role:      role,
+		TokenType: tokenTypeAccess,
+	}, TokenExpiryTime)
+}
+
+// CreateMFAChallengeToken issues a short-lived token proving that the password
+// was checked. It is only accepted by GetClaimsFromMFAChallengeToken.
+func (auth *JWTTokenManager) CreateMFAChallengeToken(userID int) (string, error) {
+	return auth.createToken(Claims{
+		UserID:    userID,
+		TokenType: tokenTypeMFAChallenge,
+	}, MFAChallengeExpiryTime)
+}
+
+// CreateOIDCLoginToken signs the state of a login started at the identity provider.
+// It is only accepted by GetClaimsFromOIDCLoginToken.
+func (auth *JWTTokenManager) CreateOIDCLoginToken(claims OIDCLoginClaims) (string, error) {
+	claims.RegisteredClaims = expiringClaims(OIDCLoginExpiryTime)
+	claims.TokenType = tokenTypeOIDCLogin
+
+	return auth.signToken(claims)
+}
+
+func (auth *JWTTokenManager) createToken(claims Claims, expiryTime time.Duration) (string, error) {
+	claims.RegisteredClaims = expiringClaims(expiryTime)
+
+	return auth.signToken(claims)
+}
+
+func expiringClaims(expiryTime time.Duration) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiryTime)),
+	}
+}
+
+func (auth *JWTTokenManager) signToken(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	tokenString, err := token.SignedString(auth.secretKey)
 
@@ -55,7 +90,40 @@ func (auth *JWTTokenManager) CreateToken(userID int) (string, error) {
 }
 
 func (auth *JWTTokenManager) GetClaimsFromToken(tokenString string) (*Claims, error) {
+	return auth.getClaims(tokenString, tokenTypeAccess)
+}
+
+func (auth *JWTTokenManager) GetClaimsFromMFAChallengeToken(tokenString string) (*Claims, error) {
+	return auth.getClaims(tokenString, tokenTypeMFAChallenge)
+}
+
+func (auth *JWTTokenManager) GetClaimsFromOIDCLoginToken(tokenString string) (*OIDCLoginClaims, error) {
+	claims := &OIDCLoginClaims{}
+	if err := auth.parseToken(tokenString, claims); err != nil {
+		return nil, err
+	}
+
+	if claims.TokenType != tokenTypeOIDCLogin {
+		return nil, ErrUnexpectedTokenType
+	}
+
+	return claims, nil
+}
+
+func (auth *JWTTokenManager) getClaims(tokenString string, tokenType string) (*Claims, error) {
 	claims := &Claims{}
+	if err := auth.parseToken(tokenString, claims); err != nil {
+		return nil, err
+	}
+
+	if claims.TokenType != tokenType {
+		return nil, ErrUnexpectedTokenType
+	}
+
+	return claims, nil
+}
+
+func (auth *JWTTokenManager) parseToken(tokenString string, claims jwt.Claims) error {
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
@@ -65,12 +133,12 @@ func (auth *JWTTokenManager) GetClaimsFromToken(tokenString string) (*Claims, er
 	})
 
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	if !token.Valid {
-		return nil, fmt.Errorf("token is not valid")
+		return fmt.Errorf("token is not valid")
 	}
 
-	return claims, nil
+	return nil
 }
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWTTokenManagerTokenTypes(t *testing.T) {
+	tm, err := NewJWTTokenManager([]byte("secret"))
+	require.NoError(t, err)
+
+	accessToken, err := tm.CreateToken(42, models.RoleAdmin, 7)
+	require.NoError(t, err)
+
+	challengeToken, err := tm.CreateMFAChallengeToken(42)
+	require.NoError(t, err)
+
+	claims, err := tm.GetClaimsFromToken(accessToken)
+	require.NoError(t, err)
+	assert.Equal(t, 42, claims.UserID)
+	assert.Equal(t, models.RoleAdmin, claims.GetRole())
+	assert.Equal(t, 7, claims.SessionID)
+
+	claims, err = tm.GetClaimsFromMFAChallengeToken(challengeToken)
+	require.NoError(t, err)
+	assert.Equal(t, 42, claims.UserID)
+
+	_, err = tm.GetClaimsFromToken(challengeToken)
+	assert.ErrorIs(t, err, ErrUnexpectedTokenType)
+
+	_, err = tm.GetClaimsFromMFAChallengeToken(accessToken)
+	assert.ErrorIs(t, err, ErrUnexpectedTokenType)
+}
+
+func TestJWTTokenManagerOIDCLoginToken(t *testing.T) {
+	tm, err := NewJWTTokenManager([]byte("secret"))
+	require.NoError(t, err)
+
+	loginToken, err := tm.CreateOIDCLoginToken(OIDCLoginClaims{State: "state", Nonce: "nonce", CodeVerifier: "verifier", LinkUserID: 42})
+	require.NoError(t, err)
+
+	claims, err := tm.GetClaimsFromOIDCLoginToken(loginToken)
+	require.NoError(t, err)
+	assert.Equal(t, "state", claims.State)
+	assert.Equal(t, "nonce", claims.Nonce)
+	assert.Equal(t, "verifier", claims.CodeVerifier)
+	assert.Equal(t, 42, claims.LinkUserID)
+
+	_, err = tm.GetClaimsFromToken(loginToken)
+	assert.ErrorIs(t, err, ErrUnexpectedTokenType)
+
+	accessToken, err := tm.CreateToken(42, models.RoleUser, 7)
+	require.NoError(t, err)
+
+	_, err = tm.GetClaimsFromOIDCLoginToken(accessToken)
+	assert.ErrorIs(t, err, ErrUnexpectedTokenType)
+}
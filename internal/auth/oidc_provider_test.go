@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	stubClientID = "gophermart"
+	stubKeyID    = "stub-key"
+	stubCode     = "auth-code"
+)
+
+// stubIdP is a minimal OpenID provider: discovery, JWKS and a token endpoint
+// that checks the PKCE verifier and returns idToken.
+type stubIdP struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	idToken   func(issuer string) string
+}
+
+func newStubIdP(t *testing.T) *stubIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	idp := &stubIdP{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": stubKeyID,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != stubCode || PKCEChallenge(r.FormValue("code_verifier")) != idp.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": idp.idToken(idp.server.URL)})
+	})
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+func (idp *stubIdP) sign(t *testing.T, keyID string, claims IDTokenClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+
+	signed, err := token.SignedString(idp.key)
+	require.NoError(t, err)
+
+	return signed
+}
+
+func TestOIDCProviderExchange(t *testing.T) {
+	idp := newStubIdP(t)
+	provider := NewOIDCProvider(OIDCConfig{
+		Issuer:      idp.server.URL,
+		ClientID:    stubClientID,
+		RedirectURL: "http://localhost/api/user/oidc/callback",
+	}, idp.server.Client())
+
+	validClaims := func(issuer string) IDTokenClaims {
+		return IDTokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    issuer,
+				Subject:   "employee-1",
+				Audience:  jwt.ClaimStrings{stubClientID},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+			Nonce:             "nonce",
+			PreferredUsername: "alice",
+		}
+	}
+
+	tests := []struct {
+		name    string
+		idToken func(issuer string) string
+		wantErr error
+	}{
+		{
+			"valid token",
+			func(issuer string) string { return idp.sign(t, stubKeyID, validClaims(issuer)) },
+			nil,
+		},
+		{
+			"wrong audience",
+			func(issuer string) string {
+				claims := validClaims(issuer)
+				claims.Audience = jwt.ClaimStrings{"another-client"}
+				return idp.sign(t, stubKeyID, claims)
+			},
+			ErrInvalidIDToken,
+		},
+		{
+			"wrong nonce",
+			func(issuer string) string {
+				claims := validClaims(issuer)
+				claims.Nonce = "replayed"
+				return idp.sign(t, stubKeyID, claims)
+			},
+			ErrInvalidIDToken,
+		},
+		{
+			"expired token",
+			func(issuer string) string {
+				claims := validClaims(issuer)
+				claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+				return idp.sign(t, stubKeyID, claims)
+			},
+			ErrInvalidIDToken,
+		},
+		{
+			"unknown key",
+			func(issuer string) string { return idp.sign(t, "rotated-key", validClaims(issuer)) },
+			ErrInvalidIDToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier, err := GeneratePKCEVerifier()
+			require.NoError(t, err)
+
+			authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", verifier)
+			require.NoError(t, err)
+
+			parsed, err := url.Parse(authURL)
+			require.NoError(t, err)
+			assert.Equal(t, "S256", parsed.Query().Get("code_challenge_method"))
+			idp.challenge = parsed.Query().Get("code_challenge")
+			idp.idToken = tt.idToken
+
+			claims, err := provider.Exchange(context.Background(), stubCode, verifier, "nonce")
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "employee-1", claims.Subject)
+			assert.Equal(t, "alice", claims.PreferredUsername)
+		})
+	}
+}
+
+func TestOIDCProviderExchangeWrongVerifier(t *testing.T) {
+	idp := newStubIdP(t)
+	provider := NewOIDCProvider(OIDCConfig{Issuer: idp.server.URL, ClientID: stubClientID}, idp.server.Client())
+
+	idp.challenge = PKCEChallenge("expected-verifier")
+
+	_, err := provider.Exchange(context.Background(), stubCode, "another-verifier", "nonce")
+	assert.ErrorIs(t, err, ErrOIDCExchange)
+}
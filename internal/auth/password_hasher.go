@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	HashAlgorithmArgon2id = "argon2id"
+	HashAlgorithmBcrypt   = "bcrypt"
+)
+
+var (
+	ErrUnsupportedHash         = errors.New("unsupported password hash algorithm")
+	ErrInvalidHashEncoding     = errors.New("invalid password hash encoding")
+	ErrIncompatibleHashVersion = errors.New("incompatible password hash version")
+)
+
+// PasswordHasher hashes passwords into PHC-style encoded strings
+// ($<id>$<params>$<salt>$<hash>) and verifies passwords against them.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password string, encodedHash string) (bool, error)
+	// NeedsRehash reports whether encodedHash was produced with
+	// an algorithm or parameters other than the current ones.
+	NeedsRehash(encodedHash string) bool
+}
+
+type PasswordHasherConfig struct {
+	Algorithm         string
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+	BcryptCost        int
+}
+
+// MultiPasswordHasher hashes new passwords with the preferred algorithm and
+// verifies hashes produced by any of the known algorithms.
+type MultiPasswordHasher struct {
+	preferred PasswordHasher
+	hashers   map[string]PasswordHasher
+}
+
+func NewPasswordHasher(config PasswordHasherConfig) (*MultiPasswordHasher, error) {
+	argon2Hasher := NewArgon2PasswordHasher(config.Argon2Memory, config.Argon2Iterations, config.Argon2Parallelism)
+	bcryptHasher, err := NewBcryptPasswordHasher(config.BcryptCost)
+	if err != nil {
+		return nil, err
+	}
+
+	hashers := map[string]PasswordHasher{
+		HashAlgorithmArgon2id: argon2Hasher,
+	}
+	for _, id := range bcryptHashIDs {
+		hashers[id] = bcryptHasher
+	}
+
+	var preferred PasswordHasher
+	switch config.Algorithm {
+	case HashAlgorithmArgon2id:
+		preferred = argon2Hasher
+	case HashAlgorithmBcrypt:
+		preferred = bcryptHasher
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedHash, config.Algorithm)
+	}
+
+	return &MultiPasswordHasher{
+		preferred: preferred,
+		hashers:   hashers,
+	}, nil
+}
+
+func (h *MultiPasswordHasher) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+func (h *MultiPasswordHasher) Verify(password string, encodedHash string) (bool, error) {
+	hasher, ok := h.hashers[hashID(encodedHash)]
+	if !ok {
+		return false, ErrUnsupportedHash
+	}
+
+	return hasher.Verify(password, encodedHash)
+}
+
+func (h *MultiPasswordHasher) NeedsRehash(encodedHash string) bool {
+	hasher, ok := h.hashers[hashID(encodedHash)]
+	if !ok || hasher != h.preferred {
+		return true
+	}
+
+	return h.preferred.NeedsRehash(encodedHash)
+}
+
+func hashID(encodedHash string) string {
+	parts := strings.SplitN(encodedHash, "$", 3)
+	if len(parts) < 3 || parts[0] != "" {
+		return ""
+	}
+
+	return parts[1]
+}
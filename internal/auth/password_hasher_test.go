@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPasswordHasher(t *testing.T, algorithm string, argon2Iterations uint32, bcryptCost int) *MultiPasswordHasher {
+	hasher, err := NewPasswordHasher(PasswordHasherConfig{
+		Algorithm:         algorithm,
+		Argon2Memory:      64,
+		Argon2Iterations:  argon2Iterations,
+		Argon2Parallelism: 1,
+		BcryptCost:        bcryptCost,
+	})
+	require.NoError(t, err)
+
+	return hasher
+}
+
+func TestPasswordHasherVerify(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		prefix    string
+	}{
+		{
+			name:      "argon2id",
+			algorithm: HashAlgorithmArgon2id,
+			prefix:    "$argon2id$v=19$m=64,t=1,p=1$",
+		},
+		{
+			name:      "bcrypt",
+			algorithm: HashAlgorithmBcrypt,
+			prefix:    "$2a$04$",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasher := newTestPasswordHasher(t, tt.algorithm, 1, 4)
+
+			hash, err := hasher.Hash("dont-panic")
+			require.NoError(t, err)
+			assert.Contains(t, hash, tt.prefix)
+
+			ok, err := hasher.Verify("dont-panic", hash)
+			require.NoError(t, err)
+			assert.True(t, ok)
+
+			ok, err = hasher.Verify("panic", hash)
+			require.NoError(t, err)
+			assert.False(t, ok)
+
+			assert.False(t, hasher.NeedsRehash(hash))
+		})
+	}
+}
+
+func TestPasswordHasherNeedsRehash(t *testing.T) {
+	oldArgon2 := newTestPasswordHasher(t, HashAlgorithmArgon2id, 1, 4)
+	oldBcrypt := newTestPasswordHasher(t, HashAlgorithmBcrypt, 1, 4)
+	current := newTestPasswordHasher(t, HashAlgorithmArgon2id, 2, 5)
+
+	argon2Hash, err := oldArgon2.Hash("dont-panic")
+	require.NoError(t, err)
+	bcryptHash, err := oldBcrypt.Hash("dont-panic")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{
+			name: "outdated parameters",
+			hash: argon2Hash,
+		},
+		{
+			name: "outdated algorithm",
+			hash: bcryptHash,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := current.Verify("dont-panic", tt.hash)
+			require.NoError(t, err)
+			assert.True(t, ok)
+			assert.True(t, current.NeedsRehash(tt.hash))
+		})
+	}
+}
+
+func TestPasswordHasherErr(t *testing.T) {
+	hasher := newTestPasswordHasher(t, HashAlgorithmArgon2id, 1, 4)
+
+	tests := []struct {
+		name    string
+		hash    string
+		wantErr error
+	}{
+		{
+			name:    "unknown algorithm",
+			hash:    "$scrypt$ln=16,r=8,p=1$c2FsdA$aGFzaA",
+			wantErr: ErrUnsupportedHash,
+		},
+		{
+			name:    "not encoded",
+			hash:    "plaintext",
+			wantErr: ErrUnsupportedHash,
+		},
+		{
+			name:    "malformed argon2id",
+			hash:    "$argon2id$v=19$m=64,t=1$c2FsdA$aGFzaA",
+			wantErr: ErrInvalidHashEncoding,
+		},
+		{
+			name:    "unknown argon2 version",
+			hash:    "$argon2id$v=16$m=64,t=1,p=1$c2FsdA$aGFzaA",
+			wantErr: ErrIncompatibleHashVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := hasher.Verify("dont-panic", tt.hash)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.True(t, hasher.NeedsRehash(tt.hash))
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package auth
+
+// GenerateRedemptionCode returns a one-time code a customer gives a merchant to
+// confirm a redemption. It has the format and entropy of a recovery code.
+func GenerateRedemptionCode() (string, error) {
+	codes, err := GenerateRecoveryCodes(1)
+	if err != nil {
+		return "", err
+	}
+
+	return codes[0], nil
+}
+
+func HashRedemptionCode(code string) string {
+	return HashRecoveryCode(code)
+}
@@ -1,10 +1,25 @@
 package auth
 
-import "time"
+import (
+	"errors"
+	"time"
 
-const tokenExpiryTime = time.Hour
+	"github.com/rovany706/loyalty-gopher/internal/models"
+)
+
+const (
+	TokenExpiryTime        = time.Hour
+	MFAChallengeExpiryTime = 5 * time.Minute
+	OIDCLoginExpiryTime    = 10 * time.Minute
+)
+
+var ErrUnexpectedTokenType = errors.New("unexpected token type")
 
 type TokenManager interface {
 	GetClaimsFromToken(tokenString string) (*Claims, error)
-	CreateToken(userID int) (string, error)
+	CreateToken(userID int, role models.Role, sessionID int) (string, error)
+	CreateMFAChallengeToken(userID int) (string, error)
+	GetClaimsFromMFAChallengeToken(tokenString string) (*Claims, error)
+	CreateOIDCLoginToken(claims OIDCLoginClaims) (string, error)
+	GetClaimsFromOIDCLoginToken(tokenString string) (*OIDCLoginClaims, error)
 }
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// base32 of the RFC 6238 SHA1 test seed "12345678901234567890"
+const rfcTestSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		unixTime int64
+		want     string
+	}{
+		{"59", 59, "287082"},
+		{"1111111109", 1111111109, "081804"},
+		{"1111111111", 1111111111, "050471"},
+		{"1234567890", 1234567890, "005924"},
+		{"2000000000", 2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := TOTPCode(rfcTestSecret, TOTPStep(time.Unix(tt.unixTime, 0)))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, code)
+		})
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	currentStep := TOTPStep(now)
+
+	tests := []struct {
+		name     string
+		codeStep int64
+		wantOK   bool
+	}{
+		{"current step", currentStep, true},
+		{"previous step", currentStep - 1, true},
+		{"next step", currentStep + 1, true},
+		{"too old", currentStep - 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := TOTPCode(rfcTestSecret, tt.codeStep)
+			require.NoError(t, err)
+
+			step, ok := ValidateTOTP(rfcTestSecret, code, now)
+			assert.Equal(t, tt.wantOK, ok)
+			if tt.wantOK {
+				assert.Equal(t, tt.codeStep, step)
+			}
+		})
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI("Gophermart", "gopher@example.com", rfcTestSecret)
+
+	parsed, err := url.Parse(uri)
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", parsed.Scheme)
+	assert.Equal(t, "totp", parsed.Host)
+	assert.Equal(t, "/Gophermart:gopher@example.com", parsed.Path)
+	assert.Equal(t, rfcTestSecret, parsed.Query().Get("secret"))
+	assert.Equal(t, "Gophermart", parsed.Query().Get("issuer"))
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(RecoveryCodeCount)
+	require.NoError(t, err)
+	require.Len(t, codes, RecoveryCodeCount)
+
+	for _, code := range codes {
+		assert.Regexp(t, `^[A-Z2-9]{5}-[A-Z2-9]{5}$`, code)
+	}
+
+	assert.Equal(t, HashRecoveryCode("ABCDE-FGHJK"), HashRecoveryCode(" abcdefghjk "))
+}
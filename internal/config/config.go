@@ -5,11 +5,17 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"math"
 	"net"
 	"net/url"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v11"
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type Config struct {
@@ -18,6 +24,71 @@ type Config struct {
 	AccrualAddress string `env:"ACCRUAL_SYSTEM_ADDRESS"`
 	LogLevel       string `env:"LOG_LEVEL"`
 	TokenSecret    string `env:"TOKEN_SECRET"`
+
+	PasswordHashAlgorithm string `env:"PASSWORD_HASH_ALGORITHM"`
+	Argon2Memory          uint   `env:"ARGON2_MEMORY"`
+	Argon2Iterations      uint   `env:"ARGON2_ITERATIONS"`
+	Argon2Parallelism     uint   `env:"ARGON2_PARALLELISM"`
+	BcryptCost            int    `env:"BCRYPT_COST"`
+
+	LoginMinLength        int    `env:"LOGIN_MIN_LENGTH"`
+	LoginMaxLength        int    `env:"LOGIN_MAX_LENGTH"`
+	LoginPattern          string `env:"LOGIN_PATTERN"`
+	PasswordMinLength     int    `env:"PASSWORD_MIN_LENGTH"`
+	PasswordMaxLength     int    `env:"PASSWORD_MAX_LENGTH"`
+	PasswordMinClasses    int    `env:"PASSWORD_MIN_CLASSES"`
+	RejectCommonPasswords bool   `env:"REJECT_COMMON_PASSWORDS"`
+
+	MFAIssuer            string          `env:"MFA_ISSUER"`
+	MFAWithdrawThreshold decimal.Decimal `env:"MFA_WITHDRAW_THRESHOLD"`
+
+	APIKeyDefaultRateLimit int `env:"API_KEY_DEFAULT_RATE_LIMIT"`
+
+	CookieAuth     bool   `env:"COOKIE_AUTH"`
+	CookieSecure   bool   `env:"COOKIE_SECURE"`
+	CookieSameSite string `env:"COOKIE_SAME_SITE"`
+
+	OIDCIssuer        string `env:"OIDC_ISSUER"`
+	OIDCClientID      string `env:"OIDC_CLIENT_ID"`
+	OIDCClientSecret  string `env:"OIDC_CLIENT_SECRET"`
+	OIDCRedirectURL   string `env:"OIDC_REDIRECT_URL"`
+	OIDCAutoProvision bool   `env:"OIDC_AUTO_PROVISION"`
+
+	AccountDeletionGracePeriod time.Duration `env:"ACCOUNT_DELETION_GRACE_PERIOD"`
+
+	ValidateRequests bool `env:"VALIDATE_REQUESTS"`
+
+	OrderBatchMaxSize int `env:"ORDER_BATCH_MAX_SIZE"`
+
+	BalanceStreamMaxConnections int `env:"BALANCE_STREAM_MAX_CONNECTIONS"`
+
+	GRPCAddress string `env:"GRPC_ADDRESS"`
+
+	GraphQLMaxDepth      int `env:"GRAPHQL_MAX_DEPTH"`
+	GraphQLMaxComplexity int `env:"GRAPHQL_MAX_COMPLEXITY"`
+
+	RateLimitStore  string `env:"RATE_LIMIT_STORE"`
+	RateLimit       int    `env:"RATE_LIMIT"`
+	OrdersRateLimit int    `env:"ORDERS_RATE_LIMIT"`
+	AuthRateLimit   int    `env:"AUTH_RATE_LIMIT"`
+
+	// TrustedProxies lists the comma separated IPs and CIDRs whose X-Forwarded-For
+	// is believed. The client IP identifies anonymous callers to the rate limits.
+	TrustedProxies string `env:"TRUSTED_PROXIES"`
+
+	WithdrawalMinSum         decimal.Decimal `env:"WITHDRAWAL_MIN_SUM"`
+	WithdrawalMaxSum         decimal.Decimal `env:"WITHDRAWAL_MAX_SUM"`
+	WithdrawalDailyMaxSum    decimal.Decimal `env:"WITHDRAWAL_DAILY_MAX_SUM"`
+	WithdrawalHourlyMaxCount int             `env:"WITHDRAWAL_HOURLY_MAX_COUNT"`
+	WithdrawalMaxDecimals    int             `env:"WITHDRAWAL_MAX_DECIMALS"`
+
+	FraudWindow         time.Duration `env:"FRAUD_WINDOW"`
+	FraudUploadVelocity int           `env:"FRAUD_UPLOAD_VELOCITY"`
+	FraudFlagScore      int           `env:"FRAUD_FLAG_SCORE"`
+	FraudThrottleScore  int           `env:"FRAUD_THROTTLE_SCORE"`
+
+	TransferDailyMaxSum   decimal.Decimal `env:"TRANSFER_DAILY_MAX_SUM"`
+	TransferDailyMaxCount int             `env:"TRANSFER_DAILY_MAX_COUNT"`
 }
 
 const (
@@ -26,6 +97,66 @@ const (
 	defaultAccrualAddress = "http://localhost:8080"
 	defaultLogLevel       = "info"
 	defaultTokenSecret    = "secret"
+
+	defaultPasswordHashAlgorithm = "argon2id"
+	defaultArgon2Memory          = 19 * 1024
+	defaultArgon2Iterations      = 2
+	defaultArgon2Parallelism     = 1
+	defaultBcryptCost            = 10
+
+	defaultLoginMinLength        = 3
+	defaultLoginMaxLength        = 64
+	defaultLoginPattern          = `^[\p{L}\p{N}._@+-]+$`
+	defaultPasswordMinLength     = 8
+	defaultPasswordMaxLength     = 72
+	defaultPasswordMinClasses    = 2
+	defaultRejectCommonPasswords = true
+
+	defaultMFAIssuer = "Gophermart"
+
+	defaultAPIKeyDefaultRateLimit = 60
+
+	defaultCookieAuth     = false
+	defaultCookieSecure   = true
+	defaultCookieSameSite = "strict"
+
+	defaultOIDCAutoProvision = true
+
+	defaultAccountDeletionGracePeriod = 30 * 24 * time.Hour
+
+	defaultValidateRequests = false
+
+	defaultOrderBatchMaxSize = 100
+
+	defaultBalanceStreamMaxConnections = 3
+
+	defaultGraphQLMaxDepth      = 6
+	defaultGraphQLMaxComplexity = 1000
+
+	defaultRateLimitStore  = "memory"
+	defaultRateLimit       = 300
+	defaultOrdersRateLimit = 30
+	defaultAuthRateLimit   = 10
+
+	defaultWithdrawalHourlyMaxCount = 0
+	defaultWithdrawalMaxDecimals    = 2
+
+	defaultFraudWindow         = time.Hour
+	defaultFraudUploadVelocity = 60
+	defaultFraudFlagScore      = 40
+	defaultFraudThrottleScore  = 70
+
+	defaultTransferDailyMaxCount = 10
+)
+
+var defaultMFAWithdrawThreshold = decimal.NewFromInt(1000)
+
+var defaultTransferDailyMaxSum = decimal.NewFromInt(1000)
+
+var (
+	defaultWithdrawalMinSum      = decimal.Zero
+	defaultWithdrawalMaxSum      = decimal.Zero
+	defaultWithdrawalDailyMaxSum = decimal.Zero
 )
 
 var (
@@ -33,6 +164,24 @@ var (
 	ErrInvalidDatabaseURI    = errors.New("invalid database URI")
 	ErrInvalidAccrualAddress = errors.New("invalid accrual address")
 	ErrInvalidLogLevel       = errors.New("invalid log level")
+	ErrInvalidHashAlgorithm  = errors.New("invalid password hash algorithm")
+	ErrInvalidHashParams     = errors.New("invalid password hash parameters")
+	ErrInvalidLoginPolicy    = errors.New("invalid login policy")
+	ErrInvalidPasswordPolicy = errors.New("invalid password policy")
+	ErrInvalidMFAThreshold   = errors.New("invalid 2FA withdraw threshold")
+	ErrInvalidAPIKeyLimit    = errors.New("invalid API key rate limit")
+	ErrInvalidCookieSettings = errors.New("invalid auth cookie settings")
+	ErrInvalidOIDCSettings   = errors.New("invalid OIDC provider settings")
+	ErrInvalidGracePeriod    = errors.New("invalid account deletion grace period")
+	ErrInvalidOrderBatchSize = errors.New("invalid order batch size")
+	ErrInvalidConnLimit      = errors.New("invalid balance stream connection limit")
+	ErrInvalidRateLimit      = errors.New("invalid rate limit settings")
+	ErrInvalidGRPCAddress    = errors.New("invalid gRPC address")
+	ErrInvalidGraphQLLimits  = errors.New("invalid GraphQL query limits")
+	ErrInvalidWithdrawLimits = errors.New("invalid withdrawal limits")
+	ErrInvalidFraudSettings  = errors.New("invalid fraud scoring settings")
+	ErrInvalidTransferLimits = errors.New("invalid transfer limits")
+	ErrInvalidTrustedProxies = errors.New("invalid trusted proxies")
 )
 
 type Option func(config *Config)
@@ -67,6 +216,152 @@ func WithTokenSecret(tokenSecret string) Option {
 	}
 }
 
+func WithPasswordHashAlgorithm(algorithm string) Option {
+	return func(config *Config) {
+		config.PasswordHashAlgorithm = algorithm
+	}
+}
+
+func WithArgon2Params(memory uint, iterations uint, parallelism uint) Option {
+	return func(config *Config) {
+		config.Argon2Memory = memory
+		config.Argon2Iterations = iterations
+		config.Argon2Parallelism = parallelism
+	}
+}
+
+func WithBcryptCost(cost int) Option {
+	return func(config *Config) {
+		config.BcryptCost = cost
+	}
+}
+
+func WithLoginPolicy(minLength int, maxLength int, pattern string) Option {
+	return func(config *Config) {
+		config.LoginMinLength = minLength
+		config.LoginMaxLength = maxLength
+		config.LoginPattern = pattern
+	}
+}
+
+func WithPasswordPolicy(minLength int, maxLength int, minClasses int, rejectCommon bool) Option {
+	return func(config *Config) {
+		config.PasswordMinLength = minLength
+		config.PasswordMaxLength = maxLength
+		config.PasswordMinClasses = minClasses
+		config.RejectCommonPasswords = rejectCommon
+	}
+}
+
+func WithMFA(issuer string, withdrawThreshold decimal.Decimal) Option {
+	return func(config *Config) {
+		config.MFAIssuer = issuer
+		config.MFAWithdrawThreshold = withdrawThreshold
+	}
+}
+
+func WithAPIKeyDefaultRateLimit(perMinute int) Option {
+	return func(config *Config) {
+		config.APIKeyDefaultRateLimit = perMinute
+	}
+}
+
+func WithCookieAuth(enabled bool, secure bool, sameSite string) Option {
+	return func(config *Config) {
+		config.CookieAuth = enabled
+		config.CookieSecure = secure
+		config.CookieSameSite = sameSite
+	}
+}
+
+func WithOIDC(issuer string, clientID string, clientSecret string, redirectURL string, autoProvision bool) Option {
+	return func(config *Config) {
+		config.OIDCIssuer = issuer
+		config.OIDCClientID = clientID
+		config.OIDCClientSecret = clientSecret
+		config.OIDCRedirectURL = redirectURL
+		config.OIDCAutoProvision = autoProvision
+	}
+}
+
+func WithAccountDeletionGracePeriod(gracePeriod time.Duration) Option {
+	return func(config *Config) {
+		config.AccountDeletionGracePeriod = gracePeriod
+	}
+}
+
+func WithValidateRequests(enabled bool) Option {
+	return func(config *Config) {
+		config.ValidateRequests = enabled
+	}
+}
+
+func WithOrderBatchMaxSize(maxSize int) Option {
+	return func(config *Config) {
+		config.OrderBatchMaxSize = maxSize
+	}
+}
+
+func WithBalanceStreamMaxConnections(perUser int) Option {
+	return func(config *Config) {
+		config.BalanceStreamMaxConnections = perUser
+	}
+}
+
+func WithGRPCAddress(address string) Option {
+	return func(config *Config) {
+		config.GRPCAddress = address
+	}
+}
+
+func WithGraphQLLimits(maxDepth int, maxComplexity int) Option {
+	return func(config *Config) {
+		config.GraphQLMaxDepth = maxDepth
+		config.GraphQLMaxComplexity = maxComplexity
+	}
+}
+
+func WithRateLimits(store string, perMinute int, ordersPerMinute int, authPerMinute int) Option {
+	return func(config *Config) {
+		config.RateLimitStore = store
+		config.RateLimit = perMinute
+		config.OrdersRateLimit = ordersPerMinute
+		config.AuthRateLimit = authPerMinute
+	}
+}
+
+func WithTrustedProxies(proxies string) Option {
+	return func(config *Config) {
+		config.TrustedProxies = proxies
+	}
+}
+
+func WithWithdrawalLimits(minSum decimal.Decimal, maxSum decimal.Decimal, dailyMaxSum decimal.Decimal, hourlyMaxCount int, maxDecimals int) Option {
+	return func(config *Config) {
+		config.WithdrawalMinSum = minSum
+		config.WithdrawalMaxSum = maxSum
+		config.WithdrawalDailyMaxSum = dailyMaxSum
+		config.WithdrawalHourlyMaxCount = hourlyMaxCount
+		config.WithdrawalMaxDecimals = maxDecimals
+	}
+}
+
+func WithFraudScoring(window time.Duration, uploadVelocity int, flagScore int, throttleScore int) Option {
+	return func(config *Config) {
+		config.FraudWindow = window
+		config.FraudUploadVelocity = uploadVelocity
+		config.FraudFlagScore = flagScore
+		config.FraudThrottleScore = throttleScore
+	}
+}
+
+func WithTransferLimits(dailyMaxSum decimal.Decimal, dailyMaxCount int) Option {
+	return func(config *Config) {
+		config.TransferDailyMaxSum = dailyMaxSum
+		config.TransferDailyMaxCount = dailyMaxCount
+	}
+}
+
 func NewConfig(opts ...Option) *Config {
 	config := &Config{
 		RunAddress:     defaultRunAddress,
@@ -74,6 +369,61 @@ func NewConfig(opts ...Option) *Config {
 		AccrualAddress: defaultAccrualAddress,
 		LogLevel:       defaultLogLevel,
 		TokenSecret:    defaultTokenSecret,
+
+		PasswordHashAlgorithm: defaultPasswordHashAlgorithm,
+		Argon2Memory:          defaultArgon2Memory,
+		Argon2Iterations:      defaultArgon2Iterations,
+		Argon2Parallelism:     defaultArgon2Parallelism,
+		BcryptCost:            defaultBcryptCost,
+
+		LoginMinLength:        defaultLoginMinLength,
+		LoginMaxLength:        defaultLoginMaxLength,
+		LoginPattern:          defaultLoginPattern,
+		PasswordMinLength:     defaultPasswordMinLength,
+		PasswordMaxLength:     defaultPasswordMaxLength,
+		PasswordMinClasses:    defaultPasswordMinClasses,
+		RejectCommonPasswords: defaultRejectCommonPasswords,
+
+		MFAIssuer:            defaultMFAIssuer,
+		MFAWithdrawThreshold: defaultMFAWithdrawThreshold,
+
+		APIKeyDefaultRateLimit: defaultAPIKeyDefaultRateLimit,
+
+		CookieAuth:     defaultCookieAuth,
+		CookieSecure:   defaultCookieSecure,
+		CookieSameSite: defaultCookieSameSite,
+
+		OIDCAutoProvision: defaultOIDCAutoProvision,
+
+		AccountDeletionGracePeriod: defaultAccountDeletionGracePeriod,
+
+		ValidateRequests: defaultValidateRequests,
+
+		OrderBatchMaxSize: defaultOrderBatchMaxSize,
+
+		BalanceStreamMaxConnections: defaultBalanceStreamMaxConnections,
+
+		GraphQLMaxDepth:      defaultGraphQLMaxDepth,
+		GraphQLMaxComplexity: defaultGraphQLMaxComplexity,
+
+		RateLimitStore:  defaultRateLimitStore,
+		RateLimit:       defaultRateLimit,
+		OrdersRateLimit: defaultOrdersRateLimit,
+		AuthRateLimit:   defaultAuthRateLimit,
+
+		WithdrawalMinSum:         defaultWithdrawalMinSum,
+		WithdrawalMaxSum:         defaultWithdrawalMaxSum,
+		WithdrawalDailyMaxSum:    defaultWithdrawalDailyMaxSum,
+		WithdrawalHourlyMaxCount: defaultWithdrawalHourlyMaxCount,
+		WithdrawalMaxDecimals:    defaultWithdrawalMaxDecimals,
+
+		FraudWindow:         defaultFraudWindow,
+		FraudUploadVelocity: defaultFraudUploadVelocity,
+		FraudFlagScore:      defaultFraudFlagScore,
+		FraudThrottleScore:  defaultFraudThrottleScore,
+
+		TransferDailyMaxSum:   defaultTransferDailyMaxSum,
+		TransferDailyMaxCount: defaultTransferDailyMaxCount,
 	}
 
 	for _, opt := range opts {
@@ -92,6 +442,52 @@ func ParseArgs(programName string, args []string) (config *Config, err error) {
 	flags.StringVar(&config.AccrualAddress, "r", defaultAccrualAddress, fmt.Sprintf("address and port of accrual system (default: %s)", defaultAccrualAddress))
 	flags.StringVar(&config.LogLevel, "l", defaultLogLevel, fmt.Sprintf("log level (default: %s)", defaultLogLevel))
 	flags.StringVar(&config.TokenSecret, "t", defaultTokenSecret, fmt.Sprintf("token secret (default: %s)", defaultTokenSecret))
+	flags.StringVar(&config.PasswordHashAlgorithm, "hash-algorithm", defaultPasswordHashAlgorithm, fmt.Sprintf("password hash algorithm, argon2id or bcrypt (default: %s)", defaultPasswordHashAlgorithm))
+	flags.UintVar(&config.Argon2Memory, "argon2-memory", defaultArgon2Memory, fmt.Sprintf("argon2id memory in KiB (default: %d)", defaultArgon2Memory))
+	flags.UintVar(&config.Argon2Iterations, "argon2-iterations", defaultArgon2Iterations, fmt.Sprintf("argon2id iterations (default: %d)", defaultArgon2Iterations))
+	flags.UintVar(&config.Argon2Parallelism, "argon2-parallelism", defaultArgon2Parallelism, fmt.Sprintf("argon2id parallelism (default: %d)", defaultArgon2Parallelism))
+	flags.IntVar(&config.BcryptCost, "bcrypt-cost", defaultBcryptCost, fmt.Sprintf("bcrypt cost (default: %d)", defaultBcryptCost))
+	flags.IntVar(&config.LoginMinLength, "login-min-length", defaultLoginMinLength, fmt.Sprintf("minimum login length (default: %d)", defaultLoginMinLength))
+	flags.IntVar(&config.LoginMaxLength, "login-max-length", defaultLoginMaxLength, fmt.Sprintf("maximum login length (default: %d)", defaultLoginMaxLength))
+	flags.StringVar(&config.LoginPattern, "login-pattern", defaultLoginPattern, fmt.Sprintf("regular expression for allowed logins (default: %s)", defaultLoginPattern))
+	flags.IntVar(&config.PasswordMinLength, "password-min-length", defaultPasswordMinLength, fmt.Sprintf("minimum password length (default: %d)", defaultPasswordMinLength))
+	flags.IntVar(&config.PasswordMaxLength, "password-max-length", defaultPasswordMaxLength, fmt.Sprintf("maximum password length (default: %d)", defaultPasswordMaxLength))
+	flags.IntVar(&config.PasswordMinClasses, "password-min-classes", defaultPasswordMinClasses, fmt.Sprintf("minimum number of character classes in password (default: %d)", defaultPasswordMinClasses))
+	flags.BoolVar(&config.RejectCommonPasswords, "reject-common-passwords", defaultRejectCommonPasswords, fmt.Sprintf("reject passwords from the common passwords list (default: %t)", defaultRejectCommonPasswords))
+	flags.StringVar(&config.MFAIssuer, "mfa-issuer", defaultMFAIssuer, fmt.Sprintf("issuer shown in authenticator apps (default: %s)", defaultMFAIssuer))
+	flags.TextVar(&config.MFAWithdrawThreshold, "mfa-withdraw-threshold", defaultMFAWithdrawThreshold, fmt.Sprintf("withdrawals above this sum require a TOTP code from users with 2FA (default: %s)", defaultMFAWithdrawThreshold))
+	flags.IntVar(&config.APIKeyDefaultRateLimit, "api-key-rate-limit", defaultAPIKeyDefaultRateLimit, fmt.Sprintf("default requests per minute for new API keys (default: %d)", defaultAPIKeyDefaultRateLimit))
+	flags.BoolVar(&config.CookieAuth, "cookie-auth", defaultCookieAuth, fmt.Sprintf("also issue access tokens in an HttpOnly cookie for browser clients (default: %t)", defaultCookieAuth))
+	flags.BoolVar(&config.CookieSecure, "cookie-secure", defaultCookieSecure, fmt.Sprintf("mark auth cookies as Secure (default: %t)", defaultCookieSecure))
+	flags.StringVar(&config.OIDCIssuer, "oidc-issuer", "", "OpenID Connect issuer URL, enables sign in through the provider")
+	flags.StringVar(&config.OIDCClientID, "oidc-client-id", "", "OpenID Connect client id")
+	flags.StringVar(&config.OIDCClientSecret, "oidc-client-secret", "", "OpenID Connect client secret")
+	flags.StringVar(&config.OIDCRedirectURL, "oidc-redirect-url", "", "OpenID Connect redirect URL pointing to /api/user/oidc/callback")
+	flags.BoolVar(&config.OIDCAutoProvision, "oidc-auto-provision", defaultOIDCAutoProvision, fmt.Sprintf("create users on first sign in through the provider (default: %t)", defaultOIDCAutoProvision))
+	flags.DurationVar(&config.AccountDeletionGracePeriod, "account-deletion-grace-period", defaultAccountDeletionGracePeriod, fmt.Sprintf("time before a deleted account is anonymized (default: %s)", defaultAccountDeletionGracePeriod))
+	flags.BoolVar(&config.ValidateRequests, "validate-requests", defaultValidateRequests, fmt.Sprintf("reject requests that do not match the OpenAPI document (default: %t)", defaultValidateRequests))
+	flags.IntVar(&config.OrderBatchMaxSize, "order-batch-max-size", defaultOrderBatchMaxSize, fmt.Sprintf("maximum number of orders in one batch upload (default: %d)", defaultOrderBatchMaxSize))
+	flags.IntVar(&config.BalanceStreamMaxConnections, "balance-stream-max-connections", defaultBalanceStreamMaxConnections, fmt.Sprintf("maximum open balance WebSocket connections per user (default: %d)", defaultBalanceStreamMaxConnections))
+	flags.StringVar(&config.GRPCAddress, "grpc-address", "", "address and port of the gRPC API, disabled when empty")
+	flags.IntVar(&config.GraphQLMaxDepth, "graphql-max-depth", defaultGraphQLMaxDepth, fmt.Sprintf("maximum field nesting depth of a GraphQL query (default: %d)", defaultGraphQLMaxDepth))
+	flags.IntVar(&config.GraphQLMaxComplexity, "graphql-max-complexity", defaultGraphQLMaxComplexity, fmt.Sprintf("maximum complexity of a GraphQL query (default: %d)", defaultGraphQLMaxComplexity))
+	flags.StringVar(&config.RateLimitStore, "rate-limit-store", defaultRateLimitStore, fmt.Sprintf("where rate limit buckets are kept, memory or postgres to share them between instances (default: %s)", defaultRateLimitStore))
+	flags.IntVar(&config.RateLimit, "rate-limit", defaultRateLimit, fmt.Sprintf("requests per minute per user or IP (default: %d)", defaultRateLimit))
+	flags.IntVar(&config.OrdersRateLimit, "orders-rate-limit", defaultOrdersRateLimit, fmt.Sprintf("order list requests per minute per user (default: %d)", defaultOrdersRateLimit))
+	flags.IntVar(&config.AuthRateLimit, "auth-rate-limit", defaultAuthRateLimit, fmt.Sprintf("registration and login requests per minute per IP (default: %d)", defaultAuthRateLimit))
+	flags.StringVar(&config.TrustedProxies, "trusted-proxies", "", "comma separated IPs and CIDRs of reverse proxies allowed to set X-Forwarded-For, none when empty")
+	flags.TextVar(&config.WithdrawalMinSum, "withdrawal-min-sum", defaultWithdrawalMinSum, "smallest sum of one withdrawal, no minimum when 0")
+	flags.TextVar(&config.WithdrawalMaxSum, "withdrawal-max-sum", defaultWithdrawalMaxSum, "largest sum of one withdrawal, no maximum when 0")
+	flags.TextVar(&config.WithdrawalDailyMaxSum, "withdrawal-daily-max-sum", defaultWithdrawalDailyMaxSum, "largest sum withdrawn by a user in 24 hours, no limit when 0")
+	flags.IntVar(&config.WithdrawalHourlyMaxCount, "withdrawal-hourly-max-count", defaultWithdrawalHourlyMaxCount, "most withdrawals by a user in an hour, no limit when 0")
+	flags.IntVar(&config.WithdrawalMaxDecimals, "withdrawal-max-decimals", defaultWithdrawalMaxDecimals, fmt.Sprintf("most decimal places of a withdrawal sum, 0 to 2 (default: %d)", defaultWithdrawalMaxDecimals))
+	flags.DurationVar(&config.FraudWindow, "fraud-window", defaultFraudWindow, fmt.Sprintf("period over which order uploads are scored for fraud (default: %s)", defaultFraudWindow))
+	flags.IntVar(&config.FraudUploadVelocity, "fraud-upload-velocity", defaultFraudUploadVelocity, fmt.Sprintf("order uploads per fraud window that score as full velocity (default: %d)", defaultFraudUploadVelocity))
+	flags.IntVar(&config.FraudFlagScore, "fraud-flag-score", defaultFraudFlagScore, fmt.Sprintf("risk score from 1 to 100 that puts a user in the review queue (default: %d)", defaultFraudFlagScore))
+	flags.IntVar(&config.FraudThrottleScore, "fraud-throttle-score", defaultFraudThrottleScore, fmt.Sprintf("risk score from 1 to 100 that blocks order uploads of a user or IP for the fraud window (default: %d)", defaultFraudThrottleScore))
+	flags.TextVar(&config.TransferDailyMaxSum, "transfer-daily-max-sum", defaultTransferDailyMaxSum, fmt.Sprintf("largest sum a user transfers to others in 24 hours, no limit when 0 (default: %s)", defaultTransferDailyMaxSum))
+	flags.IntVar(&config.TransferDailyMaxCount, "transfer-daily-max-count", defaultTransferDailyMaxCount, fmt.Sprintf("most transfers a user makes in 24 hours, no limit when 0 (default: %d)", defaultTransferDailyMaxCount))
+	flags.StringVar(&config.CookieSameSite, "cookie-same-site", defaultCookieSameSite, fmt.Sprintf("SameSite mode of auth cookies, strict, lax or none (default: %s)", defaultCookieSameSite))
 
 	if err := flags.Parse(args); err != nil {
 		return nil, err
@@ -101,7 +497,7 @@ func ParseArgs(programName string, args []string) (config *Config, err error) {
 		return nil, err
 	}
 
-	log.Printf("Parsed app config: %+v\n", config)
+	log.Printf("Parsed app config: %s\n", config)
 
 	if err := validateParsedArgs(config); err != nil {
 		return nil, err
@@ -115,6 +511,12 @@ func validateParsedArgs(config *Config) error {
 		return ErrInvalidRunAddress
 	}
 
+	if config.GRPCAddress != "" {
+		if _, err := net.ResolveTCPAddr("tcp", config.GRPCAddress); err != nil || config.GRPCAddress == config.RunAddress {
+			return ErrInvalidGRPCAddress
+		}
+	}
+
 	if !isURL(config.AccrualAddress) {
 		return ErrInvalidAccrualAddress
 	}
@@ -127,9 +529,190 @@ func validateParsedArgs(config *Config) error {
 		return ErrInvalidDatabaseURI
 	}
 
+	if config.PasswordHashAlgorithm != "argon2id" && config.PasswordHashAlgorithm != "bcrypt" {
+		return ErrInvalidHashAlgorithm
+	}
+
+	if !isValidHashParams(config) {
+		return ErrInvalidHashParams
+	}
+
+	if !isValidLoginPolicy(config) {
+		return ErrInvalidLoginPolicy
+	}
+
+	if config.PasswordMinLength < 1 || config.PasswordMaxLength < config.PasswordMinLength ||
+		config.PasswordMinClasses < 0 || config.PasswordMinClasses > 4 {
+		return ErrInvalidPasswordPolicy
+	}
+
+	if config.MFAWithdrawThreshold.IsNegative() {
+		return ErrInvalidMFAThreshold
+	}
+
+	if config.APIKeyDefaultRateLimit < 1 {
+		return ErrInvalidAPIKeyLimit
+	}
+
+	if !isValidCookieSettings(config) {
+		return ErrInvalidCookieSettings
+	}
+
+	if config.AccountDeletionGracePeriod < 0 {
+		return ErrInvalidGracePeriod
+	}
+
+	if config.OrderBatchMaxSize < 1 {
+		return ErrInvalidOrderBatchSize
+	}
+
+	if config.BalanceStreamMaxConnections < 1 {
+		return ErrInvalidConnLimit
+	}
+
+	if config.GraphQLMaxDepth < 1 || config.GraphQLMaxComplexity < 1 {
+		return ErrInvalidGraphQLLimits
+	}
+
+	if !isValidRateLimits(config) {
+		return ErrInvalidRateLimit
+	}
+
+	for _, proxy := range config.TrustedProxyList() {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return ErrInvalidTrustedProxies
+		}
+	}
+
+	if !isValidWithdrawalLimits(config) {
+		return ErrInvalidWithdrawLimits
+	}
+
+	if config.FraudWindow <= 0 || config.FraudUploadVelocity < 1 ||
+		config.FraudFlagScore < 1 || config.FraudThrottleScore > 100 || config.FraudFlagScore > config.FraudThrottleScore {
+		return ErrInvalidFraudSettings
+	}
+
+	if config.TransferDailyMaxSum.IsNegative() || config.TransferDailyMaxCount < 0 {
+		return ErrInvalidTransferLimits
+	}
+
+	if config.OIDCIssuer != "" && (!isURL(config.OIDCIssuer) || config.OIDCClientID == "" || !isURL(config.OIDCRedirectURL)) {
+		return ErrInvalidOIDCSettings
+	}
+
 	return nil
 }
 
+func isValidRateLimits(config *Config) bool {
+	if config.RateLimitStore != "memory" && config.RateLimitStore != "postgres" {
+		return false
+	}
+
+	return config.RateLimit >= 1 && config.OrdersRateLimit >= 1 && config.AuthRateLimit >= 1
+}
+
+func isValidWithdrawalLimits(config *Config) bool {
+	if config.WithdrawalMinSum.IsNegative() || config.WithdrawalMaxSum.IsNegative() || config.WithdrawalDailyMaxSum.IsNegative() {
+		return false
+	}
+
+	if config.WithdrawalMaxSum.IsPositive() && config.WithdrawalMinSum.GreaterThan(config.WithdrawalMaxSum) {
+		return false
+	}
+
+	// sums are stored with two decimal places
+	return config.WithdrawalHourlyMaxCount >= 0 && config.WithdrawalMaxDecimals >= 0 && config.WithdrawalMaxDecimals <= 2
+}
+
+func isValidCookieSettings(config *Config) bool {
+	switch config.CookieSameSite {
+	case "strict", "lax":
+		return true
+	case "none":
+		// browsers drop SameSite=None cookies that are not Secure
+		return config.CookieSecure
+	default:
+		return false
+	}
+}
+
+func isValidLoginPolicy(config *Config) bool {
+	if config.LoginMinLength < 1 || config.LoginMaxLength < config.LoginMinLength {
+		return false
+	}
+
+	_, err := regexp.Compile(config.LoginPattern)
+
+	return err == nil
+}
+
+func isValidHashParams(config *Config) bool {
+	return config.Argon2Memory >= 8*config.Argon2Parallelism &&
+		config.Argon2Memory <= math.MaxUint32 &&
+		config.Argon2Iterations >= 1 &&
+		config.Argon2Iterations <= math.MaxUint32 &&
+		config.Argon2Parallelism >= 1 &&
+		config.Argon2Parallelism <= math.MaxUint8 &&
+		config.BcryptCost >= bcrypt.MinCost &&
+		config.BcryptCost <= bcrypt.MaxCost
+}
+
+// TrustedProxyList splits TrustedProxies, it is empty when no proxy is trusted.
+func (config *Config) TrustedProxyList() []string {
+	proxies := make([]string, 0)
+	for _, proxy := range strings.Split(config.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+
+	return proxies
+}
+
+const redacted = "[REDACTED]"
+
+// String masks the secrets, the config ends up in the logs.
+func (config Config) String() string {
+	// the conversion drops the method, %+v would call String again otherwise
+	type plainConfig Config
+
+	config.DatabaseURI = redactDatabaseURI(config.DatabaseURI)
+	if config.TokenSecret != "" {
+		config.TokenSecret = redacted
+	}
+	if config.OIDCClientSecret != "" {
+		config.OIDCClientSecret = redacted
+	}
+
+	return fmt.Sprintf("%+v", plainConfig(config))
+}
+
+// redactDatabaseURI masks the password of a URL DSN, in the user info or the query. A key=value DSN may
+// carry the password anywhere, so it is masked as a whole.
+func redactDatabaseURI(databaseURI string) string {
+	if databaseURI == "" {
+		return ""
+	}
+
+	if !isURL(databaseURI) {
+		return redacted
+	}
+
+	u, err := url.Parse(databaseURI)
+	if err != nil {
+		return redacted
+	}
+
+	query := u.Query()
+	if query.Has("password") {
+		query.Set("password", redacted)
+		u.RawQuery = query.Encode()
+	}
+
+	return u.Redacted()
+}
+
 func isURL(str string) bool {
 	u, err := url.Parse(str)
 	return err == nil && u.Scheme != "" && u.Host != ""
@@ -0,0 +1,36 @@
+-- Sign in normalizes logins like registration does (trimmed, NFKC), so the stored
+-- logins are normalized the same way. Logins that collide afterwards, by case or
+-- exactly, keep the login of one user (an already normalized one, else the oldest)
+-- and get the user ID as a suffix for the others, reported as a warning so that
+-- those users can be told their new login.
+DO $$
+DECLARE
+    u RECORD;
+    new_username TEXT;
+BEGIN
+    FOR u IN
+        SELECT id, username, normalized,
+               ROW_NUMBER() OVER (PARTITION BY LOWER(normalized) ORDER BY username = normalized DESC, id) AS rank
+        FROM (
+            SELECT id, username, normalize(btrim(username, E' \t\n\r\v\f'), NFKC) AS normalized
+            FROM users
+        ) AS U
+        ORDER BY rank, id
+    LOOP
+        new_username := u.normalized;
+        IF u.rank > 1 THEN
+            new_username := u.normalized || '-' || u.id;
+            IF EXISTS (SELECT 1 FROM users WHERE LOWER(username) = LOWER(new_username)) THEN
+                RAISE EXCEPTION 'login "%" of user % collides with another login ignoring case and "%" is taken too, rename the user and run the migrations again', u.username, u.id, new_username;
+            END IF;
+
+            RAISE WARNING 'login "%" of user % collides with another login ignoring case, renamed to "%"', u.username, u.id, new_username;
+        END IF;
+
+        IF new_username <> u.username THEN
+            UPDATE users SET username = new_username WHERE id = u.id;
+        END IF;
+    END LOOP;
+END $$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_username_lower_key ON users (LOWER(username));
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS balance_adjustments;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS disabled_at,
+    DROP COLUMN IF EXISTS role;
+DROP TYPE IF EXISTS e_user_role;
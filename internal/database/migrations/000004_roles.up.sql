@@ -0,0 +1,19 @@
+CREATE TYPE e_user_role AS ENUM (
+    'user',
+    'support',
+    'admin',
+    'partner'
+);
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS role e_user_role NOT NULL DEFAULT 'user',
+    ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS balance_adjustments (
+    id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    point_account_id INT NOT NULL REFERENCES point_accounts(id),
+    amount NUMERIC(12,2) NOT NULL,
+    reason TEXT NOT NULL,
+    admin_user_id INT NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
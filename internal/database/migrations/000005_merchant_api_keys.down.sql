@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS merchants;
+ALTER TABLE users DROP COLUMN IF EXISTS loyalty_card_id;
@@ -0,0 +1,23 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS loyalty_card_id TEXT UNIQUE;
+
+-- cards issued from now on start with 1-8, so legacy numbers never collide with them
+UPDATE users SET loyalty_card_id = '9' || LPAD(id::TEXT, 15, '0') WHERE loyalty_card_id IS NULL;
+ALTER TABLE users ALTER COLUMN loyalty_card_id SET NOT NULL;
+
+CREATE TABLE IF NOT EXISTS merchants (
+    id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    merchant_id INT NOT NULL REFERENCES merchants(id),
+    prefix TEXT UNIQUE NOT NULL,
+    key_hash TEXT NOT NULL,
+    scopes TEXT[] NOT NULL,
+    rate_limit_per_minute INT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
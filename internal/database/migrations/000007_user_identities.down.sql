@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS user_identities;
+
+-- users created through an identity provider have no password
+-- and must not be left with a NULL hash
+UPDATE users SET pw_hash='!' WHERE pw_hash IS NULL;
+ALTER TABLE users ALTER COLUMN pw_hash SET NOT NULL;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS statements;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS processed_at;
@@ -0,0 +1,15 @@
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS processed_at TIMESTAMP WITH TIME ZONE;
+
+-- the time of earlier accruals is unknown, date them by upload
+UPDATE orders SET processed_at = uploaded_at WHERE accrual_status = 'PROCESSED' AND processed_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS statements (
+    id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    user_id INT NOT NULL REFERENCES users(id),
+    period_start DATE NOT NULL,
+    html TEXT NOT NULL,
+    pdf BYTEA NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, period_start)
+);
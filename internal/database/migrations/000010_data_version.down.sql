@@ -0,0 +1,3 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS data_modified_at,
+    DROP COLUMN IF EXISTS data_version;
@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS data_version BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS data_modified_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
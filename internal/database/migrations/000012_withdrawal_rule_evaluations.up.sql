@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS withdrawal_rule_evaluations (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    user_id INT NOT NULL REFERENCES users(id),
+    order_num TEXT NOT NULL,
+    amount NUMERIC(12,2) NOT NULL,
+    rule TEXT NOT NULL,
+    passed BOOLEAN NOT NULL,
+    limit_value TEXT NOT NULL,
+    actual_value TEXT NOT NULL,
+    evaluated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS withdrawal_rule_evaluations_user_id_idx ON withdrawal_rule_evaluations (user_id, evaluated_at);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS fraud_reviews;
+DROP TABLE IF EXISTS fraud_throttles;
+DROP TABLE IF EXISTS order_upload_events;
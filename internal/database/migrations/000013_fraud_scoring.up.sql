@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS order_upload_events (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    user_id INT NOT NULL REFERENCES users(id),
+    client_ip TEXT NOT NULL,
+    outcome TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS order_upload_events_user_id_idx ON order_upload_events (user_id, created_at);
+CREATE INDEX IF NOT EXISTS order_upload_events_client_ip_idx ON order_upload_events (client_ip, created_at);
+
+CREATE TABLE IF NOT EXISTS fraud_throttles (
+    subject TEXT PRIMARY KEY,
+    until TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS fraud_reviews (
+    id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    user_id INT NOT NULL REFERENCES users(id),
+    score INT NOT NULL,
+    uploads INT NOT NULL,
+    conflicts INT NOT NULL,
+    invalid INT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    flagged_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    resolved_by INT REFERENCES users(id)
+);
+
+-- a user has at most one review waiting for an admin
+CREATE UNIQUE INDEX IF NOT EXISTS fraud_reviews_pending_user_id_idx ON fraud_reviews (user_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS fraud_reviews_status_idx ON fraud_reviews (status, flagged_at);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS point_transfers (
+    id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    sender_account_id INT NOT NULL REFERENCES point_accounts(id),
+    recipient_account_id INT NOT NULL REFERENCES point_accounts(id),
+    amount NUMERIC(12,2) NOT NULL CHECK (amount > 0),
+    status TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    CHECK (sender_account_id <> recipient_account_id)
+);
+
+CREATE INDEX IF NOT EXISTS point_transfers_sender_account_id_idx ON point_transfers (sender_account_id, created_at);
+CREATE INDEX IF NOT EXISTS point_transfers_recipient_account_id_idx ON point_transfers (recipient_account_id, created_at);
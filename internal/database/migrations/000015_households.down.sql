@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS withdrawal_history_user_id_idx;
+ALTER TABLE withdrawal_history DROP COLUMN IF EXISTS user_id;
+ALTER TABLE orders DROP COLUMN IF EXISTS point_account_id;
+DROP TABLE IF EXISTS household_members;
+DROP TABLE IF EXISTS households;
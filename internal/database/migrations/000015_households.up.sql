@@ -0,0 +1,34 @@
+-- the account of a household belongs to no single user, so its user_id is NULL
+CREATE TABLE IF NOT EXISTS households (
+    id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    name TEXT NOT NULL,
+    point_account_id INT NOT NULL UNIQUE REFERENCES point_accounts(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- a user belongs, or is invited, to at most one household;
+-- allowance is what a member may still withdraw from the pool, NULL for no limit
+CREATE TABLE IF NOT EXISTS household_members (
+    household_id INT NOT NULL REFERENCES households(id),
+    user_id INT NOT NULL UNIQUE REFERENCES users(id),
+    role TEXT NOT NULL,
+    status TEXT NOT NULL,
+    allowance NUMERIC(12,2) CHECK (allowance >= 0),
+    invited_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    joined_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (household_id, user_id)
+);
+
+-- orders remember the account they were credited to and withdrawals the user
+-- who made them, as neither follows from the account alone any more
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS point_account_id INT REFERENCES point_accounts(id);
+UPDATE orders AS O SET point_account_id = P.id
+FROM point_accounts AS P
+WHERE P.user_id = O.user_id AND O.processed_at IS NOT NULL;
+
+ALTER TABLE withdrawal_history ADD COLUMN IF NOT EXISTS user_id INT REFERENCES users(id);
+UPDATE withdrawal_history AS W SET user_id = P.user_id
+FROM point_accounts AS P
+WHERE P.id = W.point_account_id;
+
+CREATE INDEX IF NOT EXISTS withdrawal_history_user_id_idx ON withdrawal_history (user_id, processed_at);
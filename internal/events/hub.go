@@ -0,0 +1,157 @@
+package events
+
+import (
+	"sync"
+
+	"github.com/rovany706/loyalty-gopher/internal/models"
+)
+
+const (
+	defaultHistorySize = 1024
+	subscriberBuffer   = 16
+)
+
+// OrderEvent is a change of an order's status or accrual. IDs grow
+// monotonically within the process and start over after a restart.
+type OrderEvent struct {
+	ID     uint64
+	UserID int
+	Order  models.Order
+}
+
+type Publisher interface {
+	PublishOrderEvent(userID int, order models.Order)
+	PublishBalanceDelta(userID int, delta models.BalanceDelta)
+}
+
+// Subscription receives the user's events until it is closed. The channel is
+// closed when the subscriber falls too far behind, so that the client
+// reconnects and catches up.
+type Subscription[T any] struct {
+	C      <-chan T
+	ch     chan T
+	userID int
+}
+
+type subscriberSet[T any] map[int]map[*Subscription[T]]struct{}
+
+func (s subscriberSet[T]) add(userID int) *Subscription[T] {
+	ch := make(chan T, subscriberBuffer)
+	sub := &Subscription[T]{C: ch, ch: ch, userID: userID}
+	if s[userID] == nil {
+		s[userID] = make(map[*Subscription[T]]struct{})
+	}
+	s[userID][sub] = struct{}{}
+
+	return sub
+}
+
+func (s subscriberSet[T]) send(userID int, value T) {
+	for sub := range s[userID] {
+		select {
+		case sub.ch <- value:
+		default:
+			s.remove(sub)
+		}
+	}
+}
+
+func (s subscriberSet[T]) remove(sub *Subscription[T]) {
+	userSubscribers, ok := s[sub.userID]
+	if !ok {
+		return
+	}
+
+	if _, ok := userSubscribers[sub]; !ok {
+		return
+	}
+
+	delete(userSubscribers, sub)
+	if len(userSubscribers) == 0 {
+		delete(s, sub.userID)
+	}
+
+	close(sub.ch)
+}
+
+// Hub fans order and balance events out to subscribers in this process and
+// keeps the most recent order events so reconnecting clients can resume
+// from Last-Event-ID.
+type Hub struct {
+	mutex              sync.Mutex
+	lastID             uint64
+	history            []OrderEvent
+	historySize        int
+	orderSubscribers   subscriberSet[OrderEvent]
+	balanceSubscribers subscriberSet[models.BalanceDelta]
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		history:            make([]OrderEvent, 0, defaultHistorySize),
+		historySize:        defaultHistorySize,
+		orderSubscribers:   make(subscriberSet[OrderEvent]),
+		balanceSubscribers: make(subscriberSet[models.BalanceDelta]),
+	}
+}
+
+func (h *Hub) PublishOrderEvent(userID int, order models.Order) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.lastID++
+	event := OrderEvent{ID: h.lastID, UserID: userID, Order: order}
+
+	if len(h.history) == h.historySize {
+		h.history = append(h.history[:0], h.history[1:]...)
+	}
+	h.history = append(h.history, event)
+
+	h.orderSubscribers.send(userID, event)
+}
+
+// Subscribe registers an order event subscriber and returns the user's events
+// published after lastEventID that are still kept. Pass 0 to skip the replay.
+func (h *Hub) Subscribe(userID int, lastEventID uint64) (*Subscription[OrderEvent], []OrderEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	missed := make([]OrderEvent, 0)
+	if lastEventID > 0 {
+		for _, event := range h.history {
+			if event.ID > lastEventID && event.UserID == userID {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	return h.orderSubscribers.add(userID), missed
+}
+
+func (h *Hub) Unsubscribe(sub *Subscription[OrderEvent]) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.orderSubscribers.remove(sub)
+}
+
+func (h *Hub) PublishBalanceDelta(userID int, delta models.BalanceDelta) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.balanceSubscribers.send(userID, delta)
+}
+
+func (h *Hub) SubscribeBalance(userID int) *Subscription[models.BalanceDelta] {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.balanceSubscribers.add(userID)
+}
+
+func (h *Hub) UnsubscribeBalance(sub *Subscription[models.BalanceDelta]) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.balanceSubscribers.remove(sub)
+}
@@ -0,0 +1,58 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub(t *testing.T) {
+	t.Run("delivers only the user's events", func(t *testing.T) {
+		hub := NewHub()
+		sub, missed := hub.Subscribe(1, 0)
+		defer hub.Unsubscribe(sub)
+		assert.Empty(t, missed)
+
+		hub.PublishOrderEvent(2, models.Order{OrderNum: "2377225624"})
+		hub.PublishOrderEvent(1, models.Order{OrderNum: "12345678903"})
+
+		event := <-sub.C
+		assert.Equal(t, uint64(2), event.ID)
+		assert.Equal(t, "12345678903", event.Order.OrderNum)
+		assert.Empty(t, sub.C)
+	})
+
+	t.Run("replays events after Last-Event-ID", func(t *testing.T) {
+		hub := NewHub()
+		hub.PublishOrderEvent(1, models.Order{OrderNum: "12345678903", AccrualStatus: models.AccrualStatusProcessing})
+		hub.PublishOrderEvent(2, models.Order{OrderNum: "2377225624", AccrualStatus: models.AccrualStatusProcessing})
+		hub.PublishOrderEvent(1, models.Order{OrderNum: "12345678903", AccrualStatus: models.AccrualStatusProcessed})
+
+		sub, missed := hub.Subscribe(1, 1)
+		defer hub.Unsubscribe(sub)
+
+		require.Len(t, missed, 1)
+		assert.Equal(t, uint64(3), missed[0].ID)
+		assert.Equal(t, models.AccrualStatusProcessed, missed[0].Order.AccrualStatus)
+	})
+
+	t.Run("drops subscribers that fall behind", func(t *testing.T) {
+		hub := NewHub()
+		sub, _ := hub.Subscribe(1, 0)
+
+		for range subscriberBuffer + 1 {
+			hub.PublishOrderEvent(1, models.Order{OrderNum: "12345678903"})
+		}
+
+		received := 0
+		for range sub.C {
+			received++
+		}
+		assert.Equal(t, subscriberBuffer, received)
+
+		// unsubscribing after the hub dropped the subscriber must not panic
+		hub.Unsubscribe(sub)
+	})
+}
@@ -0,0 +1,186 @@
+package export
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	ContentTypeCSV  = "text/csv"
+	ContentTypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// amountPlaces is the number of decimal places amounts are exported with.
+const amountPlaces = 2
+
+var ErrUnsupportedFormat = errors.New("unsupported export format")
+
+// Writer writes a table one row at a time. Cells are strings, decimal.Decimal
+// amounts, *decimal.Decimal amounts that may be missing, or time.Time instants.
+type Writer interface {
+	WriteRow(cells ...any) error
+	// Close writes whatever is still buffered. Nothing is complete before it is called.
+	Close() error
+}
+
+// NewWriter returns a writer for the content type with the header row already written.
+func NewWriter(contentType string, w io.Writer, sheet string, header ...string) (Writer, error) {
+	var writer Writer
+	switch contentType {
+	case ContentTypeCSV:
+		writer = NewCSVWriter(w)
+	case ContentTypeXLSX:
+		xlsxWriter, err := NewXLSXWriter(w, sheet)
+		if err != nil {
+			return nil, err
+		}
+		writer = xlsxWriter
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	cells := make([]any, 0, len(header))
+	for _, name := range header {
+		cells = append(cells, name)
+	}
+
+	if err := writer.WriteRow(cells...); err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+// Extension returns the file extension for the content type.
+func Extension(contentType string) string {
+	if contentType == ContentTypeXLSX {
+		return "xlsx"
+	}
+
+	return "csv"
+}
+
+type CSVWriter struct {
+	writer *csv.Writer
+}
+
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{
+		writer: csv.NewWriter(w),
+	}
+}
+
+func (w *CSVWriter) WriteRow(cells ...any) error {
+	record := make([]string, 0, len(cells))
+	for _, cell := range cells {
+		switch cell := cell.(type) {
+		case string:
+			record = append(record, cell)
+		case decimal.Decimal:
+			record = append(record, cell.StringFixed(amountPlaces))
+		case *decimal.Decimal:
+			if cell == nil {
+				record = append(record, "")
+			} else {
+				record = append(record, cell.StringFixed(amountPlaces))
+			}
+		case time.Time:
+			record = append(record, cell.Format(time.RFC3339))
+		default:
+			return fmt.Errorf("unsupported cell type %T", cell)
+		}
+	}
+
+	return w.writer.Write(record)
+}
+
+func (w *CSVWriter) Close() error {
+	w.writer.Flush()
+
+	return w.writer.Error()
+}
+
+// XLSXWriter streams rows into a single sheet. The workbook is a zip archive,
+// so it reaches w only when the writer is closed.
+type XLSXWriter struct {
+	w           io.Writer
+	file        *excelize.File
+	stream      *excelize.StreamWriter
+	amountStyle int
+	row         int
+}
+
+func NewXLSXWriter(w io.Writer, sheet string) (*XLSXWriter, error) {
+	file := excelize.NewFile()
+	if err := file.SetSheetName(file.GetSheetName(0), sheet); err != nil {
+		return nil, err
+	}
+
+	// built-in number format 2 is "0.00"
+	amountStyle, err := file.NewStyle(&excelize.Style{NumFmt: 2})
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := file.NewStreamWriter(sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	return &XLSXWriter{
+		w:           w,
+		file:        file,
+		stream:      stream,
+		amountStyle: amountStyle,
+	}, nil
+}
+
+func (w *XLSXWriter) WriteRow(cells ...any) error {
+	values := make([]any, 0, len(cells))
+	for _, cell := range cells {
+		switch cell := cell.(type) {
+		case string:
+			values = append(values, cell)
+		case decimal.Decimal:
+			values = append(values, w.amountCell(cell))
+		case *decimal.Decimal:
+			if cell == nil {
+				values = append(values, nil)
+			} else {
+				values = append(values, w.amountCell(*cell))
+			}
+		case time.Time:
+			values = append(values, cell.Format(time.RFC3339))
+		default:
+			return fmt.Errorf("unsupported cell type %T", cell)
+		}
+	}
+
+	w.row++
+	name, err := excelize.CoordinatesToCellName(1, w.row)
+	if err != nil {
+		return err
+	}
+
+	return w.stream.SetRow(name, values)
+}
+
+func (w *XLSXWriter) amountCell(amount decimal.Decimal) excelize.Cell {
+	return excelize.Cell{StyleID: w.amountStyle, Value: amount.Round(amountPlaces).InexactFloat64()}
+}
+
+func (w *XLSXWriter) Close() error {
+	defer w.file.Close()
+
+	if err := w.stream.Flush(); err != nil {
+		return err
+	}
+
+	return w.file.Write(w.w)
+}
@@ -0,0 +1,54 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+func writeTable(t *testing.T, contentType string) []byte {
+	var buf bytes.Buffer
+	writer, err := NewWriter(contentType, &buf, "orders", "number", "accrual", "uploaded_at")
+	require.NoError(t, err)
+
+	uploadedAt := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	accrual := decimal.RequireFromString("729.9")
+	require.NoError(t, writer.WriteRow("12345678903", &accrual, uploadedAt))
+	require.NoError(t, writer.WriteRow("2377225624", (*decimal.Decimal)(nil), uploadedAt))
+	require.NoError(t, writer.Close())
+
+	return buf.Bytes()
+}
+
+func TestCSVWriter(t *testing.T) {
+	want := "number,accrual,uploaded_at\n" +
+		"12345678903,729.90,2024-03-01T12:30:00Z\n" +
+		"2377225624,,2024-03-01T12:30:00Z\n"
+
+	assert.Equal(t, want, string(writeTable(t, ContentTypeCSV)))
+}
+
+func TestXLSXWriter(t *testing.T) {
+	file, err := excelize.OpenReader(bytes.NewReader(writeTable(t, ContentTypeXLSX)))
+	require.NoError(t, err)
+	defer file.Close()
+
+	rows, err := file.GetRows("orders")
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]string{
+		{"number", "accrual", "uploaded_at"},
+		{"12345678903", "729.90", "2024-03-01T12:30:00Z"},
+		{"2377225624", "", "2024-03-01T12:30:00Z"},
+	}, rows)
+}
+
+func TestNewWriterUnsupportedFormat(t *testing.T) {
+	_, err := NewWriter("application/pdf", &bytes.Buffer{}, "orders")
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
@@ -0,0 +1,79 @@
+package graph
+
+import (
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+// listFields are the fields returning pages of items.
+// Their selections cost as much as many items as the page may hold.
+var listFields = map[string]bool{
+	"orders":      true,
+	"withdrawals": true,
+}
+
+// Complexity counts the fields the operation selects, multiplying the selections of
+// list fields by their page size. It reports zero for queries it cannot parse and
+// leaves rejecting them to the schema.
+func Complexity(query string, operationName string, variables map[string]any) int {
+	doc, err := parser.ParseQuery(&ast.Source{Input: query})
+	if err != nil {
+		return 0
+	}
+
+	operation := doc.Operations.ForName(operationName)
+	if operation == nil {
+		return 0
+	}
+
+	return selectionComplexity(doc, operation.SelectionSet, variables, make(map[string]bool))
+}
+
+func selectionComplexity(doc *ast.QueryDocument, selections ast.SelectionSet, variables map[string]any, visiting map[string]bool) int {
+	complexity := 0
+	for _, selection := range selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			children := selectionComplexity(doc, selection.SelectionSet, variables, visiting)
+			if listFields[selection.Name] {
+				children *= pageSize(selection, variables)
+			}
+			complexity += 1 + children
+		case *ast.InlineFragment:
+			complexity += selectionComplexity(doc, selection.SelectionSet, variables, visiting)
+		case *ast.FragmentSpread:
+			fragment := doc.Fragments.ForName(selection.Name)
+			// cycles are rejected by validation, do not follow them here
+			if fragment == nil || visiting[fragment.Name] {
+				continue
+			}
+
+			visiting[fragment.Name] = true
+			complexity += selectionComplexity(doc, fragment.SelectionSet, variables, visiting)
+			delete(visiting, fragment.Name)
+		}
+	}
+
+	return complexity
+}
+
+func pageSize(field *ast.Field, variables map[string]any) int {
+	argument := field.Arguments.ForName("first")
+	if argument == nil {
+		return defaultPageSize
+	}
+
+	value, err := argument.Value.Value(variables)
+	if err != nil {
+		return defaultPageSize
+	}
+
+	switch value := value.(type) {
+	case int64:
+		return max(int(value), 0)
+	case float64:
+		return max(int(value), 0)
+	default:
+		return defaultPageSize
+	}
+}
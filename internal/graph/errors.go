@@ -0,0 +1,82 @@
+package graph
+
+import (
+	"errors"
+
+	"github.com/rovany706/loyalty-gopher/internal/helpers"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/services"
+)
+
+// Codes match the problem codes of the HTTP API where the errors are the same.
+const (
+	CodeValidationFailed   = "validation_failed"
+	CodeInvalidOrderNumber = "invalid_order_number"
+	CodeNotEnoughPoints    = "not_enough_points"
+	CodeOrderConflict      = "order_conflict"
+	CodeUserNotFound       = "user_not_found"
+	CodeMFANotEnrolled     = "mfa_not_enrolled"
+	CodeInvalidMFACode     = "invalid_mfa_code"
+	CodeWithdrawalNotPos   = "withdrawal_not_positive"
+	CodeWithdrawalTooSmall = "withdrawal_below_minimum"
+	CodeWithdrawalTooLarge = "withdrawal_above_maximum"
+	CodeWithdrawalPrecise  = "withdrawal_too_precise"
+	CodeDailyWithdrawLimit = "daily_withdrawal_limit_exceeded"
+	CodeHourlyWithdrawals  = "hourly_withdrawal_limit_exceeded"
+	CodeUploadsThrottled   = "order_uploads_throttled"
+	CodeQueryTooComplex    = "query_too_complex"
+	CodeInternal           = "internal"
+)
+
+var knownErrors = []struct {
+	err  error
+	code string
+}{
+	{helpers.ErrInvalidOrderNumber, CodeInvalidOrderNumber},
+	{repository.ErrNotEnoughPoints, CodeNotEnoughPoints},
+	{repository.ErrOrderConflict, CodeOrderConflict},
+	{repository.ErrUserNotFound, CodeUserNotFound},
+	{repository.ErrMFANotEnrolled, CodeMFANotEnrolled},
+	{services.ErrInvalidMFACode, CodeInvalidMFACode},
+	{services.ErrWithdrawalNotPositive, CodeWithdrawalNotPos},
+	{services.ErrWithdrawalBelowMinimum, CodeWithdrawalTooSmall},
+	{services.ErrWithdrawalAboveMaximum, CodeWithdrawalTooLarge},
+	{services.ErrWithdrawalTooPrecise, CodeWithdrawalPrecise},
+	{services.ErrDailyWithdrawalLimit, CodeDailyWithdrawLimit},
+	{services.ErrHourlyWithdrawalLimit, CodeHourlyWithdrawals},
+	{services.ErrOrderUploadsThrottled, CodeUploadsThrottled},
+}
+
+// Error is returned by resolvers so that clients get a stable code in the error extensions.
+type Error struct {
+	message string
+	code    string
+	err     error
+}
+
+func (e *Error) Error() string {
+	return e.message
+}
+
+func (e *Error) Extensions() map[string]any {
+	return map[string]any{"code": e.code}
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// publicError hides the messages of errors not meant for clients.
+func publicError(err error) error {
+	for _, known := range knownErrors {
+		if errors.Is(err, known.err) {
+			return &Error{message: known.err.Error(), code: known.code, err: err}
+		}
+	}
+
+	return &Error{message: "internal error", code: CodeInternal, err: err}
+}
+
+func validationError(message string) error {
+	return &Error{message: message, code: CodeValidationFailed}
+}
@@ -0,0 +1,88 @@
+package graph
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// batchWait is how long a loader waits for sibling fields to ask for more keys.
+const batchWait = time.Millisecond
+
+type loadResult[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// Loader collects the keys requested by fields resolved in parallel and fetches
+// them with one call. Results are cached for the lifetime of the loader, which
+// lives as long as a single request.
+type Loader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	cache   map[K]*loadResult[V]
+	pending map[K]*loadResult[V]
+}
+
+func NewLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error)) *Loader[K, V] {
+	return &Loader[K, V]{
+		fetch: fetch,
+		cache: make(map[K]*loadResult[V]),
+	}
+}
+
+// Load returns the value for the key, or the zero value if the batch has none.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+	result, ok := l.cache[key]
+	if !ok {
+		result = &loadResult[V]{done: make(chan struct{})}
+		l.cache[key] = result
+
+		if l.pending == nil {
+			l.pending = make(map[K]*loadResult[V])
+			time.AfterFunc(batchWait, func() { l.dispatch(ctx) })
+		}
+		l.pending[key] = result
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-result.done:
+		return result.value, result.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// Clear drops the cached value so the next Load fetches it again.
+func (l *Loader[K, V]) Clear(key K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// a pending load is still going to be fetched, keep it for its waiters
+	if _, ok := l.pending[key]; !ok {
+		delete(l.cache, key)
+	}
+}
+
+func (l *Loader[K, V]) dispatch(ctx context.Context) {
+	l.mu.Lock()
+	pending := l.pending
+	l.pending = nil
+	l.mu.Unlock()
+
+	keys := make([]K, 0, len(pending))
+	for key := range pending {
+		keys = append(keys, key)
+	}
+
+	values, err := l.fetch(ctx, keys)
+	for key, result := range pending {
+		result.value, result.err = values[key], err
+		close(result.done)
+	}
+}
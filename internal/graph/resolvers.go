@@ -0,0 +1,314 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/rovany706/loyalty-gopher/internal/helpers"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/services"
+	"github.com/shopspring/decimal"
+)
+
+// Decimal is the GraphQL scalar for point amounts.
+type Decimal struct {
+	decimal.Decimal
+}
+
+func (Decimal) ImplementsGraphQLType(name string) bool {
+	return name == "Decimal"
+}
+
+func (d *Decimal) UnmarshalGraphQL(input any) error {
+	var err error
+	switch input := input.(type) {
+	case string:
+		d.Decimal, err = decimal.NewFromString(input)
+	case float64:
+		d.Decimal = decimal.NewFromFloat(input)
+	case int32:
+		d.Decimal = decimal.NewFromInt32(input)
+	default:
+		err = fmt.Errorf("wrong type for Decimal: %T", input)
+	}
+
+	return err
+}
+
+type Resolver struct {
+	userRepository       repository.UserRepository
+	orderRepository      repository.OrderRepository
+	pointsRepository     repository.PointsRepository
+	accrualService       services.AccrualService
+	mfaService           services.MFAService
+	mfaWithdrawThreshold decimal.Decimal
+	withdrawalRules      services.WithdrawalRulesService
+	fraudService         services.FraudService
+}
+
+func (r *Resolver) Me(ctx context.Context) (*userResolver, error) {
+	request := requestFromContext(ctx)
+
+	user, err := r.userRepository.GetUser(ctx, request.userID)
+	if err != nil {
+		return nil, publicError(err)
+	}
+
+	return &userResolver{user: user, request: request, accrualService: r.accrualService}, nil
+}
+
+func (r *Resolver) UploadOrder(ctx context.Context, args struct{ Number string }) (*uploadOrderResult, error) {
+	request := requestFromContext(ctx)
+
+	if err := r.fraudService.CheckOrderUpload(ctx, request.userID, request.clientIP); err != nil {
+		return nil, publicError(err)
+	}
+
+	result, err := r.uploadOrder(ctx, request, args.Number)
+	if outcome, ok := services.OrderUploadOutcome(result != nil && result.created, err); ok {
+		r.fraudService.RecordOrderUploads(ctx, request.userID, request.clientIP, outcome)
+	}
+
+	return result, err
+}
+
+func (r *Resolver) uploadOrder(ctx context.Context, request *requestContext, orderNum string) (*uploadOrderResult, error) {
+	if !helpers.LuhnCheck(orderNum) {
+		return nil, publicError(helpers.ErrInvalidOrderNumber)
+	}
+
+	order, err := r.orderRepository.GetOrder(ctx, orderNum)
+	if err != nil {
+		return nil, publicError(err)
+	}
+
+	if order != nil {
+		if order.UserID != request.userID {
+			return nil, publicError(repository.ErrOrderConflict)
+		}
+
+		return &uploadOrderResult{created: false, order: order}, nil
+	}
+
+	if err := r.orderRepository.AddOrder(ctx, request.userID, orderNum); err != nil {
+		return nil, publicError(err)
+	}
+
+	order = &models.Order{
+		UserID:        request.userID,
+		OrderNum:      orderNum,
+		UploadedAt:    models.RFC3339Time(time.Now()),
+		AccrualStatus: models.AccrualStatusRegistered,
+	}
+	r.accrualService.QueueStatusUpdate(*order)
+	request.orders.Clear(request.userID)
+
+	return &uploadOrderResult{created: true, order: order}, nil
+}
+
+func (r *Resolver) Withdraw(ctx context.Context, args struct {
+	Order    string
+	Sum      Decimal
+	TotpCode *string
+}) (*balanceResolver, error) {
+	request := requestFromContext(ctx)
+
+	if !args.Sum.IsPositive() {
+		return nil, validationError("sum must be greater than 0")
+	}
+
+	if !helpers.LuhnCheck(args.Order) {
+		return nil, publicError(helpers.ErrInvalidOrderNumber)
+	}
+
+	if args.Sum.GreaterThan(r.mfaWithdrawThreshold) {
+		mfaEnabled, err := r.mfaService.IsEnabled(ctx, request.userID)
+		if err != nil {
+			return nil, publicError(err)
+		}
+
+		if mfaEnabled {
+			var code string
+			if args.TotpCode != nil {
+				code = *args.TotpCode
+			}
+
+			if err := r.mfaService.VerifyTOTP(ctx, request.userID, code); err != nil {
+				return nil, publicError(err)
+			}
+		}
+	}
+
+	err := r.withdrawalRules.Withdraw(ctx, request.userID, args.Order, args.Sum.Decimal, func(guard repository.WithdrawalGuard) error {
+		return r.pointsRepository.WithdrawPoints(ctx, request.userID, args.Order, args.Sum.Decimal, guard)
+	})
+	if err != nil {
+		return nil, publicError(err)
+	}
+
+	request.balances.Clear(request.userID)
+	request.withdrawals.Clear(request.userID)
+
+	balance, err := request.balances.Load(ctx, request.userID)
+	if err != nil {
+		return nil, publicError(err)
+	}
+
+	return &balanceResolver{balance: balance}, nil
+}
+
+type pageArgs struct {
+	First  int32
+	Offset int32
+}
+
+// page returns the items selected by first and offset.
+func page[T any](items []T, args pageArgs) ([]T, error) {
+	if args.First < 1 || args.First > maxPageSize {
+		return nil, validationError(fmt.Sprintf("first must be between 1 and %d", maxPageSize))
+	}
+
+	if args.Offset < 0 {
+		return nil, validationError("offset must not be negative")
+	}
+
+	start := min(int(args.Offset), len(items))
+	end := min(start+int(args.First), len(items))
+
+	return items[start:end], nil
+}
+
+type userResolver struct {
+	user           *models.User
+	request        *requestContext
+	accrualService services.AccrualService
+}
+
+func (r *userResolver) ID() graphql.ID {
+	return graphql.ID(strconv.Itoa(r.user.ID))
+}
+
+func (r *userResolver) Login() string {
+	return r.user.Login
+}
+
+func (r *userResolver) Balance(ctx context.Context) (*balanceResolver, error) {
+	balance, err := r.request.balances.Load(ctx, r.user.ID)
+	if err != nil {
+		return nil, publicError(err)
+	}
+
+	return &balanceResolver{balance: balance}, nil
+}
+
+func (r *userResolver) Orders(ctx context.Context, args pageArgs) ([]*orderResolver, error) {
+	orders, err := r.request.orders.Load(ctx, r.user.ID)
+	if err != nil {
+		return nil, publicError(err)
+	}
+
+	orders, err = page(orders, args)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := make([]*orderResolver, 0, len(orders))
+	for i := range orders {
+		r.accrualService.QueueStatusUpdate(orders[i])
+		resolvers = append(resolvers, &orderResolver{order: &orders[i]})
+	}
+
+	return resolvers, nil
+}
+
+func (r *userResolver) Withdrawals(ctx context.Context, args pageArgs) ([]*withdrawalResolver, error) {
+	withdrawals, err := r.request.withdrawals.Load(ctx, r.user.ID)
+	if err != nil {
+		return nil, publicError(err)
+	}
+
+	withdrawals, err = page(withdrawals, args)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := make([]*withdrawalResolver, 0, len(withdrawals))
+	for i := range withdrawals {
+		resolvers = append(resolvers, &withdrawalResolver{entry: &withdrawals[i]})
+	}
+
+	return resolvers, nil
+}
+
+type balanceResolver struct {
+	balance models.GetUserBalanceResponse
+}
+
+func (r *balanceResolver) Current() Decimal {
+	return Decimal{r.balance.Current}
+}
+
+func (r *balanceResolver) Withdrawn() Decimal {
+	return Decimal{r.balance.Withdrawn}
+}
+
+type orderResolver struct {
+	order *models.Order
+}
+
+func (r *orderResolver) Number() string {
+	return r.order.OrderNum
+}
+
+func (r *orderResolver) Status() string {
+	if r.order.AccrualStatus == models.AccrualStatusRegistered {
+		return string(models.AccrualStatusNew)
+	}
+
+	return string(r.order.AccrualStatus)
+}
+
+func (r *orderResolver) Accrual() *Decimal {
+	if r.order.AccrualStatus != models.AccrualStatusProcessed || r.order.Accrual == nil {
+		return nil
+	}
+
+	return &Decimal{*r.order.Accrual}
+}
+
+func (r *orderResolver) UploadedAt() graphql.Time {
+	return graphql.Time{Time: time.Time(r.order.UploadedAt)}
+}
+
+type withdrawalResolver struct {
+	entry *models.WithdrawHistoryEntry
+}
+
+func (r *withdrawalResolver) Order() string {
+	return r.entry.OrderNum
+}
+
+func (r *withdrawalResolver) Sum() Decimal {
+	return Decimal{r.entry.WithdrawSum}
+}
+
+func (r *withdrawalResolver) ProcessedAt() graphql.Time {
+	return graphql.Time{Time: time.Time(r.entry.ProcessedAt)}
+}
+
+type uploadOrderResult struct {
+	created bool
+	order   *models.Order
+}
+
+func (r *uploadOrderResult) Created() bool {
+	return r.created
+}
+
+func (r *uploadOrderResult) Order() *orderResolver {
+	return &orderResolver{order: r.order}
+}
@@ -0,0 +1,96 @@
+package graph
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+
+	"github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/services"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+//go:embed schema.graphql
+var schemaString string
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type requestContextKey struct{}
+
+// requestContext holds the signed in user and the loaders shared by the fields of one request.
+type requestContext struct {
+	userID      int
+	clientIP    string
+	orders      *Loader[int, []models.Order]
+	balances    *Loader[int, models.GetUserBalanceResponse]
+	withdrawals *Loader[int, []models.WithdrawHistoryEntry]
+}
+
+type Server struct {
+	schema           *graphql.Schema
+	orderRepository  repository.OrderRepository
+	pointsRepository repository.PointsRepository
+	maxComplexity    int
+	logger           *zap.Logger
+}
+
+func NewServer(ur repository.UserRepository, or repository.OrderRepository, pr repository.PointsRepository, as services.AccrualService, mfa services.MFAService, mfaWithdrawThreshold decimal.Decimal, wr services.WithdrawalRulesService, fs services.FraudService, maxDepth int, maxComplexity int, logger *zap.Logger) *Server {
+	resolver := &Resolver{
+		userRepository:       ur,
+		orderRepository:      or,
+		pointsRepository:     pr,
+		accrualService:       as,
+		mfaService:           mfa,
+		mfaWithdrawThreshold: mfaWithdrawThreshold,
+		withdrawalRules:      wr,
+		fraudService:         fs,
+	}
+
+	return &Server{
+		schema:           graphql.MustParseSchema(schemaString, resolver, graphql.MaxDepth(maxDepth)),
+		orderRepository:  or,
+		pointsRepository: pr,
+		maxComplexity:    maxComplexity,
+		logger:           logger,
+	}
+}
+
+// Exec runs the request on behalf of the user. Queries over the complexity limit are not run.
+func (s *Server) Exec(ctx context.Context, userID int, clientIP string, request models.GraphQLRequest) *graphql.Response {
+	if complexity := Complexity(request.Query, request.OperationName, request.Variables); complexity > s.maxComplexity {
+		return &graphql.Response{Errors: []*gqlerrors.QueryError{{
+			Message:    fmt.Sprintf("query complexity %d exceeds the limit of %d", complexity, s.maxComplexity),
+			Extensions: map[string]any{"code": CodeQueryTooComplex},
+		}}}
+	}
+
+	ctx = context.WithValue(ctx, requestContextKey{}, &requestContext{
+		userID:      userID,
+		clientIP:    clientIP,
+		orders:      NewLoader(s.orderRepository.GetOrdersByUserIDs),
+		balances:    NewLoader(s.pointsRepository.GetBalancesByUserIDs),
+		withdrawals: NewLoader(s.pointsRepository.GetWithdrawalsByUserIDs),
+	})
+
+	response := s.schema.Exec(ctx, request.Query, request.OperationName, request.Variables)
+	for _, err := range response.Errors {
+		var resolverErr *Error
+		if errors.As(err.ResolverError, &resolverErr) && resolverErr.code == CodeInternal {
+			s.logger.Error("graphql resolver failed", zap.Any("path", err.Path), zap.Error(resolverErr.err))
+		}
+	}
+
+	return response
+}
+
+func requestFromContext(ctx context.Context) *requestContext {
+	return ctx.Value(requestContextKey{}).(*requestContext)
+}
@@ -0,0 +1,175 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/services"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeUserRepository struct {
+	repository.UserRepository
+}
+
+func (r *fakeUserRepository) GetUser(_ context.Context, userID int) (*models.User, error) {
+	return &models.User{ID: userID, Login: "gopher", Role: models.RoleUser}, nil
+}
+
+type fakeOrderRepository struct {
+	repository.OrderRepository
+	batches atomic.Int32
+}
+
+func (r *fakeOrderRepository) GetOrdersByUserIDs(_ context.Context, userIDs []int) (map[int][]models.Order, error) {
+	r.batches.Add(1)
+	accrual := decimal.NewFromInt(500)
+
+	return map[int][]models.Order{userIDs[0]: {
+		{UserID: userIDs[0], OrderNum: "12345678903", AccrualStatus: models.AccrualStatusProcessed, Accrual: &accrual},
+		{UserID: userIDs[0], OrderNum: "79927398713", AccrualStatus: models.AccrualStatusNew},
+	}}, nil
+}
+
+func (r *fakeOrderRepository) GetOrder(_ context.Context, orderNum string) (*models.Order, error) {
+	if orderNum == "12345678903" {
+		return &models.Order{UserID: 2, OrderNum: orderNum}, nil
+	}
+
+	return nil, nil
+}
+
+func (r *fakeOrderRepository) AddOrder(context.Context, int, string) error {
+	return nil
+}
+
+type fakePointsRepository struct {
+	repository.PointsRepository
+	batches atomic.Int32
+}
+
+func (r *fakePointsRepository) GetBalancesByUserIDs(_ context.Context, userIDs []int) (map[int]models.GetUserBalanceResponse, error) {
+	r.batches.Add(1)
+
+	return map[int]models.GetUserBalanceResponse{userIDs[0]: {Current: decimal.NewFromInt(500), Withdrawn: decimal.NewFromInt(42)}}, nil
+}
+
+func (r *fakePointsRepository) GetWithdrawalsByUserIDs(_ context.Context, userIDs []int) (map[int][]models.WithdrawHistoryEntry, error) {
+	r.batches.Add(1)
+
+	return map[int][]models.WithdrawHistoryEntry{userIDs[0]: {{OrderNum: "2377225624", WithdrawSum: decimal.NewFromInt(42)}}}, nil
+}
+
+type fakeAccrualService struct {
+	services.AccrualService
+}
+
+func (s *fakeAccrualService) QueueStatusUpdate(models.Order) {}
+
+type fakeFraudService struct {
+	services.FraudService
+}
+
+func (s *fakeFraudService) CheckOrderUpload(context.Context, int, string) error {
+	return nil
+}
+
+func (s *fakeFraudService) RecordOrderUploads(context.Context, int, string, ...models.OrderBatchStatus) {
+}
+
+type graphQLResponse struct {
+	Data   map[string]any `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func exec(t *testing.T, server *Server, query string) graphQLResponse {
+	response := server.Exec(context.Background(), 1, "192.0.2.1", models.GraphQLRequest{Query: query})
+
+	body, err := json.Marshal(response)
+	require.NoError(t, err)
+
+	var decoded graphQLResponse
+	require.NoError(t, json.Unmarshal(body, &decoded))
+
+	return decoded
+}
+
+func TestServerBatchesLoads(t *testing.T) {
+	decimal.MarshalJSONWithoutQuotes = true
+
+	orders := &fakeOrderRepository{}
+	points := &fakePointsRepository{}
+	server := NewServer(&fakeUserRepository{}, orders, points, &fakeAccrualService{}, nil, decimal.Zero, nil, &fakeFraudService{}, 6, 1000, zap.NewNop())
+
+	response := exec(t, server, `{
+		me {
+			login
+			balance { current withdrawn }
+			recent: orders(first: 1) { number status accrual }
+			all: orders { number status }
+			withdrawals { order sum }
+		}
+	}`)
+	require.Empty(t, response.Errors)
+
+	me := response.Data["me"].(map[string]any)
+	assert.Equal(t, "gopher", me["login"])
+	assert.Equal(t, map[string]any{"current": 500.0, "withdrawn": 42.0}, me["balance"])
+	assert.Equal(t, []any{map[string]any{"number": "12345678903", "status": "PROCESSED", "accrual": 500.0}}, me["recent"])
+	assert.Len(t, me["all"], 2)
+	assert.Len(t, me["withdrawals"], 1)
+
+	assert.EqualValues(t, 1, orders.batches.Load())
+	assert.EqualValues(t, 2, points.batches.Load())
+}
+
+func TestServerLimits(t *testing.T) {
+	server := NewServer(&fakeUserRepository{}, &fakeOrderRepository{}, &fakePointsRepository{}, &fakeAccrualService{}, nil, decimal.Zero, nil, &fakeFraudService{}, 3, 50, zap.NewNop())
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode string
+	}{
+		{"within limits", `{ me { orders(first: 2) { number status } } }`, ""},
+		{"too complex", `{ me { orders(first: 100) { number status } } }`, CodeQueryTooComplex},
+		{"too complex through fragment", `{ me { ...page } } fragment page on User { orders(first: 30) { number status } }`, CodeQueryTooComplex},
+		{"page too large", `{ me { orders(first: 0) { number } } }`, CodeValidationFailed},
+		{"upload taken order", `mutation { uploadOrder(number: "12345678903") { created } }`, CodeOrderConflict},
+		{"upload invalid number", `mutation { uploadOrder(number: "79927398710") { created } }`, CodeInvalidOrderNumber},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := exec(t, server, tt.query)
+
+			if tt.wantCode == "" {
+				assert.Empty(t, response.Errors)
+				return
+			}
+
+			require.Len(t, response.Errors, 1)
+			assert.Equal(t, tt.wantCode, response.Errors[0].Extensions["code"])
+		})
+	}
+
+	t.Run("too deep", func(t *testing.T) {
+		shallow := NewServer(&fakeUserRepository{}, &fakeOrderRepository{}, &fakePointsRepository{}, &fakeAccrualService{}, nil, decimal.Zero, nil, &fakeFraudService{}, 2, 50, zap.NewNop())
+
+		response := exec(t, shallow, `{ me { login } }`)
+		assert.Empty(t, response.Errors)
+
+		response = exec(t, shallow, `{ me { orders { number } } }`)
+		assert.NotEmpty(t, response.Errors)
+	})
+}
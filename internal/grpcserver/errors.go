@@ -0,0 +1,65 @@
+package grpcserver
+
+import (
+	"errors"
+
+	"github.com/rovany706/loyalty-gopher/internal/helpers"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/services"
+	"github.com/rovany706/loyalty-gopher/internal/validation"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var knownErrors = []struct {
+	err  error
+	code codes.Code
+}{
+	{repository.ErrUserConfict, codes.AlreadyExists},
+	{repository.ErrOrderConflict, codes.AlreadyExists},
+	{repository.ErrUserDisabled, codes.PermissionDenied},
+	{repository.ErrUserNotFound, codes.NotFound},
+	{repository.ErrNotEnoughPoints, codes.FailedPrecondition},
+	{repository.ErrMFANotEnrolled, codes.FailedPrecondition},
+	{helpers.ErrInvalidOrderNumber, codes.InvalidArgument},
+	{services.ErrInvalidMFACode, codes.PermissionDenied},
+	{services.ErrWithdrawalNotPositive, codes.InvalidArgument},
+	{services.ErrWithdrawalBelowMinimum, codes.InvalidArgument},
+	{services.ErrWithdrawalAboveMaximum, codes.InvalidArgument},
+	{services.ErrWithdrawalTooPrecise, codes.InvalidArgument},
+	{services.ErrDailyWithdrawalLimit, codes.ResourceExhausted},
+	{services.ErrHourlyWithdrawalLimit, codes.ResourceExhausted},
+	{services.ErrOrderUploadsThrottled, codes.ResourceExhausted},
+}
+
+// toStatus maps repository and service errors to gRPC statuses. Unknown
+// errors become Internal without their message, like 500 problem responses.
+func toStatus(err error) error {
+	var fieldErrs validation.Errors
+	if errors.As(err, &fieldErrs) {
+		violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(fieldErrs))
+		for _, fieldErr := range fieldErrs {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       fieldErr.Field,
+				Description: fieldErr.Message,
+				Reason:      fieldErr.Code,
+			})
+		}
+
+		st, detailsErr := status.New(codes.InvalidArgument, "request validation failed").WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+		if detailsErr != nil {
+			return status.Error(codes.InvalidArgument, fieldErrs.Error())
+		}
+
+		return st.Err()
+	}
+
+	for _, known := range knownErrors {
+		if errors.Is(err, known.err) {
+			return status.Error(known.code, known.err.Error())
+		}
+	}
+
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,107 @@
+package grpcserver
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	loyaltyv1 "github.com/rovany706/loyalty-gopher/api/loyalty/v1"
+	"github.com/rovany706/loyalty-gopher/internal/auth"
+	"github.com/rovany706/loyalty-gopher/internal/middleware"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type userIDContextKey struct{}
+
+// publicMethods can be called without an access token.
+var publicMethods = map[string]bool{
+	loyaltyv1.LoyaltyService_Register_FullMethodName: true,
+	loyaltyv1.LoyaltyService_Login_FullMethodName:    true,
+	loyaltyv1.LoyaltyService_LoginMFA_FullMethodName: true,
+}
+
+// AuthInterceptor checks the bearer token in the "authorization" metadata
+// the same way middleware.AuthUser checks the Authorization header.
+func AuthInterceptor(tm auth.TokenManager, sessions middleware.SessionStore) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if publicMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get("authorization")
+		if len(values) == 0 {
+			return nil, status.Error(codes.Unauthenticated, "missing access token")
+		}
+
+		token, ok := strings.CutPrefix(values[0], "Bearer ")
+		if !ok || token == "" {
+			return nil, status.Error(codes.Unauthenticated, "missing access token")
+		}
+
+		claims, err := tm.GetClaimsFromToken(token)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid access token")
+		}
+
+		// tokens issued before sessions were introduced carry no session, they
+		// would escape revocation and the disabled check of TouchSession
+		if claims.SessionID == 0 {
+			return nil, status.Error(codes.Unauthenticated, "invalid access token")
+		}
+
+		active, err := sessions.TouchSession(ctx, claims.UserID, claims.SessionID)
+		if err != nil {
+			return nil, toStatus(err)
+		}
+
+		if !active {
+			return nil, status.Error(codes.Unauthenticated, "session revoked")
+		}
+
+		return handler(context.WithValue(ctx, userIDContextKey{}, claims.UserID), req)
+	}
+}
+
+// RateLimitInterceptor limits calls like middleware.RateLimit limits requests: per user ID
+// set by AuthInterceptor or, on the public methods, per client IP, with the routes of the
+// policy named by full method. It runs after AuthInterceptor. A failing store lets calls through.
+func RateLimitInterceptor(store middleware.RateLimitStore, policy middleware.RateLimitPolicy, logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		route, limit := policy.Limit(info.FullMethod)
+
+		client := "ip:" + clientIPFromContext(ctx)
+		if userID, ok := ctx.Value(userIDContextKey{}).(int); ok {
+			client = "user:" + strconv.Itoa(userID)
+		}
+
+		decision, err := store.TakeToken(ctx, route+"|"+client, limit, time.Now())
+		if err != nil {
+			logger.Warn("failed to check rate limit", zap.String("method", info.FullMethod), zap.Error(err))
+			return handler(ctx, req)
+		}
+
+		if !decision.Allowed {
+			retryAfter := strconv.Itoa(int(math.Ceil(decision.RetryAfter.Seconds())))
+			_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", retryAfter))
+			return nil, status.Error(codes.ResourceExhausted, "too many requests, retry in "+retryAfter+"s")
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func userIDFromContext(ctx context.Context) (int, error) {
+	userID, ok := ctx.Value(userIDContextKey{}).(int)
+	if !ok {
+		return 0, status.Error(codes.Unauthenticated, "missing access token")
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,382 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	loyaltyv1 "github.com/rovany706/loyalty-gopher/api/loyalty/v1"
+	"github.com/rovany706/loyalty-gopher/internal/auth"
+	"github.com/rovany706/loyalty-gopher/internal/helpers"
+	"github.com/rovany706/loyalty-gopher/internal/middleware"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/services"
+	"github.com/rovany706/loyalty-gopher/internal/validation"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// LoyaltyServer implements loyaltyv1.LoyaltyServiceServer on top of the
+// repositories and services used by the HTTP handlers.
+type LoyaltyServer struct {
+	loyaltyv1.UnimplementedLoyaltyServiceServer
+
+	userRepository        repository.UserRepository
+	sessionRepository     repository.SessionRepository
+	orderRepository       repository.OrderRepository
+	pointsRepository      repository.PointsRepository
+	tokenManager          auth.TokenManager
+	registrationValidator *validation.RegistrationValidator
+	mfaService            services.MFAService
+	accrualService        services.AccrualService
+	mfaWithdrawThreshold  decimal.Decimal
+	withdrawalRules       services.WithdrawalRulesService
+	fraudService          services.FraudService
+}
+
+func NewLoyaltyServer(ur repository.UserRepository, sr repository.SessionRepository, or repository.OrderRepository, pr repository.PointsRepository, tm auth.TokenManager, rv *validation.RegistrationValidator, mfa services.MFAService, as services.AccrualService, mfaWithdrawThreshold decimal.Decimal, wr services.WithdrawalRulesService, fs services.FraudService) *LoyaltyServer {
+	return &LoyaltyServer{
+		userRepository:        ur,
+		sessionRepository:     sr,
+		orderRepository:       or,
+		pointsRepository:      pr,
+		tokenManager:          tm,
+		registrationValidator: rv,
+		mfaService:            mfa,
+		accrualService:        as,
+		mfaWithdrawThreshold:  mfaWithdrawThreshold,
+		withdrawalRules:       wr,
+		fraudService:          fs,
+	}
+}
+
+// NewGRPCServer returns a server with the loyalty service, authentication and
+// the rate limits of the policy registered.
+func NewGRPCServer(ls *LoyaltyServer, sessions repository.SessionRepository, rateLimitStore middleware.RateLimitStore, rateLimitPolicy middleware.RateLimitPolicy, logger *zap.Logger) *grpc.Server {
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		AuthInterceptor(ls.tokenManager, sessions),
+		RateLimitInterceptor(rateLimitStore, rateLimitPolicy, logger),
+	))
+	loyaltyv1.RegisterLoyaltyServiceServer(server, ls)
+
+	return server
+}
+
+func (s *LoyaltyServer) Register(ctx context.Context, req *loyaltyv1.RegisterRequest) (*loyaltyv1.AuthResponse, error) {
+	login := validation.NormalizeLogin(req.GetLogin())
+	if err := s.registrationValidator.Validate(login, req.GetPassword()); err != nil {
+		return nil, toStatus(err)
+	}
+
+	userID, err := s.userRepository.Register(ctx, login, req.GetPassword())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return s.startSession(ctx, userID, models.RoleUser)
+}
+
+func (s *LoyaltyServer) Login(ctx context.Context, req *loyaltyv1.LoginRequest) (*loyaltyv1.LoginResponse, error) {
+	userID, err := s.userRepository.Login(ctx, validation.NormalizeLogin(req.GetLogin()), req.GetPassword())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	if userID == repository.UnauthorizedUserID {
+		return nil, status.Error(codes.Unauthenticated, "invalid login or password")
+	}
+
+	mfaEnabled, err := s.mfaService.IsEnabled(ctx, userID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	if mfaEnabled {
+		challengeToken, err := s.tokenManager.CreateMFAChallengeToken(userID)
+		if err != nil {
+			return nil, toStatus(err)
+		}
+
+		return &loyaltyv1.LoginResponse{Result: &loyaltyv1.LoginResponse_MfaChallenge{MfaChallenge: &loyaltyv1.MFAChallenge{
+			MfaToken:  challengeToken,
+			ExpiresIn: int64(auth.MFAChallengeExpiryTime.Seconds()),
+		}}}, nil
+	}
+
+	response, err := s.issueToken(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &loyaltyv1.LoginResponse{Result: &loyaltyv1.LoginResponse_Auth{Auth: response}}, nil
+}
+
+func (s *LoyaltyServer) LoginMFA(ctx context.Context, req *loyaltyv1.LoginMFARequest) (*loyaltyv1.AuthResponse, error) {
+	claims, err := s.tokenManager.GetClaimsFromMFAChallengeToken(req.GetMfaToken())
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid or expired MFA token")
+	}
+
+	err = s.mfaService.Verify(ctx, claims.UserID, req.GetCode(), req.GetRecoveryCode())
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidMFACode) || errors.Is(err, repository.ErrMFANotEnrolled) {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+
+		return nil, toStatus(err)
+	}
+
+	return s.issueToken(ctx, claims.UserID)
+}
+
+func (s *LoyaltyServer) UploadOrder(ctx context.Context, req *loyaltyv1.UploadOrderRequest) (*loyaltyv1.UploadOrderResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	clientIP := clientIPFromContext(ctx)
+	if err := s.fraudService.CheckOrderUpload(ctx, userID, clientIP); err != nil {
+		return nil, toStatus(err)
+	}
+
+	created, err := s.uploadOrder(ctx, userID, req.GetNumber())
+	if outcome, ok := services.OrderUploadOutcome(created, err); ok {
+		s.fraudService.RecordOrderUploads(ctx, userID, clientIP, outcome)
+	}
+
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &loyaltyv1.UploadOrderResponse{Created: created}, nil
+}
+
+// uploadOrder reports whether the order is new to the user.
+func (s *LoyaltyServer) uploadOrder(ctx context.Context, userID int, orderNum string) (bool, error) {
+	if !helpers.LuhnCheck(orderNum) {
+		return false, helpers.ErrInvalidOrderNumber
+	}
+
+	existingOrder, err := s.orderRepository.GetOrder(ctx, orderNum)
+	if err != nil {
+		return false, err
+	}
+
+	if existingOrder != nil {
+		if existingOrder.UserID != userID {
+			return false, repository.ErrOrderConflict
+		}
+
+		return false, nil
+	}
+
+	if err := s.orderRepository.AddOrder(ctx, userID, orderNum); err != nil {
+		return false, err
+	}
+
+	s.accrualService.QueueStatusUpdate(models.Order{
+		UserID:        userID,
+		OrderNum:      orderNum,
+		AccrualStatus: models.AccrualStatusRegistered,
+	})
+
+	return true, nil
+}
+
+func (s *LoyaltyServer) ListOrders(ctx context.Context, _ *loyaltyv1.ListOrdersRequest) (*loyaltyv1.ListOrdersResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := s.orderRepository.GetUserOrders(ctx, userID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	response := &loyaltyv1.ListOrdersResponse{Orders: make([]*loyaltyv1.Order, 0, len(orders))}
+	for _, order := range orders {
+		s.accrualService.QueueStatusUpdate(order)
+		response.Orders = append(response.Orders, toProtoOrder(order))
+	}
+
+	return response, nil
+}
+
+func (s *LoyaltyServer) GetBalance(ctx context.Context, _ *loyaltyv1.GetBalanceRequest) (*loyaltyv1.GetBalanceResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := s.pointsRepository.GetUserBalance(ctx, userID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	withdrawals, err := s.pointsRepository.GetUserWithdrawalHistory(ctx, userID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	withdrawn := decimal.Zero
+	for _, entry := range withdrawals {
+		withdrawn = withdrawn.Add(entry.WithdrawSum)
+	}
+
+	return &loyaltyv1.GetBalanceResponse{
+		Current:   balance.String(),
+		Withdrawn: withdrawn.String(),
+	}, nil
+}
+
+func (s *LoyaltyServer) Withdraw(ctx context.Context, req *loyaltyv1.WithdrawRequest) (*loyaltyv1.WithdrawResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sum, err := decimal.NewFromString(req.GetSum())
+	if err != nil || !sum.IsPositive() {
+		return nil, toStatus(validation.Errors{{
+			Field:   "sum",
+			Code:    validation.CodeOutOfRange,
+			Message: "must be a decimal number greater than 0",
+		}})
+	}
+
+	if !helpers.LuhnCheck(req.GetOrder()) {
+		return nil, toStatus(helpers.ErrInvalidOrderNumber)
+	}
+
+	if sum.GreaterThan(s.mfaWithdrawThreshold) {
+		mfaEnabled, err := s.mfaService.IsEnabled(ctx, userID)
+		if err != nil {
+			return nil, toStatus(err)
+		}
+
+		if mfaEnabled {
+			if err := s.mfaService.VerifyTOTP(ctx, userID, req.GetTotpCode()); err != nil {
+				return nil, toStatus(err)
+			}
+		}
+	}
+
+	err = s.withdrawalRules.Withdraw(ctx, userID, req.GetOrder(), sum, func(guard repository.WithdrawalGuard) error {
+		return s.pointsRepository.WithdrawPoints(ctx, userID, req.GetOrder(), sum, guard)
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &loyaltyv1.WithdrawResponse{}, nil
+}
+
+func (s *LoyaltyServer) ListWithdrawals(ctx context.Context, _ *loyaltyv1.ListWithdrawalsRequest) (*loyaltyv1.ListWithdrawalsResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	withdrawals, err := s.pointsRepository.GetUserWithdrawalHistory(ctx, userID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	response := &loyaltyv1.ListWithdrawalsResponse{Withdrawals: make([]*loyaltyv1.Withdrawal, 0, len(withdrawals))}
+	for _, entry := range withdrawals {
+		response.Withdrawals = append(response.Withdrawals, &loyaltyv1.Withdrawal{
+			Order:       entry.OrderNum,
+			Sum:         entry.WithdrawSum.String(),
+			ProcessedAt: timestamppb.New(time.Time(entry.ProcessedAt)),
+		})
+	}
+
+	return response, nil
+}
+
+func (s *LoyaltyServer) issueToken(ctx context.Context, userID int) (*loyaltyv1.AuthResponse, error) {
+	user, err := s.userRepository.GetUser(ctx, userID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	if user.IsDisabled() {
+		return nil, toStatus(repository.ErrUserDisabled)
+	}
+
+	return s.startSession(ctx, user.ID, user.Role)
+}
+
+// startSession records the calling client and returns an access token bound to that session.
+func (s *LoyaltyServer) startSession(ctx context.Context, userID int, role models.Role) (*loyaltyv1.AuthResponse, error) {
+	var userAgent string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("user-agent"); len(values) > 0 {
+			userAgent = values[0]
+		}
+	}
+
+	sessionID, err := s.sessionRepository.CreateSession(ctx, userID, userAgent, clientIPFromContext(ctx))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	token, err := s.tokenManager.CreateToken(userID, role, sessionID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &loyaltyv1.AuthResponse{
+		AccessToken: token,
+		ExpiresIn:   int64(auth.TokenExpiryTime.Seconds()),
+	}, nil
+}
+
+func toProtoOrder(order models.Order) *loyaltyv1.Order {
+	protoOrder := &loyaltyv1.Order{
+		Number:     order.OrderNum,
+		UploadedAt: timestamppb.New(time.Time(order.UploadedAt)),
+	}
+
+	switch order.AccrualStatus {
+	case models.AccrualStatusNew, models.AccrualStatusRegistered:
+		protoOrder.Status = loyaltyv1.OrderStatus_ORDER_STATUS_REGISTERED
+	case models.AccrualStatusProcessing:
+		protoOrder.Status = loyaltyv1.OrderStatus_ORDER_STATUS_PROCESSING
+	case models.AccrualStatusInvalid:
+		protoOrder.Status = loyaltyv1.OrderStatus_ORDER_STATUS_INVALID
+	case models.AccrualStatusProcessed:
+		protoOrder.Status = loyaltyv1.OrderStatus_ORDER_STATUS_PROCESSED
+		if order.Accrual != nil {
+			protoOrder.Accrual = order.Accrual.String()
+		} else {
+			protoOrder.Accrual = decimal.Zero.String()
+		}
+	}
+
+	return protoOrder
+}
+
+func clientIPFromContext(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	clientIP := p.Addr.String()
+	if host, _, err := net.SplitHostPort(clientIP); err == nil {
+		clientIP = host
+	}
+
+	return clientIP
+}
@@ -0,0 +1,206 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	loyaltyv1 "github.com/rovany706/loyalty-gopher/api/loyalty/v1"
+	"github.com/rovany706/loyalty-gopher/internal/auth"
+	"github.com/rovany706/loyalty-gopher/internal/middleware"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/services"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakeOrderRepository struct {
+	repository.OrderRepository
+	orders map[string]models.Order
+}
+
+func (r *fakeOrderRepository) GetOrder(_ context.Context, orderNum string) (*models.Order, error) {
+	order, ok := r.orders[orderNum]
+	if !ok {
+		return nil, nil
+	}
+
+	return &order, nil
+}
+
+func (r *fakeOrderRepository) AddOrder(_ context.Context, userID int, orderNum string) error {
+	r.orders[orderNum] = models.Order{UserID: userID, OrderNum: orderNum, AccrualStatus: models.AccrualStatusRegistered}
+
+	return nil
+}
+
+func (r *fakeOrderRepository) GetUserOrders(_ context.Context, userID int) ([]models.Order, error) {
+	orders := make([]models.Order, 0)
+	for _, order := range r.orders {
+		if order.UserID == userID {
+			orders = append(orders, order)
+		}
+	}
+
+	return orders, nil
+}
+
+type fakeAccrualService struct {
+	services.AccrualService
+}
+
+func (s *fakeAccrualService) QueueStatusUpdate(models.Order) {}
+
+// fakeSessionRepository knows the active sessions by ID.
+type fakeSessionRepository struct {
+	repository.SessionRepository
+	active map[int]bool
+}
+
+func (r *fakeSessionRepository) TouchSession(_ context.Context, _ int, sessionID int) (bool, error) {
+	return r.active[sessionID], nil
+}
+
+type fakeFraudService struct {
+	services.FraudService
+}
+
+func (s *fakeFraudService) CheckOrderUpload(context.Context, int, string) error {
+	return nil
+}
+
+func (s *fakeFraudService) RecordOrderUploads(context.Context, int, string, ...models.OrderBatchStatus) {
+}
+
+func TestLoyaltyServer(t *testing.T) {
+	tm, err := auth.NewJWTTokenManager([]byte("secret"))
+	require.NoError(t, err)
+
+	token, err := tm.CreateToken(1, models.RoleUser, 1)
+	require.NoError(t, err)
+
+	sessionlessToken, err := tm.CreateToken(1, models.RoleUser, 0)
+	require.NoError(t, err)
+
+	orders := &fakeOrderRepository{orders: map[string]models.Order{
+		"12345678903": {UserID: 2, OrderNum: "12345678903", AccrualStatus: models.AccrualStatusProcessed},
+	}}
+	loyaltyServer := NewLoyaltyServer(nil, nil, orders, nil, tm, nil, nil, &fakeAccrualService{}, decimal.Zero, nil, &fakeFraudService{})
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := NewGRPCServer(loyaltyServer, &fakeSessionRepository{active: map[int]bool{1: true}}, middleware.NewMemoryRateLimitStore(), middleware.RateLimitPolicy{Default: models.RateLimit{Requests: 10, Period: time.Minute}}, zap.NewNop())
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := loyaltyv1.NewLoyaltyServiceClient(conn)
+	authorized := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		number      string
+		wantCode    codes.Code
+		wantCreated bool
+	}{
+		{"without token", context.Background(), "79927398713", codes.Unauthenticated, false},
+		{"token without session", metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+sessionlessToken), "79927398713", codes.Unauthenticated, false},
+		{"new order", authorized, "79927398713", codes.OK, true},
+		{"same order again", authorized, "79927398713", codes.OK, false},
+		{"order of another user", authorized, "12345678903", codes.AlreadyExists, false},
+		{"invalid number", authorized, "79927398710", codes.InvalidArgument, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := client.UploadOrder(tt.ctx, &loyaltyv1.UploadOrderRequest{Number: tt.number})
+
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantCreated, response.GetCreated())
+		})
+	}
+
+	t.Run("list orders", func(t *testing.T) {
+		response, err := client.ListOrders(authorized, &loyaltyv1.ListOrdersRequest{})
+		require.NoError(t, err)
+
+		require.Len(t, response.GetOrders(), 1)
+		assert.Equal(t, "79927398713", response.GetOrders()[0].GetNumber())
+		assert.Equal(t, loyaltyv1.OrderStatus_ORDER_STATUS_REGISTERED, response.GetOrders()[0].GetStatus())
+	})
+}
+
+func TestRateLimitInterceptor(t *testing.T) {
+	perMinute := func(requests int) models.RateLimit {
+		return models.RateLimit{Requests: requests, Period: time.Minute}
+	}
+	interceptor := RateLimitInterceptor(middleware.NewMemoryRateLimitStore(), middleware.RateLimitPolicy{
+		Default: perMinute(100),
+		Routes: map[string]models.RateLimit{
+			loyaltyv1.LoyaltyService_Login_FullMethodName:      perMinute(2),
+			loyaltyv1.LoyaltyService_ListOrders_FullMethodName: perMinute(2),
+		},
+	}, zap.NewNop())
+	handler := func(context.Context, any) (any, error) {
+		return "ok", nil
+	}
+
+	clientCtx := func(ip string) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 50000}})
+	}
+	userCtx := func(ip string, userID int) context.Context {
+		return context.WithValue(clientCtx(ip), userIDContextKey{}, userID)
+	}
+	login := &grpc.UnaryServerInfo{FullMethod: loyaltyv1.LoyaltyService_Login_FullMethodName}
+	listOrders := &grpc.UnaryServerInfo{FullMethod: loyaltyv1.LoyaltyService_ListOrders_FullMethodName}
+	getBalance := &grpc.UnaryServerInfo{FullMethod: loyaltyv1.LoyaltyService_GetBalance_FullMethodName}
+
+	t.Run("public method per client IP", func(t *testing.T) {
+		for range 2 {
+			_, err := interceptor(clientCtx("203.0.113.7"), nil, login, handler)
+			require.NoError(t, err)
+		}
+
+		_, err := interceptor(clientCtx("203.0.113.7"), nil, login, handler)
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+		_, err = interceptor(clientCtx("203.0.113.8"), nil, login, handler)
+		assert.NoError(t, err)
+	})
+
+	t.Run("authenticated method per user", func(t *testing.T) {
+		// the limit follows the user across client IPs
+		for _, ip := range []string{"203.0.113.7", "203.0.113.9"} {
+			_, err := interceptor(userCtx(ip, 1), nil, listOrders, handler)
+			require.NoError(t, err)
+		}
+
+		_, err := interceptor(userCtx("203.0.113.10", 1), nil, listOrders, handler)
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+		_, err = interceptor(userCtx("203.0.113.7", 2), nil, listOrders, handler)
+		assert.NoError(t, err)
+
+		// the other methods share the default limit
+		_, err = interceptor(userCtx("203.0.113.7", 1), nil, getBalance, handler)
+		assert.NoError(t, err)
+	})
+}
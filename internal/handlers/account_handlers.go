@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/helpers"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+)
+
+const zipContentType = "application/zip"
+
+type AccountHandlers struct {
+	userRepository      repository.UserRepository
+	orderRepository     repository.OrderRepository
+	pointsRepository    repository.PointsRepository
+	deletionGracePeriod time.Duration
+}
+
+func NewAccountHandlers(ur repository.UserRepository, or repository.OrderRepository, pr repository.PointsRepository, deletionGracePeriod time.Duration) *AccountHandlers {
+	return &AccountHandlers{
+		userRepository:      ur,
+		orderRepository:     or,
+		pointsRepository:    pr,
+		deletionGracePeriod: deletionGracePeriod,
+	}
+}
+
+// ExportHandler returns everything stored about the user as one JSON
+// document, or as a ZIP archive with one file per section when requested
+// with ?format=zip or "Accept: application/zip".
+func (ah *AccountHandlers) ExportHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		export, err := ah.collectExport(ctx, userID)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		if ctx.Query("format") != "zip" && !strings.Contains(ctx.GetHeader("Accept"), zipContentType) {
+			ctx.Header("Content-Disposition", `attachment; filename="gophermart-export.json"`)
+			ctx.JSON(http.StatusOK, export)
+			return
+		}
+
+		ctx.Header("Content-Type", zipContentType)
+		ctx.Header("Content-Disposition", `attachment; filename="gophermart-export.zip"`)
+		ctx.Status(http.StatusOK)
+
+		if err := writeExportZip(ctx.Writer, export); err != nil {
+			// the archive is already partly sent, so only record the error
+			_ = ctx.Error(err)
+		}
+	}
+}
+
+func (ah *AccountHandlers) DeleteAccountHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		requestedAt, err := ah.userRepository.RequestDeletion(ctx, userID)
+		if err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				ctx.AbortWithError(http.StatusNotFound, err)
+				return
+			}
+
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusAccepted, models.AccountDeletionResponse{
+			DeletionRequestedAt: models.RFC3339Time(requestedAt),
+			DeletionScheduledAt: models.RFC3339Time(requestedAt.Add(ah.deletionGracePeriod)),
+		})
+	}
+}
+
+func (ah *AccountHandlers) CancelDeletionHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if err := ah.userRepository.CancelDeletion(ctx, userID); err != nil {
+			if errors.Is(err, repository.ErrDeletionNotRequested) {
+				ctx.AbortWithError(http.StatusConflict, err)
+				return
+			}
+
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+func (ah *AccountHandlers) collectExport(ctx *gin.Context, userID int) (*models.UserExport, error) {
+	user, err := ah.userRepository.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := getUserBalance(ctx, ah.pointsRepository, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := ah.orderRepository.GetUserOrders(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	withdrawals, err := ah.pointsRepository.GetUserWithdrawalHistory(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	adjustments, err := ah.pointsRepository.GetUserBalanceAdjustments(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.UserExport{
+		ExportedAt:         models.RFC3339Time(time.Now()),
+		Profile:            *user,
+		Balance:            balance,
+		Orders:             orders,
+		Withdrawals:        withdrawals,
+		BalanceAdjustments: adjustments,
+	}, nil
+}
+
+func writeExportZip(w http.ResponseWriter, export *models.UserExport) error {
+	archive := zip.NewWriter(w)
+
+	files := []struct {
+		name    string
+		content any
+	}{
+		{"profile.json", export.Profile},
+		{"balance.json", export.Balance},
+		{"orders.json", export.Orders},
+		{"withdrawals.json", export.Withdrawals},
+		{"balance_adjustments.json", export.BalanceAdjustments},
+	}
+
+	for _, file := range files {
+		f, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: time.Time(export.ExportedAt),
+		})
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.content); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.name, err)
+		}
+	}
+
+	return archive.Close()
+}
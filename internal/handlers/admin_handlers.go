@@ -0,0 +1,444 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/auth"
+	"github.com/rovany706/loyalty-gopher/internal/helpers"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/services"
+)
+
+type AdminHandlers struct {
+	userRepository         repository.UserRepository
+	orderRepository        repository.OrderRepository
+	pointsRepository       repository.PointsRepository
+	merchantRepository     repository.MerchantRepository
+	fraudService           services.FraudService
+	defaultAPIKeyRateLimit int
+}
+
+func NewAdminHandlers(ur repository.UserRepository, or repository.OrderRepository, pr repository.PointsRepository, mr repository.MerchantRepository, fs services.FraudService, defaultAPIKeyRateLimit int) *AdminHandlers {
+	return &AdminHandlers{
+		userRepository:         ur,
+		orderRepository:        or,
+		pointsRepository:       pr,
+		merchantRepository:     mr,
+		fraudService:           fs,
+		defaultAPIKeyRateLimit: defaultAPIKeyRateLimit,
+	}
+}
+
+func (ah *AdminHandlers) FindUserHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		login := ctx.Query("login")
+		if login == "" {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		user, err := ah.userRepository.FindUserByLogin(ctx, login)
+		if err != nil {
+			abortWithAdminError(ctx, err)
+			return
+		}
+
+		ah.respondWithUser(ctx, user)
+	}
+}
+
+func (ah *AdminHandlers) GetUserHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := ah.getUserFromParam(ctx)
+		if !ok {
+			return
+		}
+
+		ah.respondWithUser(ctx, user)
+	}
+}
+
+func (ah *AdminHandlers) GetUserOrdersHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := ah.getUserFromParam(ctx)
+		if !ok {
+			return
+		}
+
+		orders, err := ah.orderRepository.GetUserOrders(ctx, user.ID)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, orders)
+	}
+}
+
+func (ah *AdminHandlers) GetUserBalanceHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := ah.getUserFromParam(ctx)
+		if !ok {
+			return
+		}
+
+		balance, err := getUserBalance(ctx, ah.pointsRepository, user.ID)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, balance)
+	}
+}
+
+func (ah *AdminHandlers) GetUserWithdrawalsHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := ah.getUserFromParam(ctx)
+		if !ok {
+			return
+		}
+
+		withdrawalHistory, err := ah.pointsRepository.GetUserWithdrawalHistory(ctx, user.ID)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, withdrawalHistory)
+	}
+}
+
+func (ah *AdminHandlers) GetOrderHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		order, err := ah.orderRepository.GetOrder(ctx, ctx.Param("number"))
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		if order == nil {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, models.AdminOrderResponse{
+			Order:  *order,
+			UserID: order.UserID,
+		})
+	}
+}
+
+func (ah *AdminHandlers) AdjustBalanceHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		adminUserID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		user, ok := ah.getUserFromParam(ctx)
+		if !ok {
+			return
+		}
+
+		var request models.BalanceAdjustmentRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil || request.Amount.IsZero() {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		err := ah.pointsRepository.AdjustBalance(ctx, user.ID, adminUserID, request.Amount, request.Reason)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotEnoughPoints) {
+				ctx.AbortWithError(http.StatusPaymentRequired, err)
+				return
+			}
+
+			abortWithAdminError(ctx, err)
+			return
+		}
+
+		ah.respondWithUser(ctx, user)
+	}
+}
+
+func (ah *AdminHandlers) SetUserRoleHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := ah.getUserFromParam(ctx)
+		if !ok {
+			return
+		}
+
+		var request models.SetUserRoleRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil || !request.Role.IsValid() {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		if err := ah.userRepository.SetUserRole(ctx, user.ID, request.Role); err != nil {
+			abortWithAdminError(ctx, err)
+			return
+		}
+
+		user.Role = request.Role
+		ah.respondWithUser(ctx, user)
+	}
+}
+
+func (ah *AdminHandlers) DisableUserHandler() gin.HandlerFunc {
+	return ah.setUserDisabledHandler(true)
+}
+
+func (ah *AdminHandlers) EnableUserHandler() gin.HandlerFunc {
+	return ah.setUserDisabledHandler(false)
+}
+
+func (ah *AdminHandlers) setUserDisabledHandler(disabled bool) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := parseUserIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		if err := ah.userRepository.SetUserDisabled(ctx, userID, disabled); err != nil {
+			abortWithAdminError(ctx, err)
+			return
+		}
+
+		user, err := ah.userRepository.GetUser(ctx, userID)
+		if err != nil {
+			abortWithAdminError(ctx, err)
+			return
+		}
+
+		ah.respondWithUser(ctx, user)
+	}
+}
+
+func (ah *AdminHandlers) CreateMerchantHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var request models.CreateMerchantRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			ctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		merchant, err := ah.merchantRepository.CreateMerchant(ctx, request.Name)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, merchant)
+	}
+}
+
+func (ah *AdminHandlers) GetMerchantAPIKeysHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		merchant, ok := ah.getMerchantFromParam(ctx)
+		if !ok {
+			return
+		}
+
+		keys, err := ah.merchantRepository.GetMerchantAPIKeys(ctx, merchant.ID)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, keys)
+	}
+}
+
+// CreateAPIKeyHandler responds with the plain text key. It is not stored
+// and cannot be shown again.
+func (ah *AdminHandlers) CreateAPIKeyHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		merchant, ok := ah.getMerchantFromParam(ctx)
+		if !ok {
+			return
+		}
+
+		var request models.CreateAPIKeyRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil || len(request.Scopes) == 0 || request.RateLimitPerMinute < 0 {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		for _, scope := range request.Scopes {
+			if !scope.IsValid() {
+				ctx.AbortWithStatus(http.StatusBadRequest)
+				return
+			}
+		}
+
+		if request.RateLimitPerMinute == 0 {
+			request.RateLimitPerMinute = ah.defaultAPIKeyRateLimit
+		}
+
+		key, prefix, err := auth.GenerateAPIKey()
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		apiKey, err := ah.merchantRepository.CreateAPIKey(ctx, models.APIKey{
+			MerchantID:         merchant.ID,
+			Prefix:             prefix,
+			KeyHash:            auth.HashAPIKey(key),
+			Scopes:             request.Scopes,
+			RateLimitPerMinute: request.RateLimitPerMinute,
+		})
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, models.CreateAPIKeyResponse{
+			APIKey: *apiKey,
+			Key:    key,
+		})
+	}
+}
+
+func (ah *AdminHandlers) RevokeAPIKeyHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		merchant, ok := ah.getMerchantFromParam(ctx)
+		if !ok {
+			return
+		}
+
+		keyID, err := strconv.Atoi(ctx.Param("keyID"))
+		if err != nil {
+			ctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		if err := ah.merchantRepository.RevokeAPIKey(ctx, merchant.ID, keyID); err != nil {
+			abortWithAdminError(ctx, err)
+			return
+		}
+
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+// GetFraudReviewsHandler lists the reviews with the status given in the query,
+// pending by default, highest score first.
+func (ah *AdminHandlers) GetFraudReviewsHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		status := models.FraudReviewStatus(ctx.DefaultQuery("status", string(models.FraudReviewStatusPending)))
+		if !status.IsValid() {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		reviews, err := ah.fraudService.GetReviews(ctx, status)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, reviews)
+	}
+}
+
+func (ah *AdminHandlers) ResolveFraudReviewHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		adminUserID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		reviewID, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			ctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		var request models.ResolveFraudReviewRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil ||
+			(request.Status != models.FraudReviewStatusCleared && request.Status != models.FraudReviewStatusConfirmed) {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		review, err := ah.fraudService.ResolveReview(ctx, reviewID, request.Status, adminUserID)
+		if err != nil {
+			abortWithAdminError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, review)
+	}
+}
+
+func (ah *AdminHandlers) getMerchantFromParam(ctx *gin.Context) (*models.Merchant, bool) {
+	merchantID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	merchant, err := ah.merchantRepository.GetMerchant(ctx, merchantID)
+	if err != nil {
+		abortWithAdminError(ctx, err)
+		return nil, false
+	}
+
+	return merchant, true
+}
+
+func (ah *AdminHandlers) getUserFromParam(ctx *gin.Context) (*models.User, bool) {
+	userID, ok := parseUserIDParam(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	user, err := ah.userRepository.GetUser(ctx, userID)
+	if err != nil {
+		abortWithAdminError(ctx, err)
+		return nil, false
+	}
+
+	return user, true
+}
+
+func (ah *AdminHandlers) respondWithUser(ctx *gin.Context, user *models.User) {
+	balance, err := getUserBalance(ctx, ah.pointsRepository, user.ID)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.AdminUserResponse{
+		User:    *user,
+		Balance: balance,
+	})
+}
+
+func parseUserIDParam(ctx *gin.Context) (int, bool) {
+	userID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return 0, false
+	}
+
+	return userID, true
+}
+
+func abortWithAdminError(ctx *gin.Context, err error) {
+	if errors.Is(err, repository.ErrUserNotFound) || errors.Is(err, repository.ErrMerchantNotFound) || errors.Is(err, repository.ErrAPIKeyNotFound) ||
+		errors.Is(err, repository.ErrFraudReviewNotFound) {
+		ctx.AbortWithError(http.StatusNotFound, err)
+		return
+	}
+
+	ctx.AbortWithError(http.StatusInternalServerError, err)
+}
@@ -6,19 +6,30 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/rovany706/loyalty-gopher/internal/auth"
+	"github.com/rovany706/loyalty-gopher/internal/middleware"
 	"github.com/rovany706/loyalty-gopher/internal/models"
 	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/services"
+	"github.com/rovany706/loyalty-gopher/internal/validation"
 )
 
 type AuthHandlers struct {
-	userRepository repository.UserRepository
-	tokenManager   auth.TokenManager
+	userRepository        repository.UserRepository
+	sessionRepository     repository.SessionRepository
+	tokenManager          auth.TokenManager
+	registrationValidator *validation.RegistrationValidator
+	mfaService            services.MFAService
+	cookies               middleware.CookieSettings
 }
 
-func NewAuthHandlers(r repository.UserRepository, tm auth.TokenManager) *AuthHandlers {
+func NewAuthHandlers(r repository.UserRepository, sr repository.SessionRepository, tm auth.TokenManager, rv *validation.RegistrationValidator, mfa services.MFAService, cookies middleware.CookieSettings) *AuthHandlers {
 	return &AuthHandlers{
-		userRepository: r,
-		tokenManager:   tm,
+		userRepository:        r,
+		sessionRepository:     sr,
+		tokenManager:          tm,
+		registrationValidator: rv,
+		mfaService:            mfa,
+		cookies:               cookies,
 	}
 }
 
@@ -26,28 +37,38 @@ func (ah *AuthHandlers) RegisterHandler() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var request models.RegisterRequest
 		if err := ctx.ShouldBindJSON(&request); err != nil {
-			ctx.AbortWithError(http.StatusBadRequest, err)
+			abortWithValidationErrors(ctx, validation.Errors{{
+				Field:   validation.FieldBody,
+				Code:    validation.CodeMalformedBody,
+				Message: "request body must be a JSON object with login and password",
+			}})
 			return
 		}
 
-		userID, err := ah.userRepository.Register(ctx, request.Login, request.Password)
-		if err != nil {
-			if errors.Is(err, repository.ErrUserConfict) {
-				ctx.AbortWithError(http.StatusConflict, err)
+		request.Login = validation.NormalizeLogin(request.Login)
+
+		if err := ah.registrationValidator.Validate(request.Login, request.Password); err != nil {
+			var validationErrs validation.Errors
+			if errors.As(err, &validationErrs) {
+				abortWithValidationErrors(ctx, validationErrs)
 				return
 			}
+
 			ctx.AbortWithError(http.StatusInternalServerError, err)
 			return
 		}
 
-		token, err := ah.tokenManager.CreateToken(userID)
+		userID, err := ah.userRepository.Register(ctx, request.Login, request.Password)
 		if err != nil {
+			if errors.Is(err, repository.ErrUserConfict) {
+				ctx.AbortWithError(http.StatusConflict, err)
+				return
+			}
 			ctx.AbortWithError(http.StatusInternalServerError, err)
 			return
 		}
 
-		ctx.Header("Authorization", "Bearer "+token)
-		ctx.Status(http.StatusOK)
+		ah.startSession(ctx, userID, models.RoleUser)
 	}
 }
 
@@ -59,8 +80,14 @@ func (ah *AuthHandlers) LoginHandler() gin.HandlerFunc {
 			return
 		}
 
+		request.Login = validation.NormalizeLogin(request.Login)
+
 		userID, err := ah.userRepository.Login(ctx, request.Login, request.Password)
 		if err != nil {
+			if errors.Is(err, repository.ErrUserDisabled) {
+				ctx.AbortWithError(http.StatusForbidden, err)
+				return
+			}
 			ctx.AbortWithError(http.StatusInternalServerError, err)
 			return
 		}
@@ -70,13 +97,107 @@ func (ah *AuthHandlers) LoginHandler() gin.HandlerFunc {
 			return
 		}
 
-		token, err := ah.tokenManager.CreateToken(userID)
+		ah.signIn(ctx, userID)
+	}
+}
+
+func (ah *AuthHandlers) LoginMFAHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var request models.LoginMFARequest
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			ctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		claims, err := ah.tokenManager.GetClaimsFromMFAChallengeToken(request.MFAToken)
 		if err != nil {
+			ctx.AbortWithError(http.StatusUnauthorized, err)
+			return
+		}
+
+		err = ah.mfaService.Verify(ctx, claims.UserID, request.Code, request.RecoveryCode)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidMFACode) || errors.Is(err, repository.ErrMFANotEnrolled) {
+				ctx.AbortWithError(http.StatusUnauthorized, err)
+				return
+			}
+
 			ctx.AbortWithError(http.StatusInternalServerError, err)
 			return
 		}
 
-		ctx.Header("Authorization", "Bearer "+token)
-		ctx.Status(http.StatusOK)
+		ah.issueToken(ctx, claims.UserID)
 	}
 }
+
+// signIn asks users with 2FA enabled for a code before issuing the access token.
+func (ah *AuthHandlers) signIn(ctx *gin.Context, userID int) {
+	mfaEnabled, err := ah.mfaService.IsEnabled(ctx, userID)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	if mfaEnabled {
+		challengeToken, err := ah.tokenManager.CreateMFAChallengeToken(userID)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusAccepted, models.MFAChallengeResponse{
+			MFAToken:  challengeToken,
+			ExpiresIn: int(auth.MFAChallengeExpiryTime.Seconds()),
+		})
+		return
+	}
+
+	ah.issueToken(ctx, userID)
+}
+
+// issueToken responds with an access token carrying the user's current role.
+func (ah *AuthHandlers) issueToken(ctx *gin.Context, userID int) {
+	user, err := ah.userRepository.GetUser(ctx, userID)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	if user.IsDisabled() {
+		ctx.AbortWithError(http.StatusForbidden, repository.ErrUserDisabled)
+		return
+	}
+
+	ah.startSession(ctx, user.ID, user.Role)
+}
+
+// startSession records the device the user signed in from
+// and responds with an access token bound to that session.
+func (ah *AuthHandlers) startSession(ctx *gin.Context, userID int, role models.Role) {
+	sessionID, err := ah.sessionRepository.CreateSession(ctx, userID, ctx.Request.UserAgent(), ctx.ClientIP())
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	token, err := ah.tokenManager.CreateToken(userID, role, sessionID)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	if ah.cookies.Enabled {
+		if err := middleware.SetAuthCookies(ctx, ah.cookies, token); err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	ctx.Header("Authorization", "Bearer "+token)
+	ctx.Status(http.StatusOK)
+}
+
+// abortWithValidationErrors leaves rendering the field errors to the problem middleware.
+func abortWithValidationErrors(ctx *gin.Context, errs validation.Errors) {
+	ctx.AbortWithError(http.StatusBadRequest, errs)
+}
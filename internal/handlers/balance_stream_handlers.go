@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/rovany706/loyalty-gopher/internal/events"
+	"github.com/rovany706/loyalty-gopher/internal/helpers"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+)
+
+const (
+	balanceStreamWriteWait    = 10 * time.Second
+	balanceStreamPongWait     = 60 * time.Second
+	balanceStreamPingInterval = balanceStreamPongWait * 9 / 10
+	balanceStreamReadLimit    = 512
+)
+
+var ErrTooManyConnections = errors.New("too many balance connections")
+
+type BalanceStreamHandlers struct {
+	pointsRepository repository.PointsRepository
+	hub              *events.Hub
+	maxConnections   int
+	upgrader         websocket.Upgrader
+	mutex            sync.Mutex
+	connections      map[int]int
+}
+
+func NewBalanceStreamHandlers(pr repository.PointsRepository, hub *events.Hub, maxConnections int) *BalanceStreamHandlers {
+	return &BalanceStreamHandlers{
+		pointsRepository: pr,
+		hub:              hub,
+		maxConnections:   maxConnections,
+		// the default origin check rejects cross-site pages riding on the auth cookie
+		upgrader:    websocket.Upgrader{},
+		connections: make(map[int]int),
+	}
+}
+
+// BalanceStreamHandler upgrades to a WebSocket, sends the balance and then a
+// delta for every credit or withdrawal. Clients only send pongs and close frames.
+func (bh *BalanceStreamHandlers) BalanceStreamHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if !bh.acquire(userID) {
+			ctx.AbortWithError(http.StatusTooManyRequests, ErrTooManyConnections)
+			return
+		}
+		defer bh.release(userID)
+
+		// subscribe before reading the balance so that no change after it is lost
+		sub := bh.hub.SubscribeBalance(userID)
+		defer bh.hub.UnsubscribeBalance(sub)
+
+		balance, err := getUserBalance(ctx, bh.pointsRepository, userID)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		conn, err := bh.upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+		if err != nil {
+			// the upgrader has already replied with an error
+			return
+		}
+		defer conn.Close()
+
+		closed := make(chan struct{})
+		go readBalanceStream(conn, closed)
+
+		if err := writeBalanceMessage(conn, models.BalanceMessageSnapshot, balance); err != nil {
+			return
+		}
+
+		ping := time.NewTicker(balanceStreamPingInterval)
+		defer ping.Stop()
+
+		for {
+			select {
+			case <-closed:
+				return
+			case delta, ok := <-sub.C:
+				if !ok {
+					// dropped for falling behind, the client reconnects for a fresh balance
+					conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"), time.Now().Add(balanceStreamWriteWait))
+					return
+				}
+
+				if err := writeBalanceMessage(conn, models.BalanceMessageDelta, delta); err != nil {
+					return
+				}
+			case <-ping.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(balanceStreamWriteWait)); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (bh *BalanceStreamHandlers) acquire(userID int) bool {
+	bh.mutex.Lock()
+	defer bh.mutex.Unlock()
+
+	if bh.connections[userID] >= bh.maxConnections {
+		return false
+	}
+	bh.connections[userID]++
+
+	return true
+}
+
+func (bh *BalanceStreamHandlers) release(userID int) {
+	bh.mutex.Lock()
+	defer bh.mutex.Unlock()
+
+	bh.connections[userID]--
+	if bh.connections[userID] == 0 {
+		delete(bh.connections, userID)
+	}
+}
+
+// readBalanceStream processes pongs and close frames until the peer goes away
+// or stops answering pings.
+func readBalanceStream(conn *websocket.Conn, closed chan<- struct{}) {
+	defer close(closed)
+
+	conn.SetReadLimit(balanceStreamReadLimit)
+	conn.SetReadDeadline(time.Now().Add(balanceStreamPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(balanceStreamPongWait))
+	})
+
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func writeBalanceMessage(conn *websocket.Conn, messageType models.BalanceMessageType, data any) error {
+	conn.SetWriteDeadline(time.Now().Add(balanceStreamWriteWait))
+
+	return conn.WriteJSON(models.BalanceMessage{Type: messageType, Data: data})
+}
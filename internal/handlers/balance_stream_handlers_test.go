@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/rovany706/loyalty-gopher/internal/events"
+	"github.com/rovany706/loyalty-gopher/internal/middleware"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePointsRepository struct {
+	repository.PointsRepository
+}
+
+func (r *fakePointsRepository) GetUserBalance(context.Context, int) (decimal.Decimal, error) {
+	return decimal.NewFromInt(700), nil
+}
+
+func (r *fakePointsRepository) GetUserWithdrawalHistory(context.Context, int) ([]models.WithdrawHistoryEntry, error) {
+	return []models.WithdrawHistoryEntry{{OrderNum: "2377225624", WithdrawSum: decimal.NewFromInt(300)}}, nil
+}
+
+func TestBalanceStreamHandler(t *testing.T) {
+	decimal.MarshalJSONWithoutQuotes = true
+	gin.SetMode(gin.TestMode)
+
+	hub := events.NewHub()
+	r := gin.New()
+	r.GET("/ws", func(ctx *gin.Context) {
+		ctx.Set(middleware.UserIDContextKey, 1)
+	}, NewBalanceStreamHandlers(&fakePointsRepository{}, hub, 1).BalanceStreamHandler())
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, snapshot, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"balance","data":{"current":700,"withdrawn":300}}`, string(snapshot))
+
+	t.Run("rejects connections over the limit", func(t *testing.T) {
+		_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+		require.ErrorIs(t, err, websocket.ErrBadHandshake)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	})
+
+	t.Run("pushes deltas", func(t *testing.T) {
+		hub.PublishBalanceDelta(2, models.BalanceDelta{Current: decimal.NewFromInt(1), Withdrawn: decimal.Zero, Reason: models.BalanceChangeAccrual})
+		hub.PublishBalanceDelta(1, models.BalanceDelta{Current: decimal.NewFromInt(-50), Withdrawn: decimal.NewFromInt(50), Reason: models.BalanceChangeWithdrawal})
+
+		_, delta, err := conn.ReadMessage()
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"type":"delta","data":{"current":-50,"withdrawn":50,"reason":"withdrawal"}}`, string(delta))
+	})
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/export"
+)
+
+// exportFormat returns the export content type the client asked for in the Accept
+// header, or an empty string when it wants JSON.
+func exportFormat(ctx *gin.Context) string {
+	switch format := ctx.NegotiateFormat(gin.MIMEJSON, export.ContentTypeCSV, export.ContentTypeXLSX); format {
+	case export.ContentTypeCSV, export.ContentTypeXLSX:
+		return format
+	default:
+		return ""
+	}
+}
+
+// writeExport responds with a downloadable table whose rows are written by writeRows
+// as they are read. A failure after the first rows reached the client can only be
+// recorded, the status is already sent.
+func writeExport(ctx *gin.Context, format string, name string, header []string, writeRows func(export.Writer) error) {
+	writer, err := export.NewWriter(format, ctx.Writer, name, header...)
+	if err != nil {
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Header("Content-Type", format)
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, export.Extension(format)))
+	ctx.Status(http.StatusOK)
+
+	if err := writeRows(writer); err != nil {
+		abortExport(ctx, err)
+		return
+	}
+
+	if err := writer.Close(); err != nil {
+		abortExport(ctx, err)
+	}
+}
+
+func abortExport(ctx *gin.Context, err error) {
+	if ctx.Writer.Written() {
+		_ = ctx.Error(err)
+		ctx.Abort()
+		return
+	}
+
+	ctx.Writer.Header().Del("Content-Disposition")
+	ctx.AbortWithError(http.StatusInternalServerError, err)
+}
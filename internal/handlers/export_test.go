@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/export"
+	"github.com/rovany706/loyalty-gopher/internal/middleware"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (r *fakePointsRepository) StreamUserWithdrawals(_ context.Context, _ int, fn func(models.WithdrawHistoryEntry) error) error {
+	return fn(models.WithdrawHistoryEntry{
+		OrderNum:    "2377225624",
+		WithdrawSum: decimal.NewFromInt(300),
+		ProcessedAt: models.RFC3339Time(time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)),
+	})
+}
+
+func TestWithdrawalHistoryExport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.GET("/api/user/withdrawals", func(ctx *gin.Context) {
+		ctx.Set(middleware.UserIDContextKey, 1)
+	}, NewPointsHandlers(&fakePointsRepository{}, nil, decimal.Zero, nil).GetUserWithdrawalHistory())
+
+	tests := []struct {
+		name            string
+		accept          string
+		wantContentType string
+		wantBody        string
+	}{
+		{"csv", "text/csv", export.ContentTypeCSV, "order,sum,processed_at\n2377225624,300.00,2024-03-01T12:30:00Z\n"},
+		{"xlsx preferred", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet, text/csv;q=0.5", export.ContentTypeXLSX, ""},
+		{"json by default", "", gin.MIMEJSON, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/user/withdrawals", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Header().Get("Content-Type"), tt.wantContentType)
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
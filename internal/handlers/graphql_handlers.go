@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/graph"
+	"github.com/rovany706/loyalty-gopher/internal/helpers"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+)
+
+type GraphQLHandlers struct {
+	server *graph.Server
+}
+
+func NewGraphQLHandlers(server *graph.Server) *GraphQLHandlers {
+	return &GraphQLHandlers{
+		server: server,
+	}
+}
+
+// GraphQLHandler runs the query as the signed in user. Like other GraphQL servers it
+// answers 200 with the errors in the response body once the request is well formed.
+func (gh *GraphQLHandlers) GraphQLHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var request models.GraphQLRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			abortWithMalformedBody(ctx)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gh.server.Exec(ctx, userID, ctx.ClientIP(), request))
+	}
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/helpers"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/services"
+	"github.com/shopspring/decimal"
+)
+
+const householdNameMaxLength = 64
+
+var ErrInvalidHouseholdName = errors.New("household name must be 1 to 64 characters")
+
+type HouseholdHandlers struct {
+	householdRepository  repository.HouseholdRepository
+	mfaService           services.MFAService
+	mfaWithdrawThreshold decimal.Decimal
+	withdrawalRules      services.WithdrawalRulesService
+}
+
+func NewHouseholdHandlers(hr repository.HouseholdRepository, mfa services.MFAService, mfaWithdrawThreshold decimal.Decimal, wr services.WithdrawalRulesService) *HouseholdHandlers {
+	return &HouseholdHandlers{
+		householdRepository:  hr,
+		mfaService:           mfa,
+		mfaWithdrawThreshold: mfaWithdrawThreshold,
+		withdrawalRules:      wr,
+	}
+}
+
+func (hh *HouseholdHandlers) CreateHouseholdHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var request models.CreateHouseholdRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			ctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		name := strings.TrimSpace(request.Name)
+		if name == "" || utf8.RuneCountInString(name) > householdNameMaxLength {
+			ctx.AbortWithError(http.StatusBadRequest, ErrInvalidHouseholdName)
+			return
+		}
+
+		household, err := hh.householdRepository.CreateHousehold(ctx, userID, name)
+		if err != nil {
+			abortWithHouseholdError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, household)
+	}
+}
+
+func (hh *HouseholdHandlers) GetHouseholdHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		household, err := hh.householdRepository.GetUserHousehold(ctx, userID)
+		if err != nil {
+			abortWithHouseholdError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, household)
+	}
+}
+
+func (hh *HouseholdHandlers) AcceptInvitationHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		household, err := hh.householdRepository.AcceptInvitation(ctx, userID)
+		if err != nil {
+			abortWithHouseholdError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, household)
+	}
+}
+
+func (hh *HouseholdHandlers) LeaveHouseholdHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if err := hh.householdRepository.LeaveHousehold(ctx, userID); err != nil {
+			abortWithHouseholdError(ctx, err)
+			return
+		}
+
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+func (hh *HouseholdHandlers) InviteMemberHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var request models.InviteHouseholdMemberRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil || request.Login == "" {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		household, err := hh.householdRepository.InviteMember(ctx, userID, request.Login)
+		if err != nil {
+			abortWithHouseholdError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, household)
+	}
+}
+
+func (hh *HouseholdHandlers) RemoveMemberHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, memberID, ok := householdMemberParams(ctx)
+		if !ok {
+			return
+		}
+
+		if err := hh.householdRepository.RemoveMember(ctx, userID, memberID); err != nil {
+			abortWithHouseholdError(ctx, err)
+			return
+		}
+
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+// SetAllowanceHandler takes a null allowance to lift the limit of the member.
+func (hh *HouseholdHandlers) SetAllowanceHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, memberID, ok := householdMemberParams(ctx)
+		if !ok {
+			return
+		}
+
+		var request models.SetHouseholdAllowanceRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil || (request.Allowance != nil && request.Allowance.IsNegative()) {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		household, err := hh.householdRepository.SetAllowance(ctx, userID, memberID, request.Allowance)
+		if err != nil {
+			abortWithHouseholdError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, household)
+	}
+}
+
+// WithdrawHandler pays for an order from the pool, checked like a withdrawal from
+// the member's own account and against the allowance of the member.
+func (hh *HouseholdHandlers) WithdrawHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var request models.WithdrawUserPointsRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			ctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		if !request.WithdrawSum.IsPositive() {
+			ctx.AbortWithError(http.StatusUnprocessableEntity, repository.ErrInvalidWithdrawSum)
+			return
+		}
+
+		if ok := helpers.LuhnCheck(request.OrderNum); !ok {
+			ctx.AbortWithError(http.StatusUnprocessableEntity, helpers.ErrInvalidOrderNumber)
+			return
+		}
+
+		if err := checkWithdrawMFA(ctx, hh.mfaService, hh.mfaWithdrawThreshold, userID, request.WithdrawSum); err != nil {
+			if errors.Is(err, services.ErrInvalidMFACode) {
+				ctx.AbortWithError(http.StatusForbidden, err)
+				return
+			}
+
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		err := hh.withdrawalRules.Withdraw(ctx, userID, request.OrderNum, request.WithdrawSum, func(guard repository.WithdrawalGuard) error {
+			return hh.householdRepository.WithdrawFromHousehold(ctx, userID, request.OrderNum, request.WithdrawSum, guard)
+		})
+		if err != nil {
+			if errors.Is(err, services.ErrWithdrawalRefused) {
+				ctx.AbortWithError(http.StatusUnprocessableEntity, err)
+				return
+			}
+
+			abortWithHouseholdError(ctx, err)
+			return
+		}
+
+		ctx.Status(http.StatusOK)
+	}
+}
+
+func householdMemberParams(ctx *gin.Context) (userID int, memberID int, ok bool) {
+	userID, ok = helpers.GetUserIDFromContext(ctx)
+	if !ok {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return 0, 0, false
+	}
+
+	memberID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return 0, 0, false
+	}
+
+	return userID, memberID, true
+}
+
+func abortWithHouseholdError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrHouseholdNotFound), errors.Is(err, repository.ErrHouseholdMemberNotFound):
+		ctx.AbortWithError(http.StatusNotFound, err)
+	case errors.Is(err, repository.ErrNotHouseholdOwner):
+		ctx.AbortWithError(http.StatusForbidden, err)
+	case errors.Is(err, repository.ErrAlreadyInHousehold), errors.Is(err, repository.ErrOwnerHasMembers):
+		ctx.AbortWithError(http.StatusConflict, err)
+	case errors.Is(err, repository.ErrInviteeNotFound), errors.Is(err, repository.ErrAllowanceExceeded),
+		errors.Is(err, repository.ErrInvalidWithdrawSum):
+		ctx.AbortWithError(http.StatusUnprocessableEntity, err)
+	case errors.Is(err, repository.ErrNotEnoughPoints):
+		ctx.AbortWithError(http.StatusPaymentRequired, err)
+	default:
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/middleware"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeHouseholdRepository maps members to the owners of their households
+// and, like the database, only finds members of the caller's own household.
+type fakeHouseholdRepository struct {
+	repository.HouseholdRepository
+	owners map[int]int
+}
+
+func (r *fakeHouseholdRepository) SetAllowance(_ context.Context, ownerID int, memberID int, allowance *decimal.Decimal) (*models.Household, error) {
+	if r.owners[ownerID] != ownerID {
+		return nil, repository.ErrNotHouseholdOwner
+	}
+
+	if memberID == ownerID || r.owners[memberID] != ownerID {
+		return nil, repository.ErrHouseholdMemberNotFound
+	}
+
+	return &models.Household{Members: []models.HouseholdMember{{UserID: memberID, Allowance: allowance}}}, nil
+}
+
+func TestSetHouseholdAllowance(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeHouseholdRepository{owners: map[int]int{1: 1, 2: 1, 3: 4, 4: 4}}
+	r := gin.New()
+	r.PUT("/api/user/household/members/:id/allowance", func(ctx *gin.Context) {
+		userID, _ := strconv.Atoi(ctx.GetHeader("X-User-ID"))
+		ctx.Set(middleware.UserIDContextKey, userID)
+	}, NewHouseholdHandlers(repo, nil, decimal.Zero, nil).SetAllowanceHandler())
+
+	tests := []struct {
+		name       string
+		callerID   string
+		memberID   string
+		body       string
+		wantStatus int
+	}{
+		{"set allowance", "1", "2", `{"allowance":150.5}`, http.StatusOK},
+		{"lift allowance", "1", "2", `{"allowance":null}`, http.StatusOK},
+		{"negative allowance", "1", "2", `{"allowance":-1}`, http.StatusBadRequest},
+		{"invalid member id", "1", "abc", `{"allowance":10}`, http.StatusBadRequest},
+		{"member of another household", "1", "3", `{"allowance":10}`, http.StatusNotFound},
+		{"unknown member", "1", "5", `{"allowance":10}`, http.StatusNotFound},
+		{"caller is not an owner", "2", "1", `{"allowance":10}`, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/api/user/household/members/"+tt.memberID+"/allowance", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-User-ID", tt.callerID)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestHouseholdWithdrawRejectsNonPositiveSum(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.POST("/api/user/household/withdraw", func(ctx *gin.Context) {
+		ctx.Set(middleware.UserIDContextKey, 2)
+	}, NewHouseholdHandlers(&fakeHouseholdRepository{}, nil, decimal.Zero, nil).WithdrawHandler())
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"negative sum", `{"order":"2377225624","sum":-100}`},
+		{"zero sum", `{"order":"2377225624","sum":0}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/user/household/withdraw", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/auth"
+	"github.com/rovany706/loyalty-gopher/internal/helpers"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/services"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+const redemptionCodeTTL = 10 * time.Minute
+
+type MerchantHandlers struct {
+	userRepository           repository.UserRepository
+	orderRepository          repository.OrderRepository
+	pointsRepository         repository.PointsRepository
+	redemptionCodeRepository repository.RedemptionCodeRepository
+	accrualService           services.AccrualService
+	mfaService               services.MFAService
+	mfaWithdrawThreshold     decimal.Decimal
+	withdrawalRules          services.WithdrawalRulesService
+	fraudService             services.FraudService
+	logger                   *zap.Logger
+}
+
+func NewMerchantHandlers(ur repository.UserRepository, or repository.OrderRepository, pr repository.PointsRepository, rcr repository.RedemptionCodeRepository, as services.AccrualService, mfa services.MFAService, mfaWithdrawThreshold decimal.Decimal, wr services.WithdrawalRulesService, fs services.FraudService, logger *zap.Logger) *MerchantHandlers {
+	return &MerchantHandlers{
+		userRepository:           ur,
+		orderRepository:          or,
+		pointsRepository:         pr,
+		redemptionCodeRepository: rcr,
+		accrualService:           as,
+		mfaService:               mfa,
+		mfaWithdrawThreshold:     mfaWithdrawThreshold,
+		withdrawalRules:          wr,
+		fraudService:             fs,
+		logger:                   logger,
+	}
+}
+
+// CreateRedemptionCodeHandler issues the customer a one-time code that lets
+// a merchant redeem the customer's points once.
+func (mh *MerchantHandlers) CreateRedemptionCodeHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		code, err := auth.GenerateRedemptionCode()
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		expiresAt := time.Now().Add(redemptionCodeTTL)
+		if err := mh.redemptionCodeRepository.CreateRedemptionCode(ctx, userID, auth.HashRedemptionCode(code), expiresAt); err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, models.RedemptionCodeResponse{
+			Code:      code,
+			ExpiresAt: models.RFC3339Time(expiresAt),
+		})
+	}
+}
+
+func (mh *MerchantHandlers) PostCustomerOrderHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		apiKey, ok := helpers.GetAPIKeyFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var request models.MerchantOrderRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			ctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		user, ok := mh.findCustomer(ctx, request.Customer)
+		if !ok {
+			return
+		}
+
+		// scored per API key rather than per IP, so a leaked key is throttled on its own
+		status, err := uploadOrder(ctx, mh.orderRepository, mh.accrualService, mh.fraudService, user.ID, models.FraudAPIKeyClient(apiKey.ID), request.OrderNum)
+		if err != nil {
+			ctx.AbortWithError(status, err)
+			return
+		}
+
+		if status == http.StatusAccepted {
+			mh.logger.Info("merchant uploaded order", zap.Int("merchant_id", apiKey.MerchantID), zap.Int("user_id", user.ID), zap.String("num", request.OrderNum))
+		}
+
+		ctx.Status(status)
+	}
+}
+
+func (mh *MerchantHandlers) RedeemPointsHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		apiKey, ok := helpers.GetAPIKeyFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var request models.MerchantRedeemRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil || !request.WithdrawSum.IsPositive() {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		if ok := helpers.LuhnCheck(request.OrderNum); !ok {
+			ctx.AbortWithError(http.StatusUnprocessableEntity, helpers.ErrInvalidOrderNumber)
+			return
+		}
+
+		user, ok := mh.findCustomer(ctx, request.Customer)
+		if !ok {
+			return
+		}
+
+		// the code is spent even if the redemption fails, so it cannot be guessed at
+		used, err := mh.redemptionCodeRepository.UseRedemptionCode(ctx, user.ID, auth.HashRedemptionCode(request.Code))
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		if !used {
+			ctx.AbortWithError(http.StatusForbidden, repository.ErrInvalidRedemptionCode)
+			return
+		}
+
+		// the customer's TOTP code is passed on by the merchant in the same header
+		if err := checkWithdrawMFA(ctx, mh.mfaService, mh.mfaWithdrawThreshold, user.ID, request.WithdrawSum); err != nil {
+			if errors.Is(err, services.ErrInvalidMFACode) {
+				ctx.AbortWithError(http.StatusForbidden, err)
+				return
+			}
+
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		err = mh.withdrawalRules.Withdraw(ctx, user.ID, request.OrderNum, request.WithdrawSum, func(guard repository.WithdrawalGuard) error {
+			return mh.pointsRepository.WithdrawPoints(ctx, user.ID, request.OrderNum, request.WithdrawSum, guard)
+		})
+		if err != nil {
+			abortWithWithdrawalError(ctx, err)
+			return
+		}
+
+		mh.logger.Info("merchant redeemed points", zap.Int("merchant_id", apiKey.MerchantID), zap.Int("user_id", user.ID), zap.String("sum", request.WithdrawSum.String()))
+
+		ctx.Status(http.StatusOK)
+	}
+}
+
+func (mh *MerchantHandlers) findCustomer(ctx *gin.Context, customer models.Customer) (*models.User, bool) {
+	var user *models.User
+	var err error
+
+	switch {
+	case customer.LoyaltyCardID != "":
+		user, err = mh.userRepository.FindUserByLoyaltyCard(ctx, customer.LoyaltyCardID)
+	case customer.Login != "":
+		user, err = mh.userRepository.FindUserByLogin(ctx, customer.Login)
+	default:
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return nil, false
+	}
+
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			ctx.AbortWithError(http.StatusNotFound, err)
+			return nil, false
+		}
+
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	if user.IsDisabled() {
+		ctx.AbortWithError(http.StatusForbidden, repository.ErrUserDisabled)
+		return nil, false
+	}
+
+	return user, true
+}
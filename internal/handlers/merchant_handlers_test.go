@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/auth"
+	"github.com/rovany706/loyalty-gopher/internal/middleware"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/services"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type fakeUserRepository struct {
+	repository.UserRepository
+}
+
+func (r *fakeUserRepository) FindUserByLogin(_ context.Context, login string) (*models.User, error) {
+	return &models.User{ID: 1, Login: login}, nil
+}
+
+type fakeRedemptionCodeRepository struct {
+	repository.RedemptionCodeRepository
+	codeHashes map[string]bool
+}
+
+func (r *fakeRedemptionCodeRepository) UseRedemptionCode(_ context.Context, _ int, codeHash string) (bool, error) {
+	if !r.codeHashes[codeHash] {
+		return false, nil
+	}
+
+	delete(r.codeHashes, codeHash)
+	return true, nil
+}
+
+type fakeMFAService struct {
+	services.MFAService
+	enabled bool
+}
+
+func (s *fakeMFAService) IsEnabled(context.Context, int) (bool, error) {
+	return s.enabled, nil
+}
+
+// fakeWithdrawalRulesService refuses withdrawals above 500.
+type fakeWithdrawalRulesService struct {
+	services.WithdrawalRulesService
+}
+
+func (s *fakeWithdrawalRulesService) Withdraw(_ context.Context, _ int, _ string, sum decimal.Decimal, withdraw func(guard repository.WithdrawalGuard) error) error {
+	return withdraw(func(repository.WithdrawalTotalsFunc) error {
+		if sum.GreaterThan(decimal.NewFromInt(500)) {
+			return services.ErrWithdrawalAboveMaximum
+		}
+
+		return nil
+	})
+}
+
+// fakeFraudService throttles the listed clients and keeps the clients of recorded uploads.
+type fakeFraudService struct {
+	services.FraudService
+	throttled map[string]bool
+	recorded  []string
+}
+
+func (s *fakeFraudService) CheckOrderUpload(_ context.Context, _ int, client string) error {
+	if s.throttled[client] {
+		return services.ErrOrderUploadsThrottled
+	}
+
+	return nil
+}
+
+func (s *fakeFraudService) RecordOrderUploads(_ context.Context, _ int, client string, _ ...models.OrderBatchStatus) {
+	s.recorded = append(s.recorded, client)
+}
+
+func (r *fakePointsRepository) WithdrawPoints(_ context.Context, _ int, _ string, amount decimal.Decimal, guard repository.WithdrawalGuard) error {
+	if err := guard(nil); err != nil {
+		return err
+	}
+
+	if amount.GreaterThan(decimal.NewFromInt(700)) {
+		return repository.ErrNotEnoughPoints
+	}
+
+	return nil
+}
+
+func TestRedeemPoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	codes := &fakeRedemptionCodeRepository{codeHashes: map[string]bool{
+		auth.HashRedemptionCode("AAAAA-AAAAA"): true,
+		auth.HashRedemptionCode("BBBBB-BBBBB"): true,
+	}}
+	mh := NewMerchantHandlers(&fakeUserRepository{}, nil, &fakePointsRepository{}, codes, nil, &fakeMFAService{}, decimal.NewFromInt(1000), &fakeWithdrawalRulesService{}, &fakeFraudService{}, zap.NewNop())
+
+	r := gin.New()
+	r.POST("/api/merchant/redemptions", func(ctx *gin.Context) {
+		ctx.Set(middleware.APIKeyContextKey, &models.APIKey{MerchantID: 1})
+	}, mh.RedeemPointsHandler())
+
+	// the cases run in order, a code spent by one case is gone for the next
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"without code", `{"customer":{"login":"gopher"},"order":"2377225624","sum":100}`, http.StatusBadRequest},
+		{"unknown code", `{"customer":{"login":"gopher"},"code":"CCCCC-CCCCC","order":"2377225624","sum":100}`, http.StatusForbidden},
+		{"issued code", `{"customer":{"login":"gopher"},"code":"aaaaaaaaaa","order":"2377225624","sum":100}`, http.StatusOK},
+		{"spent code", `{"customer":{"login":"gopher"},"code":"AAAAA-AAAAA","order":"2377225624","sum":100}`, http.StatusForbidden},
+		{"refused by rules", `{"customer":{"login":"gopher"},"code":"BBBBB-BBBBB","order":"2377225624","sum":600}`, http.StatusUnprocessableEntity},
+		{"code spent by refused redemption", `{"customer":{"login":"gopher"},"code":"BBBBB-BBBBB","order":"2377225624","sum":100}`, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/merchant/redemptions", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestPostCustomerOrderScoredPerAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	fraud := &fakeFraudService{throttled: map[string]bool{models.FraudAPIKeyClient(2): true}}
+	mh := NewMerchantHandlers(&fakeUserRepository{}, &fakeOrderRepository{}, nil, nil, &fakeAccrualService{}, nil, decimal.Zero, nil, fraud, zap.NewNop())
+
+	r := gin.New()
+	r.POST("/api/merchant/orders", func(ctx *gin.Context) {
+		apiKeyID, _ := strconv.Atoi(ctx.GetHeader("X-API-Key-ID"))
+		ctx.Set(middleware.APIKeyContextKey, &models.APIKey{ID: apiKeyID, MerchantID: 1})
+	}, mh.PostCustomerOrderHandler())
+
+	tests := []struct {
+		name       string
+		apiKeyID   string
+		wantStatus int
+	}{
+		{"new order", "1", http.StatusAccepted},
+		{"throttled key", "2", http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/merchant/orders", strings.NewReader(`{"customer":{"login":"gopher"},"order":"2377225624"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-API-Key-ID", tt.apiKeyID)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+
+	assert.Equal(t, []string{models.FraudAPIKeyClient(1)}, fraud.recorded)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/helpers"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/services"
+)
+
+type MFAHandlers struct {
+	mfaService services.MFAService
+}
+
+func NewMFAHandlers(mfaService services.MFAService) *MFAHandlers {
+	return &MFAHandlers{
+		mfaService: mfaService,
+	}
+}
+
+func (mh *MFAHandlers) EnrollTOTPHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		enrollment, err := mh.mfaService.BeginEnrollment(ctx, userID)
+		if err != nil {
+			abortWithMFAError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, enrollment)
+	}
+}
+
+func (mh *MFAHandlers) ConfirmTOTPHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var request models.TOTPCodeRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			ctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		recoveryCodes, err := mh.mfaService.ConfirmEnrollment(ctx, userID, request.Code)
+		if err != nil {
+			abortWithMFAError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, models.RecoveryCodesResponse{
+			RecoveryCodes: recoveryCodes,
+		})
+	}
+}
+
+func (mh *MFAHandlers) DisableTOTPHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var request models.DisableTOTPRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			ctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		if err := mh.mfaService.Disable(ctx, userID, request.Code, request.RecoveryCode); err != nil {
+			abortWithMFAError(ctx, err)
+			return
+		}
+
+		ctx.Status(http.StatusOK)
+	}
+}
+
+func abortWithMFAError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidMFACode):
+		ctx.AbortWithError(http.StatusForbidden, err)
+	case errors.Is(err, repository.ErrMFAAlreadyEnabled), errors.Is(err, repository.ErrMFANotEnrolled):
+		ctx.AbortWithError(http.StatusConflict, err)
+	default:
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+	}
+}
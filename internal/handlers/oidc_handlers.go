@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/auth"
+	"github.com/rovany706/loyalty-gopher/internal/helpers"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/services"
+)
+
+const (
+	oidcLoginCookie     = "oidc_login"
+	oidcLoginCookiePath = "/api/user/oidc/callback"
+)
+
+type OIDCHandlers struct {
+	authHandlers *AuthHandlers
+	oidcService  services.OIDCService
+}
+
+func NewOIDCHandlers(ah *AuthHandlers, oidcService services.OIDCService) *OIDCHandlers {
+	return &OIDCHandlers{
+		authHandlers: ah,
+		oidcService:  oidcService,
+	}
+}
+
+func (oh *OIDCHandlers) LoginHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		authURL, loginToken, err := oh.oidcService.BeginLogin(ctx, 0)
+		if err != nil {
+			abortWithOIDCError(ctx, err)
+			return
+		}
+
+		oh.setLoginCookie(ctx, loginToken, int(auth.OIDCLoginExpiryTime.Seconds()))
+		ctx.Redirect(http.StatusFound, authURL)
+	}
+}
+
+// LinkHandler starts the flow for an already signed in user. The frontend
+// sends the browser to the returned URL, the login cookie set here makes
+// sure the identity is linked only when that same browser comes back.
+func (oh *OIDCHandlers) LinkHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		authURL, loginToken, err := oh.oidcService.BeginLogin(ctx, userID)
+		if err != nil {
+			abortWithOIDCError(ctx, err)
+			return
+		}
+
+		oh.setLoginCookie(ctx, loginToken, int(auth.OIDCLoginExpiryTime.Seconds()))
+		ctx.JSON(http.StatusOK, models.OIDCAuthorizationResponse{AuthorizationURL: authURL})
+	}
+}
+
+func (oh *OIDCHandlers) CallbackHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		loginToken, err := ctx.Cookie(oidcLoginCookie)
+		if err != nil {
+			abortWithOIDCError(ctx, services.ErrInvalidOIDCState)
+			return
+		}
+
+		// the login token is good for one callback only
+		oh.setLoginCookie(ctx, "", -1)
+
+		if ctx.Query("error") != "" {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		result, err := oh.oidcService.CompleteLogin(ctx, loginToken, ctx.Query("state"), ctx.Query("code"))
+		if err != nil {
+			abortWithOIDCError(ctx, err)
+			return
+		}
+
+		if result.Linked {
+			ctx.Status(http.StatusNoContent)
+			return
+		}
+
+		oh.authHandlers.signIn(ctx, result.UserID)
+	}
+}
+
+// setLoginCookie keeps the login token away from scripts. Lax lets the browser
+// send it on the top-level redirect back from the identity provider.
+func (oh *OIDCHandlers) setLoginCookie(ctx *gin.Context, loginToken string, maxAge int) {
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     oidcLoginCookie,
+		Value:    loginToken,
+		Path:     oidcLoginCookiePath,
+		MaxAge:   maxAge,
+		Secure:   oh.authHandlers.cookies.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func abortWithOIDCError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidOIDCState):
+		ctx.AbortWithError(http.StatusBadRequest, err)
+	case errors.Is(err, auth.ErrOIDCExchange), errors.Is(err, auth.ErrInvalidIDToken):
+		ctx.AbortWithError(http.StatusUnauthorized, err)
+	case errors.Is(err, services.ErrOIDCUserNotLinked):
+		ctx.AbortWithError(http.StatusForbidden, err)
+	case errors.Is(err, repository.ErrIdentityConflict), errors.Is(err, repository.ErrUserConfict):
+		ctx.AbortWithError(http.StatusConflict, err)
+	case errors.Is(err, services.ErrOIDCNoLogin):
+		ctx.AbortWithError(http.StatusUnprocessableEntity, err)
+	case errors.Is(err, auth.ErrOIDCDiscovery):
+		ctx.AbortWithError(http.StatusBadGateway, err)
+	default:
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/auth"
+	"github.com/rovany706/loyalty-gopher/internal/middleware"
+	"github.com/rovany706/loyalty-gopher/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOIDCService completes logins presenting the "issued" login token.
+type fakeOIDCService struct {
+	services.OIDCService
+}
+
+func (s *fakeOIDCService) CompleteLogin(_ context.Context, loginToken string, _ string, _ string) (*services.OIDCResult, error) {
+	if loginToken != "issued" {
+		return nil, services.ErrInvalidOIDCState
+	}
+
+	return &services.OIDCResult{UserID: 1}, nil
+}
+
+func TestOIDCCallback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		loginCookie string
+		mfaEnabled  bool
+		wantStatus  int
+	}{
+		{"without login cookie", "", false, http.StatusBadRequest},
+		{"foreign login cookie", "forged", false, http.StatusBadRequest},
+		{"2FA enabled", "issued", true, http.StatusAccepted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tm, err := auth.NewJWTTokenManager([]byte("secret"))
+			require.NoError(t, err)
+
+			ah := NewAuthHandlers(&fakeUserRepository{}, nil, tm, nil, &fakeMFAService{enabled: tt.mfaEnabled}, middleware.CookieSettings{})
+			r := gin.New()
+			r.GET(oidcLoginCookiePath, NewOIDCHandlers(ah, &fakeOIDCService{}).CallbackHandler())
+
+			req := httptest.NewRequest(http.MethodGet, oidcLoginCookiePath+"?state=state&code=code", nil)
+			if tt.loginCookie != "" {
+				req.AddCookie(&http.Cookie{Name: oidcLoginCookie, Value: tt.loginCookie})
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.loginCookie != "" {
+				cookies := w.Result().Cookies()
+				require.Len(t, cookies, 1)
+				assert.Equal(t, oidcLoginCookie, cookies[0].Name)
+				assert.Negative(t, cookies[0].MaxAge)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/events"
+	"github.com/rovany706/loyalty-gopher/internal/helpers"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+)
+
+const (
+	orderEventsHeartbeatInterval = 15 * time.Second
+	orderEventsRetry             = 3 * time.Second
+	orderEventName               = "order"
+)
+
+type OrderEventHandlers struct {
+	hub *events.Hub
+}
+
+func NewOrderEventHandlers(hub *events.Hub) *OrderEventHandlers {
+	return &OrderEventHandlers{
+		hub: hub,
+	}
+}
+
+// OrderEventsHandler streams the user's order changes as Server-Sent Events in
+// the same shape as GET /api/user/orders. Comments are sent as heartbeats so
+// proxies keep the connection open.
+func (oh *OrderEventHandlers) OrderEventsHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		// a malformed id cannot be resumed from, so the stream starts afresh
+		lastEventID, _ := strconv.ParseUint(ctx.GetHeader("Last-Event-ID"), 10, 64)
+
+		sub, missed := oh.hub.Subscribe(userID, lastEventID)
+		defer oh.hub.Unsubscribe(sub)
+
+		ctx.Header("Content-Type", "text/event-stream")
+		ctx.Header("Cache-Control", "no-cache")
+		ctx.Header("Connection", "keep-alive")
+		ctx.Header("X-Accel-Buffering", "no")
+		ctx.Status(http.StatusOK)
+
+		if _, err := fmt.Fprintf(ctx.Writer, "retry: %d\n\n", orderEventsRetry.Milliseconds()); err != nil {
+			return
+		}
+
+		for _, event := range missed {
+			if err := writeOrderEvent(ctx.Writer, event); err != nil {
+				return
+			}
+		}
+		ctx.Writer.Flush()
+
+		heartbeat := time.NewTicker(orderEventsHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			var err error
+			select {
+			case <-ctx.Request.Context().Done():
+				return
+			case event, ok := <-sub.C:
+				if !ok {
+					// dropped for falling behind, the client resumes with Last-Event-ID
+					return
+				}
+				err = writeOrderEvent(ctx.Writer, event)
+			case <-heartbeat.C:
+				_, err = io.WriteString(ctx.Writer, ": heartbeat\n\n")
+			}
+
+			if err != nil {
+				return
+			}
+			ctx.Writer.Flush()
+		}
+	}
+}
+
+func writeOrderEvent(w io.Writer, event events.OrderEvent) error {
+	order := event.Order
+	if order.Accrual != nil && order.Accrual.IsZero() {
+		order.Accrual = nil
+	}
+
+	if order.AccrualStatus == models.AccrualStatusRegistered {
+		order.AccrualStatus = models.AccrualStatusNew
+	}
+
+	data, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, orderEventName, data)
+
+	return err
+}
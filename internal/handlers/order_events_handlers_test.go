@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/events"
+	"github.com/rovany706/loyalty-gopher/internal/middleware"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderEventsHandler(t *testing.T) {
+	decimal.MarshalJSONWithoutQuotes = true
+	gin.SetMode(gin.TestMode)
+
+	accrual := decimal.NewFromInt(500)
+	hub := events.NewHub()
+	hub.PublishOrderEvent(1, models.Order{OrderNum: "12345678903", AccrualStatus: models.AccrualStatusRegistered, Accrual: &decimal.Zero})
+	hub.PublishOrderEvent(2, models.Order{OrderNum: "2377225624", AccrualStatus: models.AccrualStatusProcessing, Accrual: &decimal.Zero})
+	hub.PublishOrderEvent(1, models.Order{OrderNum: "12345678903", AccrualStatus: models.AccrualStatusProcessed, Accrual: &accrual})
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		wantBody    string
+	}{
+		{
+			"new stream",
+			"",
+			"retry: 3000\n\n",
+		},
+		{
+			"resume",
+			"1",
+			"retry: 3000\n\nid: 3\nevent: order\ndata: {\"number\":\"12345678903\",\"uploaded_at\":\"0001-01-01T00:00:00Z\",\"status\":\"PROCESSED\",\"accrual\":500}\n\n",
+		},
+		{
+			"resume with malformed id",
+			"abc",
+			"retry: 3000\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/events", func(ctx *gin.Context) {
+				ctx.Set(middleware.UserIDContextKey, 1)
+			}, NewOrderEventHandlers(hub).OrderEventsHandler())
+
+			// a cancelled request ends the stream right after the replay
+			requestCtx, cancel := context.WithCancel(context.Background())
+			cancel()
+			req := httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(requestCtx)
+			if tt.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+			assert.Equal(t, tt.wantBody, w.Body.String())
+		})
+	}
+}
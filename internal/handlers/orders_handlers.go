@@ -1,25 +1,45 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/export"
 	"github.com/rovany706/loyalty-gopher/internal/helpers"
 	"github.com/rovany706/loyalty-gopher/internal/models"
 	"github.com/rovany706/loyalty-gopher/internal/repository"
 	"github.com/rovany706/loyalty-gopher/internal/services"
+	"github.com/rovany706/loyalty-gopher/internal/validation"
 )
 
+// uploadOutcomes name the statuses of addUserOrder for fraud scoring.
+var uploadOutcomes = map[int]models.OrderBatchStatus{
+	http.StatusAccepted:            models.OrderBatchStatusAccepted,
+	http.StatusOK:                  models.OrderBatchStatusDuplicateOwn,
+	http.StatusConflict:            models.OrderBatchStatusConflict,
+	http.StatusUnprocessableEntity: models.OrderBatchStatusInvalid,
+}
+
 type OrderHandlers struct {
 	orderRepository repository.OrderRepository
 	accrualService  services.AccrualService
+	fraudService    services.FraudService
+	maxBatchSize    int
 }
 
-func NewOrderHandlers(orderRepository repository.OrderRepository, accrualService services.AccrualService) *OrderHandlers {
+func NewOrderHandlers(orderRepository repository.OrderRepository, accrualService services.AccrualService, fraudService services.FraudService, maxBatchSize int) *OrderHandlers {
 	return &OrderHandlers{
 		orderRepository: orderRepository,
 		accrualService:  accrualService,
+		fraudService:    fraudService,
+		maxBatchSize:    maxBatchSize,
 	}
 }
 
@@ -33,47 +53,177 @@ func (oh *OrderHandlers) PostNewOrderHandler() gin.HandlerFunc {
 
 		orderNum := string(body)
 
-		if ok := helpers.LuhnCheck(orderNum); !ok {
-			ctx.AbortWithStatus(http.StatusUnprocessableEntity)
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
 
+		status, err := uploadUserOrder(ctx, oh.orderRepository, oh.accrualService, oh.fraudService, userID, orderNum)
+		if err != nil {
+			ctx.AbortWithError(status, err)
+			return
+		}
+
+		ctx.Status(status)
+	}
+}
+
+// PostOrderBatchHandler accepts a JSON array or a newline-delimited list of order
+// numbers and reports the outcome for each of them in request order.
+func (oh *OrderHandlers) PostOrderBatchHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
 		userID, ok := helpers.GetUserIDFromContext(ctx)
 		if !ok {
 			ctx.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
 
-		existingOrder, err := oh.orderRepository.GetOrder(ctx, orderNum)
+		orderNums, err := readOrderBatch(ctx)
 		if err != nil {
-			ctx.AbortWithError(http.StatusInternalServerError, err)
+			abortWithMalformedBody(ctx)
 			return
 		}
 
-		if existingOrder != nil {
-			if existingOrder.UserID == userID {
-				ctx.Status(http.StatusOK)
-				return
-			} else {
-				ctx.Status(http.StatusConflict)
+		if len(orderNums) == 0 || len(orderNums) > oh.maxBatchSize {
+			abortWithValidationErrors(ctx, validation.Errors{{
+				Field:   validation.FieldBody,
+				Code:    validation.CodeOutOfRange,
+				Message: fmt.Sprintf("must contain from 1 to %d order numbers", oh.maxBatchSize),
+			}})
+			return
+		}
+
+		if err := oh.fraudService.CheckOrderUpload(ctx, userID, ctx.ClientIP()); err != nil {
+			ctx.AbortWithError(http.StatusTooManyRequests, err)
+			return
+		}
+
+		results := make([]models.OrderBatchResult, len(orderNums))
+		validNums := make([]string, 0, len(orderNums))
+		validPositions := make([]int, 0, len(orderNums))
+		for i, orderNum := range orderNums {
+			results[i] = models.OrderBatchResult{Number: orderNum, Status: models.OrderBatchStatusInvalid}
+			if helpers.LuhnCheck(orderNum) {
+				validNums = append(validNums, orderNum)
+				validPositions = append(validPositions, i)
+			}
+		}
+
+		if len(validNums) > 0 {
+			added, err := oh.orderRepository.AddOrders(ctx, userID, validNums)
+			if err != nil {
+				ctx.AbortWithError(http.StatusInternalServerError, err)
 				return
 			}
+
+			for i, result := range added {
+				results[validPositions[i]] = result
+
+				if result.Status == models.OrderBatchStatusAccepted {
+					oh.accrualService.QueueStatusUpdate(models.Order{
+						UserID:        userID,
+						OrderNum:      result.Number,
+						AccrualStatus: models.AccrualStatusRegistered,
+					})
+				}
+			}
 		}
 
-		err = oh.orderRepository.AddOrder(ctx, userID, orderNum)
-		if err != nil {
-			ctx.AbortWithError(http.StatusInternalServerError, err)
-			return
+		outcomes := make([]models.OrderBatchStatus, len(results))
+		for i, result := range results {
+			outcomes[i] = result.Status
+		}
+		oh.fraudService.RecordOrderUploads(ctx, userID, ctx.ClientIP(), outcomes...)
+
+		ctx.JSON(http.StatusOK, models.OrderBatchResponse{Results: results})
+	}
+}
+
+func readOrderBatch(ctx *gin.Context) ([]string, error) {
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if ctx.ContentType() == gin.MIMEJSON {
+		var orderNums []string
+		if err := json.Unmarshal(body, &orderNums); err != nil {
+			return nil, err
+		}
+
+		return orderNums, nil
+	}
+
+	orderNums := make([]string, 0)
+	for _, line := range strings.Split(string(body), "\n") {
+		if orderNum := strings.TrimSpace(line); orderNum != "" {
+			orderNums = append(orderNums, orderNum)
+		}
+	}
+
+	return orderNums, nil
+}
+
+// uploadUserOrder is addUserOrder for uploads made by users themselves, which
+// are refused while the user or the client IP is throttled and are scored for fraud.
+func uploadUserOrder(ctx *gin.Context, orderRepository repository.OrderRepository, accrualService services.AccrualService, fraudService services.FraudService, userID int, orderNum string) (int, error) {
+	return uploadOrder(ctx, orderRepository, accrualService, fraudService, userID, ctx.ClientIP(), orderNum)
+}
+
+// uploadOrder is addUserOrder refused while the user or the client of the upload
+// is throttled, scoring the upload for fraud.
+func uploadOrder(ctx context.Context, orderRepository repository.OrderRepository, accrualService services.AccrualService, fraudService services.FraudService, userID int, client string, orderNum string) (int, error) {
+	if err := fraudService.CheckOrderUpload(ctx, userID, client); err != nil {
+		return http.StatusTooManyRequests, err
+	}
+
+	status, err := addUserOrder(ctx, orderRepository, accrualService, userID, orderNum)
+	if outcome, ok := uploadOutcomes[status]; ok {
+		fraudService.RecordOrderUploads(ctx, userID, client, outcome)
+	}
+
+	return status, err
+}
+
+// addUserOrder uploads an order on behalf of the user and returns the status code
+// describing the outcome: 202 for a new order, 200 if the user already uploaded it,
+// 409 if another user did and 422 if the number fails the Luhn check. Failures
+// come with the error to abort with.
+func addUserOrder(ctx context.Context, orderRepository repository.OrderRepository, accrualService services.AccrualService, userID int, orderNum string) (int, error) {
+	if ok := helpers.LuhnCheck(orderNum); !ok {
+		return http.StatusUnprocessableEntity, helpers.ErrInvalidOrderNumber
+	}
+
+	existingOrder, err := orderRepository.GetOrder(ctx, orderNum)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if existingOrder != nil {
+		if existingOrder.UserID == userID {
+			return http.StatusOK, nil
 		}
 
-		oh.accrualService.QueueStatusUpdate(models.Order{
-			UserID:        userID,
-			OrderNum:      orderNum,
-			AccrualStatus: models.AccrualStatusRegistered,
-		})
+		return http.StatusConflict, repository.ErrOrderConflict
+	}
 
-		ctx.Status(http.StatusAccepted)
+	err = orderRepository.AddOrder(ctx, userID, orderNum)
+	if err != nil {
+		if errors.Is(err, repository.ErrOrderConflict) {
+			return http.StatusConflict, err
+		}
+
+		return http.StatusInternalServerError, err
 	}
+
+	accrualService.QueueStatusUpdate(models.Order{
+		UserID:        userID,
+		OrderNum:      orderNum,
+		AccrualStatus: models.AccrualStatusRegistered,
+	})
+
+	return http.StatusAccepted, nil
 }
 
 func (oh *OrderHandlers) GetUserOrdersHandler() gin.HandlerFunc {
@@ -84,6 +234,15 @@ func (oh *OrderHandlers) GetUserOrdersHandler() gin.HandlerFunc {
 			return
 		}
 
+		if format := exportFormat(ctx); format != "" {
+			writeExport(ctx, format, "orders", []string{"number", "status", "accrual", "uploaded_at"}, func(w export.Writer) error {
+				return oh.orderRepository.StreamUserOrders(ctx, userID, func(order models.Order) error {
+					return w.WriteRow(order.OrderNum, string(order.AccrualStatus), order.Accrual, time.Time(order.UploadedAt))
+				})
+			})
+			return
+		}
+
 		orders, err := oh.orderRepository.GetUserOrders(ctx, userID)
 
 		if err != nil {
@@ -96,10 +255,35 @@ func (oh *OrderHandlers) GetUserOrdersHandler() gin.HandlerFunc {
 			return
 		}
 
-		for _, order := range orders {
-			oh.accrualService.QueueStatusUpdate(order)
+		ctx.JSON(http.StatusOK, orders)
+	}
+}
+
+// QueuePendingOrdersHandler goes ahead of ConditionalGET on the order lists.
+func (oh *OrderHandlers) QueuePendingOrdersHandler() gin.HandlerFunc {
+	return queuePendingOrders(oh.orderRepository, oh.accrualService)
+}
+
+// queuePendingOrders asks the accrual system about every order of the user it has
+// not finished with. Polling the list is what drives these lookups, and the data
+// version only changes with a status, so a client revalidating its copy would get
+// 304 forever if the lookups waited for the list to be read.
+func queuePendingOrders(orderRepository repository.OrderRepository, accrualService services.AccrualService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
 		}
 
-		ctx.JSON(http.StatusOK, orders)
+		orders, err := orderRepository.GetUserPendingOrders(ctx, userID)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		for _, order := range orders {
+			accrualService.QueueStatusUpdate(order)
+		}
 	}
 }
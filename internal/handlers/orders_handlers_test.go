@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/middleware"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/services"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeOrderRepository struct {
+	repository.OrderRepository
+}
+
+func (r *fakeOrderRepository) GetOrder(context.Context, string) (*models.Order, error) {
+	return nil, nil
+}
+
+func (r *fakeOrderRepository) AddOrder(context.Context, int, string) error {
+	return nil
+}
+
+func (r *fakeOrderRepository) GetUserPendingOrders(_ context.Context, userID int) ([]models.Order, error) {
+	return []models.Order{{OrderNum: "2377225624", UserID: userID, AccrualStatus: models.AccrualStatusProcessing}}, nil
+}
+
+type fakeAccrualService struct {
+	services.AccrualService
+	queued []string
+}
+
+func (s *fakeAccrualService) QueueStatusUpdate(order models.Order) {
+	s.queued = append(s.queued, order.OrderNum)
+}
+
+type fakeDataVersionStore struct{}
+
+func (s *fakeDataVersionStore) GetDataVersion(context.Context, int) (models.DataVersion, error) {
+	return models.DataVersion{Version: 1, ModifiedAt: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}, nil
+}
+
+func TestNotModifiedOrdersStillQueuePending(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	accrual := &fakeAccrualService{}
+	oh := NewOrderHandlers(&fakeOrderRepository{}, accrual, nil, 0)
+
+	r := gin.New()
+	r.GET("/api/user/orders", func(ctx *gin.Context) {
+		ctx.Set(middleware.UserIDContextKey, 1)
+	}, oh.QueuePendingOrdersHandler(), middleware.ConditionalGET(&fakeDataVersionStore{}), oh.GetUserOrdersHandler())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/orders", nil)
+	req.Header.Set("If-Modified-Since", time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC).Format(http.TimeFormat))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, []string{"2377225624"}, accrual.queued)
+}
@@ -3,21 +3,32 @@ package handlers
 import (
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/export"
 	"github.com/rovany706/loyalty-gopher/internal/helpers"
 	"github.com/rovany706/loyalty-gopher/internal/models"
 	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/services"
 	"github.com/shopspring/decimal"
 )
 
+const totpCodeHeader = "X-TOTP-Code"
+
 type PointsHandlers struct {
-	pointsRepository repository.PointsRepository
+	pointsRepository     repository.PointsRepository
+	mfaService           services.MFAService
+	mfaWithdrawThreshold decimal.Decimal
+	withdrawalRules      services.WithdrawalRulesService
 }
 
-func NewPointsHandlers(pr repository.PointsRepository) *PointsHandlers {
+func NewPointsHandlers(pr repository.PointsRepository, mfa services.MFAService, mfaWithdrawThreshold decimal.Decimal, wr services.WithdrawalRulesService) *PointsHandlers {
 	return &PointsHandlers{
-		pointsRepository: pr,
+		pointsRepository:     pr,
+		mfaService:           mfa,
+		mfaWithdrawThreshold: mfaWithdrawThreshold,
+		withdrawalRules:      wr,
 	}
 }
 
@@ -29,30 +40,12 @@ func (ph *PointsHandlers) UserBalanceHandler() gin.HandlerFunc {
 			return
 		}
 
-		balance, err := ph.pointsRepository.GetUserBalance(ctx, userID)
-
-		if err != nil {
-			ctx.AbortWithError(http.StatusInternalServerError, err)
-			return
-		}
-
-		withdrawalHistory, err := ph.pointsRepository.GetUserWithdrawalHistory(ctx, userID)
-
+		response, err := getUserBalance(ctx, ph.pointsRepository, userID)
 		if err != nil {
 			ctx.AbortWithError(http.StatusInternalServerError, err)
 			return
 		}
 
-		sum := decimal.Zero
-		for _, entry := range withdrawalHistory {
-			sum = sum.Add(entry.WithdrawSum)
-		}
-
-		response := models.GetUserBalanceResponse{
-			Current:   balance,
-			Withdrawn: sum,
-		}
-
 		ctx.JSON(http.StatusOK, response)
 	}
 }
@@ -72,14 +65,13 @@ func (ph *PointsHandlers) WithdrawPointsHandler() gin.HandlerFunc {
 		}
 
 		if ok := helpers.LuhnCheck(request.OrderNum); !ok {
-			ctx.AbortWithStatus(http.StatusUnprocessableEntity)
+			ctx.AbortWithError(http.StatusUnprocessableEntity, helpers.ErrInvalidOrderNumber)
 			return
 		}
 
-		err := ph.pointsRepository.WithdrawPoints(ctx, userID, request.OrderNum, request.WithdrawSum)
-		if err != nil {
-			if errors.Is(err, repository.ErrNotEnoughPoints) {
-				ctx.AbortWithStatus(http.StatusPaymentRequired)
+		if err := checkWithdrawMFA(ctx, ph.mfaService, ph.mfaWithdrawThreshold, userID, request.WithdrawSum); err != nil {
+			if errors.Is(err, services.ErrInvalidMFACode) {
+				ctx.AbortWithError(http.StatusForbidden, err)
 				return
 			}
 
@@ -87,10 +79,44 @@ func (ph *PointsHandlers) WithdrawPointsHandler() gin.HandlerFunc {
 			return
 		}
 
+		err := ph.withdrawalRules.Withdraw(ctx, userID, request.OrderNum, request.WithdrawSum, func(guard repository.WithdrawalGuard) error {
+			return ph.pointsRepository.WithdrawPoints(ctx, userID, request.OrderNum, request.WithdrawSum, guard)
+		})
+		if err != nil {
+			abortWithWithdrawalError(ctx, err)
+			return
+		}
+
 		ctx.Status(http.StatusOK)
 	}
 }
 
+func abortWithWithdrawalError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrWithdrawalNotPositive), errors.Is(err, services.ErrWithdrawalRefused):
+		ctx.AbortWithError(http.StatusUnprocessableEntity, err)
+	case errors.Is(err, repository.ErrNotEnoughPoints):
+		ctx.AbortWithError(http.StatusPaymentRequired, err)
+	default:
+		ctx.AbortWithError(http.StatusInternalServerError, err)
+	}
+}
+
+// checkWithdrawMFA requires a fresh TOTP code in the X-TOTP-Code header
+// for withdrawals above the threshold made by users with 2FA enabled.
+func checkWithdrawMFA(ctx *gin.Context, mfaService services.MFAService, threshold decimal.Decimal, userID int, amount decimal.Decimal) error {
+	if !amount.GreaterThan(threshold) {
+		return nil
+	}
+
+	mfaEnabled, err := mfaService.IsEnabled(ctx, userID)
+	if err != nil || !mfaEnabled {
+		return err
+	}
+
+	return mfaService.VerifyTOTP(ctx, userID, ctx.GetHeader(totpCodeHeader))
+}
+
 func (ph *PointsHandlers) GetUserWithdrawalHistory() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		userID, ok := helpers.GetUserIDFromContext(ctx)
@@ -99,6 +125,15 @@ func (ph *PointsHandlers) GetUserWithdrawalHistory() gin.HandlerFunc {
 			return
 		}
 
+		if format := exportFormat(ctx); format != "" {
+			writeExport(ctx, format, "withdrawals", []string{"order", "sum", "processed_at"}, func(w export.Writer) error {
+				return ph.pointsRepository.StreamUserWithdrawals(ctx, userID, func(entry models.WithdrawHistoryEntry) error {
+					return w.WriteRow(entry.OrderNum, entry.WithdrawSum, time.Time(entry.ProcessedAt))
+				})
+			})
+			return
+		}
+
 		withdrawalHistory, err := ph.pointsRepository.GetUserWithdrawalHistory(ctx, userID)
 
 		if err != nil {
@@ -114,3 +149,25 @@ func (ph *PointsHandlers) GetUserWithdrawalHistory() gin.HandlerFunc {
 		ctx.JSON(http.StatusOK, withdrawalHistory)
 	}
 }
+
+func getUserBalance(ctx *gin.Context, pointsRepository repository.PointsRepository, userID int) (models.GetUserBalanceResponse, error) {
+	balance, err := pointsRepository.GetUserBalance(ctx, userID)
+	if err != nil {
+		return models.GetUserBalanceResponse{}, err
+	}
+
+	withdrawalHistory, err := pointsRepository.GetUserWithdrawalHistory(ctx, userID)
+	if err != nil {
+		return models.GetUserBalanceResponse{}, err
+	}
+
+	sum := decimal.Zero
+	for _, entry := range withdrawalHistory {
+		sum = sum.Add(entry.WithdrawSum)
+	}
+
+	return models.GetUserBalanceResponse{
+		Current:   balance,
+		Withdrawn: sum,
+	}, nil
+}
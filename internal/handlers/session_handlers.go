@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/helpers"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+)
+
+type SessionHandlers struct {
+	sessionRepository repository.SessionRepository
+}
+
+func NewSessionHandlers(sr repository.SessionRepository) *SessionHandlers {
+	return &SessionHandlers{
+		sessionRepository: sr,
+	}
+}
+
+func (sh *SessionHandlers) GetUserSessionsHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		currentSessionID, _ := helpers.GetSessionIDFromContext(ctx)
+
+		sessions, err := sh.sessionRepository.GetUserSessions(ctx, userID)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		for i := range sessions {
+			sessions[i].Current = sessions[i].ID == currentSessionID
+		}
+
+		ctx.JSON(http.StatusOK, sessions)
+	}
+}
+
+func (sh *SessionHandlers) RevokeSessionHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		sessionID, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			ctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		err = sh.sessionRepository.RevokeSession(ctx, userID, sessionID)
+		if err != nil {
+			if errors.Is(err, repository.ErrSessionNotFound) {
+				ctx.AbortWithError(http.StatusNotFound, err)
+				return
+			}
+
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/helpers"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/services"
+	"github.com/rovany706/loyalty-gopher/internal/statements"
+	"github.com/rovany706/loyalty-gopher/internal/validation"
+)
+
+const fieldMonth = "month"
+
+type StatementHandlers struct {
+	statementService services.StatementService
+}
+
+func NewStatementHandlers(ss services.StatementService) *StatementHandlers {
+	return &StatementHandlers{
+		statementService: ss,
+	}
+}
+
+func (sh *StatementHandlers) ListStatementsHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		periods, err := sh.statementService.ListStatementPeriods(ctx, userID)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		if len(periods) == 0 {
+			ctx.Status(http.StatusNoContent)
+			return
+		}
+
+		response := make([]models.StatementPeriod, 0, len(periods))
+		for _, period := range periods {
+			response = append(response, models.StatementPeriod{Month: period.Format(statements.MonthLayout)})
+		}
+
+		ctx.JSON(http.StatusOK, response)
+	}
+}
+
+// GetStatementHandler sends the statement of a completed month as HTML, or as PDF
+// when requested with "Accept: application/pdf".
+func (sh *StatementHandlers) GetStatementHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		month := ctx.Param(fieldMonth)
+		periodStart, err := time.Parse(statements.MonthLayout, month)
+		if err != nil {
+			abortWithValidationErrors(ctx, validation.Errors{{
+				Field:   fieldMonth,
+				Code:    validation.CodeInvalidFormat,
+				Message: "must be a month in YYYY-MM format",
+			}})
+			return
+		}
+
+		document, err := sh.statementService.GetStatement(ctx, userID, periodStart)
+		if err != nil {
+			if errors.Is(err, services.ErrStatementPeriodOpen) {
+				abortWithValidationErrors(ctx, validation.Errors{{
+					Field:   fieldMonth,
+					Code:    validation.CodeOutOfRange,
+					Message: "must be a month that has already ended",
+				}})
+				return
+			}
+
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		contentType, content, extension := statements.ContentTypeHTML, document.HTML, "html"
+		if ctx.NegotiateFormat(statements.ContentTypeHTML, statements.ContentTypePDF) == statements.ContentTypePDF {
+			contentType, content, extension = statements.ContentTypePDF, document.PDF, "pdf"
+		}
+
+		ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="statement-%s.%s"`, month, extension))
+		ctx.Data(http.StatusOK, contentType, content)
+	}
+}
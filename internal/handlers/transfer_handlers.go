@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/helpers"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/services"
+	"github.com/shopspring/decimal"
+)
+
+type TransferHandlers struct {
+	transferService      services.TransferService
+	mfaService           services.MFAService
+	mfaWithdrawThreshold decimal.Decimal
+}
+
+func NewTransferHandlers(ts services.TransferService, mfa services.MFAService, mfaWithdrawThreshold decimal.Decimal) *TransferHandlers {
+	return &TransferHandlers{
+		transferService:      ts,
+		mfaService:           mfa,
+		mfaWithdrawThreshold: mfaWithdrawThreshold,
+	}
+}
+
+// CreateTransferHandler asks for a TOTP code above the same threshold as withdrawals,
+// a transfer moves points out of the account just as well.
+func (th *TransferHandlers) CreateTransferHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var request models.CreateTransferRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			ctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		if err := checkWithdrawMFA(ctx, th.mfaService, th.mfaWithdrawThreshold, userID, request.Sum); err != nil {
+			if errors.Is(err, services.ErrInvalidMFACode) {
+				ctx.AbortWithError(http.StatusForbidden, err)
+				return
+			}
+
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		transfer, err := th.transferService.Transfer(ctx, userID, request)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrNotEnoughPoints):
+				ctx.AbortWithError(http.StatusPaymentRequired, err)
+			case errors.Is(err, services.ErrInvalidTransferSum), errors.Is(err, repository.ErrRecipientNotFound),
+				errors.Is(err, repository.ErrTransferToSelf), errors.Is(err, repository.ErrDailyTransferLimit):
+				ctx.AbortWithError(http.StatusUnprocessableEntity, err)
+			default:
+				ctx.AbortWithError(http.StatusInternalServerError, err)
+			}
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, transfer)
+	}
+}
+
+func (th *TransferHandlers) ListTransfersHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		transfers, err := th.transferService.GetTransfers(ctx, userID)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		if len(transfers) == 0 {
+			ctx.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, transfers)
+	}
+}
+
+func (th *TransferHandlers) AcceptTransferHandler() gin.HandlerFunc {
+	return resolveTransferHandler(th.transferService.Accept)
+}
+
+func (th *TransferHandlers) DeclineTransferHandler() gin.HandlerFunc {
+	return resolveTransferHandler(th.transferService.Decline)
+}
+
+func (th *TransferHandlers) CancelTransferHandler() gin.HandlerFunc {
+	return resolveTransferHandler(th.transferService.Cancel)
+}
+
+func resolveTransferHandler(resolve func(ctx context.Context, userID int, transferID int) (*models.Transfer, error)) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		transferID, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			ctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		transfer, err := resolve(ctx, userID, transferID)
+		if err != nil {
+			if errors.Is(err, repository.ErrTransferNotFound) {
+				ctx.AbortWithError(http.StatusNotFound, err)
+				return
+			}
+
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, transfer)
+	}
+}
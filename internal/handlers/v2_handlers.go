@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/helpers"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/services"
+	"github.com/rovany706/loyalty-gopher/internal/validation"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// V2Handlers serve /api/v2. Unlike /api/user they take JSON bodies, wrap
+// resources in envelopes and paginate lists, so they never return 204.
+type V2Handlers struct {
+	orderRepository      repository.OrderRepository
+	pointsRepository     repository.PointsRepository
+	accrualService       services.AccrualService
+	mfaService           services.MFAService
+	mfaWithdrawThreshold decimal.Decimal
+	withdrawalRules      services.WithdrawalRulesService
+	fraudService         services.FraudService
+}
+
+func NewV2Handlers(or repository.OrderRepository, pr repository.PointsRepository, as services.AccrualService, mfa services.MFAService, mfaWithdrawThreshold decimal.Decimal, wr services.WithdrawalRulesService, fs services.FraudService) *V2Handlers {
+	return &V2Handlers{
+		orderRepository:      or,
+		pointsRepository:     pr,
+		accrualService:       as,
+		mfaService:           mfa,
+		mfaWithdrawThreshold: mfaWithdrawThreshold,
+		withdrawalRules:      wr,
+		fraudService:         fs,
+	}
+}
+
+func (vh *V2Handlers) CreateOrderHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var request models.CreateOrderRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			abortWithMalformedBody(ctx)
+			return
+		}
+
+		status, err := uploadUserOrder(ctx, vh.orderRepository, vh.accrualService, vh.fraudService, userID, request.Number)
+		if err != nil {
+			ctx.AbortWithError(status, err)
+			return
+		}
+
+		order, err := vh.orderRepository.GetOrder(ctx, request.Number)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		if order == nil {
+			ctx.AbortWithError(http.StatusInternalServerError, repository.ErrOrderNotFound)
+			return
+		}
+
+		if status == http.StatusAccepted {
+			status = http.StatusCreated
+		}
+
+		ctx.JSON(status, models.Envelope[models.OrderResource]{Data: models.NewOrderResource(*order)})
+	}
+}
+
+func (vh *V2Handlers) ListOrdersHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		limit, offset, errs := parsePage(ctx)
+		if len(errs) > 0 {
+			abortWithValidationErrors(ctx, errs)
+			return
+		}
+
+		orders, total, err := vh.orderRepository.GetUserOrdersPage(ctx, userID, limit, offset)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		resources := make([]models.OrderResource, 0, len(orders))
+		for _, order := range orders {
+			resources = append(resources, models.NewOrderResource(order))
+		}
+
+		ctx.JSON(http.StatusOK, newListEnvelope(ctx, resources, total, limit, offset))
+	}
+}
+
+// QueuePendingOrdersHandler goes ahead of ConditionalGET on the order list.
+func (vh *V2Handlers) QueuePendingOrdersHandler() gin.HandlerFunc {
+	return queuePendingOrders(vh.orderRepository, vh.accrualService)
+}
+
+func (vh *V2Handlers) GetOrderHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		order, err := vh.orderRepository.GetOrder(ctx, ctx.Param("number"))
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		// orders of other users are reported as missing so numbers cannot be probed
+		if order == nil || order.UserID != userID {
+			ctx.AbortWithError(http.StatusNotFound, repository.ErrOrderNotFound)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, models.Envelope[models.OrderResource]{Data: models.NewOrderResource(*order)})
+	}
+}
+
+func (vh *V2Handlers) BalanceHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		balance, err := getUserBalance(ctx, vh.pointsRepository, userID)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, models.Envelope[models.GetUserBalanceResponse]{Data: balance})
+	}
+}
+
+func (vh *V2Handlers) CreateWithdrawalHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var request models.WithdrawUserPointsRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			abortWithMalformedBody(ctx)
+			return
+		}
+
+		if !request.WithdrawSum.IsPositive() {
+			abortWithValidationErrors(ctx, validation.Errors{{
+				Field:   "sum",
+				Code:    validation.CodeOutOfRange,
+				Message: "must be greater than 0",
+			}})
+			return
+		}
+
+		if ok := helpers.LuhnCheck(request.OrderNum); !ok {
+			ctx.AbortWithError(http.StatusUnprocessableEntity, helpers.ErrInvalidOrderNumber)
+			return
+		}
+
+		if err := checkWithdrawMFA(ctx, vh.mfaService, vh.mfaWithdrawThreshold, userID, request.WithdrawSum); err != nil {
+			if errors.Is(err, services.ErrInvalidMFACode) {
+				ctx.AbortWithError(http.StatusForbidden, err)
+				return
+			}
+
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		err := vh.withdrawalRules.Withdraw(ctx, userID, request.OrderNum, request.WithdrawSum, func(guard repository.WithdrawalGuard) error {
+			return vh.pointsRepository.WithdrawPoints(ctx, userID, request.OrderNum, request.WithdrawSum, guard)
+		})
+		if err != nil {
+			abortWithWithdrawalError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, models.Envelope[models.WithdrawalResource]{Data: models.NewWithdrawalResource(models.WithdrawHistoryEntry{
+			OrderNum:    request.OrderNum,
+			WithdrawSum: request.WithdrawSum,
+			ProcessedAt: models.RFC3339Time(time.Now()),
+		})})
+	}
+}
+
+func (vh *V2Handlers) ListWithdrawalsHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := helpers.GetUserIDFromContext(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		limit, offset, errs := parsePage(ctx)
+		if len(errs) > 0 {
+			abortWithValidationErrors(ctx, errs)
+			return
+		}
+
+		entries, total, err := vh.pointsRepository.GetUserWithdrawalsPage(ctx, userID, limit, offset)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		resources := make([]models.WithdrawalResource, 0, len(entries))
+		for _, entry := range entries {
+			resources = append(resources, models.NewWithdrawalResource(entry))
+		}
+
+		ctx.JSON(http.StatusOK, newListEnvelope(ctx, resources, total, limit, offset))
+	}
+}
+
+func abortWithMalformedBody(ctx *gin.Context) {
+	abortWithValidationErrors(ctx, validation.Errors{{
+		Field:   validation.FieldBody,
+		Code:    validation.CodeMalformedBody,
+		Message: "request body must be a valid JSON object",
+	}})
+}
+
+func parsePage(ctx *gin.Context) (limit int, offset int, errs validation.Errors) {
+	limit = defaultPageLimit
+
+	if value, ok := ctx.GetQuery("limit"); ok {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxPageLimit {
+			errs = append(errs, validation.FieldError{
+				Field:   "limit",
+				Code:    validation.CodeOutOfRange,
+				Message: "must be an integer between 1 and " + strconv.Itoa(maxPageLimit),
+			})
+		} else {
+			limit = parsed
+		}
+	}
+
+	if value, ok := ctx.GetQuery("offset"); ok {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			errs = append(errs, validation.FieldError{
+				Field:   "offset",
+				Code:    validation.CodeOutOfRange,
+				Message: "must be a non-negative integer",
+			})
+		} else {
+			offset = parsed
+		}
+	}
+
+	return limit, offset, errs
+}
+
+func newListEnvelope[T any](ctx *gin.Context, data []T, total int, limit int, offset int) models.ListEnvelope[T] {
+	envelope := models.ListEnvelope[T]{
+		Data: data,
+		Meta: models.PageMeta{
+			Total:  total,
+			Limit:  limit,
+			Offset: offset,
+		},
+		Links: models.PageLinks{
+			Self: pageLink(ctx, limit, offset),
+		},
+	}
+
+	if offset+limit < total {
+		next := pageLink(ctx, limit, offset+limit)
+		envelope.Links.Next = &next
+	}
+
+	if offset > 0 {
+		prev := pageLink(ctx, limit, max(offset-limit, 0))
+		envelope.Links.Prev = &prev
+	}
+
+	return envelope
+}
+
+func pageLink(ctx *gin.Context, limit int, offset int) string {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+
+	return ctx.Request.URL.Path + "?" + query.Encode()
+}
@@ -3,6 +3,7 @@ package helpers
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/rovany706/loyalty-gopher/internal/middleware"
+	"github.com/rovany706/loyalty-gopher/internal/models"
 )
 
 func GetUserIDFromContext(ctx *gin.Context) (int, bool) {
@@ -18,3 +19,31 @@ func GetUserIDFromContext(ctx *gin.Context) (int, bool) {
 
 	return userID, true
 }
+
+func GetAPIKeyFromContext(ctx *gin.Context) (*models.APIKey, bool) {
+	apiKeyValue, exists := ctx.Get(middleware.APIKeyContextKey)
+	if !exists {
+		return nil, false
+	}
+
+	apiKey, ok := apiKeyValue.(*models.APIKey)
+	if !ok {
+		return nil, false
+	}
+
+	return apiKey, true
+}
+
+func GetSessionIDFromContext(ctx *gin.Context) (int, bool) {
+	sessionIDValue, exists := ctx.Get(middleware.SessionIDContextKey)
+	if !exists {
+		return 0, false
+	}
+
+	sessionID, ok := sessionIDValue.(int)
+	if !ok {
+		return 0, false
+	}
+
+	return sessionID, true
+}
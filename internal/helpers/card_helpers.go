@@ -0,0 +1,36 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strconv"
+)
+
+const loyaltyCardIDLength = 16
+
+// GenerateLoyaltyCardID returns a random Luhn-valid 16-digit number starting
+// with 1-8. Numbers starting with 9 were assigned to accounts that existed
+// before cards were introduced.
+func GenerateLoyaltyCardID() (string, error) {
+	first, err := rand.Int(rand.Reader, big.NewInt(8))
+	if err != nil {
+		return "", err
+	}
+
+	digits := []byte(strconv.FormatInt(first.Int64()+1, 10))
+	for len(digits) < loyaltyCardIDLength-1 {
+		d, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		digits = append(digits, byte('0'+d.Int64()))
+	}
+
+	for check := byte('0'); check <= '9'; check++ {
+		if LuhnCheck(string(digits) + string(check)) {
+			return string(append(digits, check)), nil
+		}
+	}
+
+	panic("unreachable: every prefix has a Luhn check digit")
+}
@@ -1,6 +1,12 @@
 package helpers
 
-import "github.com/rovany706/loyalty-gopher/internal/models"
+import (
+	"errors"
+
+	"github.com/rovany706/loyalty-gopher/internal/models"
+)
+
+var ErrInvalidOrderNumber = errors.New("order number fails the Luhn check")
 
 func convertStringToIntSlice(str string) []int {
 	nums := make([]int, len(str))
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/auth"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+const (
+	APIKeyContextKey = "api_key"
+
+	apiKeyHeader    = "X-API-Key"
+	apiKeyAuthToken = "ApiKey "
+)
+
+type APIKeyStore interface {
+	GetActiveAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, error)
+	TouchAPIKey(ctx context.Context, keyID int) error
+}
+
+// AuthAPIKey authenticates partner systems by the key in the X-API-Key header
+// or in "Authorization: ApiKey <key>", and applies the key's own rate limit.
+func AuthAPIKey(store APIKeyStore, logger *zap.Logger) gin.HandlerFunc {
+	limiters := newAPIKeyLimiters()
+
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader(apiKeyHeader)
+		if key == "" {
+			key, _ = strings.CutPrefix(ctx.GetHeader("Authorization"), apiKeyAuthToken)
+		}
+
+		prefix, ok := auth.ParseAPIKeyPrefix(key)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		apiKey, err := store.GetActiveAPIKeyByPrefix(ctx, prefix)
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		if apiKey == nil {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(auth.HashAPIKey(key)), []byte(apiKey.KeyHash)) != 1 {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		if retryAfter, ok := limiters.allow(apiKey); !ok {
+			ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			ctx.AbortWithStatus(http.StatusTooManyRequests)
+			return
+		}
+
+		if err := store.TouchAPIKey(ctx, apiKey.ID); err != nil {
+			logger.Warn("failed to update api key usage", zap.Int("key_id", apiKey.ID), zap.Error(err))
+		}
+
+		ctx.Set(APIKeyContextKey, apiKey)
+		ctx.Next()
+	}
+}
+
+// RequireScope must run after AuthAPIKey.
+func RequireScope(scope models.APIKeyScope) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		value, exists := ctx.Get(APIKeyContextKey)
+		if !exists {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		apiKey, ok := value.(*models.APIKey)
+		if !ok || !apiKey.HasScope(scope) {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+type apiKeyLimiters struct {
+	limiters map[int]*rate.Limiter
+	mutex    sync.Mutex
+}
+
+func newAPIKeyLimiters() *apiKeyLimiters {
+	return &apiKeyLimiters{
+		limiters: make(map[int]*rate.Limiter),
+	}
+}
+
+// allow takes a token from the key's bucket, which holds up to a minute's
+// worth of requests, and otherwise reports how long to wait for the next one.
+func (l *apiKeyLimiters) allow(apiKey *models.APIKey) (time.Duration, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	perMinute := max(apiKey.RateLimitPerMinute, 1)
+	limiter, ok := l.limiters[apiKey.ID]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(perMinute)), perMinute)
+		l.limiters[apiKey.ID] = limiter
+	}
+
+	reservation := limiter.Reserve()
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return delay, false
+	}
+
+	return 0, true
+}
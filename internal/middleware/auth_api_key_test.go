@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/auth"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type memoryAPIKeyStore map[string]*models.APIKey
+
+func (s memoryAPIKeyStore) GetActiveAPIKeyByPrefix(_ context.Context, prefix string) (*models.APIKey, error) {
+	return s[prefix], nil
+}
+
+func (s memoryAPIKeyStore) TouchAPIKey(_ context.Context, _ int) error {
+	return nil
+}
+
+func TestAuthAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key, prefix, err := auth.GenerateAPIKey()
+	require.NoError(t, err)
+	otherKey, _, err := auth.GenerateAPIKey()
+	require.NoError(t, err)
+
+	store := memoryAPIKeyStore{
+		prefix: {
+			ID:                 1,
+			Prefix:             prefix,
+			KeyHash:            auth.HashAPIKey(key),
+			Scopes:             []models.APIKeyScope{models.ScopeOrdersWrite},
+			RateLimitPerMinute: 3,
+		},
+	}
+
+	r := gin.New()
+	r.Use(AuthAPIKey(store, zap.NewNop()))
+	r.POST("/orders", RequireScope(models.ScopeOrdersWrite), func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	r.POST("/redemptions", RequireScope(models.ScopePointsRedeem), func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	tests := []struct {
+		name     string
+		path     string
+		header   string
+		value    string
+		wantCode int
+	}{
+		{"valid key header", "/orders", "X-API-Key", key, http.StatusOK},
+		{"valid authorization header", "/orders", "Authorization", "ApiKey " + key, http.StatusOK},
+		{"missing scope", "/redemptions", "X-API-Key", key, http.StatusForbidden},
+		{"rate limited", "/orders", "X-API-Key", key, http.StatusTooManyRequests},
+		{"unknown key", "/orders", "X-API-Key", otherKey, http.StatusUnauthorized},
+		{"forged secret", "/orders", "X-API-Key", key[:len(key)-4] + "aaaa", http.StatusUnauthorized},
+		{"no key", "/orders", "", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+}
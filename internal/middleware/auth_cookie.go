@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/auth"
+)
+
+const (
+	AccessTokenCookie = "access_token"
+	CSRFTokenCookie   = "csrf_token"
+	CSRFTokenHeader   = "X-CSRF-Token"
+)
+
+var ErrInvalidCSRFToken = errors.New("missing or invalid CSRF token")
+
+// CookieSettings controls the optional cookie auth mode for browser clients.
+type CookieSettings struct {
+	Enabled  bool
+	Secure   bool
+	SameSite http.SameSite
+}
+
+func ParseSameSite(mode string) http.SameSite {
+	switch mode {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteStrictMode
+	}
+}
+
+// SetAuthCookies stores the access token in an HttpOnly cookie and a fresh
+// CSRF token in a cookie the frontend can read and echo in X-CSRF-Token.
+func SetAuthCookies(ctx *gin.Context, settings CookieSettings, token string) error {
+	csrfToken, err := auth.GenerateCSRFToken()
+	if err != nil {
+		return err
+	}
+
+	maxAge := int(auth.TokenExpiryTime.Seconds())
+
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     AccessTokenCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   settings.Secure,
+		HttpOnly: true,
+		SameSite: settings.SameSite,
+	})
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     CSRFTokenCookie,
+		Value:    csrfToken,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   settings.Secure,
+		SameSite: settings.SameSite,
+	})
+
+	return nil
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func validCSRFToken(ctx *gin.Context) bool {
+	cookie, err := ctx.Cookie(CSRFTokenCookie)
+	if err != nil || cookie == "" {
+		return false
+	}
+
+	header := ctx.GetHeader(CSRFTokenHeader)
+
+	return subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) == 1
+}
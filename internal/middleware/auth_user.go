@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
@@ -8,34 +9,62 @@ import (
 	"github.com/rovany706/loyalty-gopher/internal/auth"
 )
 
-const UserIDContextKey = "user_id"
+const (
+	UserIDContextKey    = "user_id"
+	UserRoleContextKey  = "user_role"
+	SessionIDContextKey = "session_id"
+)
 
-type authHeader struct {
-	Token string `header:"Authorization"`
+type SessionStore interface {
+	TouchSession(ctx context.Context, userID int, sessionID int) (bool, error)
 }
 
-func AuthUser(tm auth.TokenManager) gin.HandlerFunc {
+// AuthUser accepts a bearer token from the Authorization header or, in cookie
+// mode, from the access token cookie. Cookie-authenticated requests that
+// change state must also echo the CSRF cookie in the X-CSRF-Token header.
+func AuthUser(tm auth.TokenManager, sessions SessionStore, cookies CookieSettings) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		h := authHeader{}
+		token, fromHeader := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if !fromHeader && cookies.Enabled {
+			token, _ = ctx.Cookie(AccessTokenCookie)
+			if token != "" && !isSafeMethod(ctx.Request.Method) && !validCSRFToken(ctx) {
+				ctx.AbortWithError(http.StatusForbidden, ErrInvalidCSRFToken)
+				return
+			}
+		}
+
+		if token == "" {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
 
-		if err := ctx.ShouldBindHeader(&h); err != nil {
+		claims, err := tm.GetClaimsFromToken(token)
+		if err != nil {
 			ctx.AbortWithError(http.StatusUnauthorized, err)
 			return
 		}
 
-		tokenHeader := strings.Split(h.Token, "Bearer ")
-		if len(tokenHeader) < 2 {
+		// tokens issued before sessions were introduced carry no session, they
+		// would escape revocation and the disabled check of TouchSession
+		if claims.SessionID == 0 {
 			ctx.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
 
-		claims, err := tm.GetClaimsFromToken(tokenHeader[1])
+		active, err := sessions.TouchSession(ctx, claims.UserID, claims.SessionID)
 		if err != nil {
-			ctx.AbortWithError(http.StatusUnauthorized, err)
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		if !active {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
 
 		ctx.Set(UserIDContextKey, claims.UserID)
+		ctx.Set(UserRoleContextKey, claims.GetRole())
+		ctx.Set(SessionIDContextKey, claims.SessionID)
 		ctx.Next()
 	}
 }
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/auth"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSessionStore knows the active sessions by ID.
+type fakeSessionStore struct {
+	active map[int]bool
+}
+
+func (s *fakeSessionStore) TouchSession(_ context.Context, _ int, sessionID int) (bool, error) {
+	return s.active[sessionID], nil
+}
+
+func TestAuthUserSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tm, err := auth.NewJWTTokenManager([]byte("secret"))
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.GET("/api/user/balance", AuthUser(tm, &fakeSessionStore{active: map[int]bool{1: true}}, CookieSettings{}), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name      string
+		sessionID int
+		wantCode  int
+	}{
+		{"active session", 1, http.StatusOK},
+		{"revoked session or disabled user", 2, http.StatusUnauthorized},
+		{"token without session", 0, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tm.CreateToken(1, models.RoleUser, tt.sessionID)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/user/balance", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+}
+
+func TestAuthUserCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tm, err := auth.NewJWTTokenManager([]byte("secret"))
+	require.NoError(t, err)
+
+	token, err := tm.CreateToken(1, models.RoleUser, 1)
+	require.NoError(t, err)
+
+	cookies := CookieSettings{Enabled: true, Secure: true, SameSite: http.SameSiteStrictMode}
+
+	r := gin.New()
+	r.Use(AuthUser(tm, &fakeSessionStore{active: map[int]bool{1: true}}, cookies))
+	r.GET("/api/user/balance", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	r.POST("/api/user/orders", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		bearer     bool
+		cookie     bool
+		csrfCookie string
+		csrfHeader string
+		wantCode   int
+	}{
+		{"bearer header", http.MethodPost, "/api/user/orders", true, false, "", "", http.StatusOK},
+		{"cookie on safe method", http.MethodGet, "/api/user/balance", false, true, "", "", http.StatusOK},
+		{"cookie with matching csrf token", http.MethodPost, "/api/user/orders", false, true, "csrf", "csrf", http.StatusOK},
+		{"cookie without csrf header", http.MethodPost, "/api/user/orders", false, true, "csrf", "", http.StatusForbidden},
+		{"cookie with mismatched csrf token", http.MethodPost, "/api/user/orders", false, true, "csrf", "other", http.StatusForbidden},
+		{"no credentials", http.MethodGet, "/api/user/balance", false, false, "", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.bearer {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			if tt.cookie {
+				req.AddCookie(&http.Cookie{Name: AccessTokenCookie, Value: token})
+			}
+			if tt.csrfCookie != "" {
+				req.AddCookie(&http.Cookie{Name: CSRFTokenCookie, Value: tt.csrfCookie})
+			}
+			if tt.csrfHeader != "" {
+				req.Header.Set(CSRFTokenHeader, tt.csrfHeader)
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+}
+
+func TestAuthUserCookieDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tm, err := auth.NewJWTTokenManager([]byte("secret"))
+	require.NoError(t, err)
+
+	token, err := tm.CreateToken(1, models.RoleUser, 1)
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.GET("/api/user/balance", AuthUser(tm, &fakeSessionStore{active: map[int]bool{1: true}}, CookieSettings{}), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/balance", nil)
+	req.AddCookie(&http.Cookie{Name: AccessTokenCookie, Value: token})
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
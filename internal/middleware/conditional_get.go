@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+)
+
+const cacheControlRevalidate = "private, no-cache"
+
+type DataVersionStore interface {
+	GetDataVersion(ctx context.Context, userID int) (models.DataVersion, error)
+}
+
+// ConditionalGET tags responses of the authenticated user with a weak ETag and
+// Last-Modified derived from the user's data version, and answers 304 without
+// running the handler when the client already has the current representation.
+// It must follow AuthUser.
+func ConditionalGET(store DataVersionStore) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := ctx.Get(UserIDContextKey)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		version, err := store.GetDataVersion(ctx, userID.(int))
+		if err != nil {
+			ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		etag := dataVersionETag(userID.(int), version, ctx.GetHeader("Accept"))
+		modifiedAt := version.ModifiedAt.UTC().Truncate(time.Second)
+
+		header := ctx.Writer.Header()
+		header.Set("ETag", etag)
+		header.Set("Last-Modified", modifiedAt.Format(http.TimeFormat))
+		header.Set("Cache-Control", cacheControlRevalidate)
+		header.Add("Vary", "Accept")
+
+		if notModified(ctx.Request, etag, modifiedAt) {
+			ctx.AbortWithStatus(http.StatusNotModified)
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// dataVersionETag covers the Accept header, because the same URL may be sent as
+// JSON or as a file download.
+func dataVersionETag(userID int, version models.DataVersion, accept string) string {
+	h := fnv.New32a()
+	h.Write([]byte(accept))
+
+	return fmt.Sprintf(`W/"%d-%d-%08x"`, userID, version.Version, h.Sum32())
+}
+
+// notModified applies If-None-Match and, only without it, If-Modified-Since,
+// whose one-second resolution cannot tell apart changes made within a second.
+func notModified(r *http.Request, etag string, modifiedAt time.Time) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	ifModifiedSince, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+
+	return !modifiedAt.After(ifModifiedSince)
+}
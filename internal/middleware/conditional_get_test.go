@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDataVersionStore struct {
+	version models.DataVersion
+}
+
+func (s *fakeDataVersionStore) GetDataVersion(context.Context, int) (models.DataVersion, error) {
+	return s.version, nil
+}
+
+func TestConditionalGET(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	modifiedAt := time.Date(2024, 3, 1, 12, 0, 0, 500, time.UTC)
+	store := &fakeDataVersionStore{version: models.DataVersion{Version: 7, ModifiedAt: modifiedAt}}
+
+	handled := 0
+	r := gin.New()
+	r.GET("/balance", func(ctx *gin.Context) {
+		ctx.Set(UserIDContextKey, 1)
+	}, ConditionalGET(store), func(ctx *gin.Context) {
+		handled++
+		ctx.String(http.StatusOK, "balance")
+	})
+
+	get := func(header string, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/balance", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		return w
+	}
+
+	first := get("", "")
+	require.Equal(t, http.StatusOK, first.Code)
+	etag := first.Header().Get("ETag")
+	assert.Regexp(t, `^W/".+"$`, etag)
+	assert.Equal(t, "private, no-cache", first.Header().Get("Cache-Control"))
+	assert.Equal(t, "Fri, 01 Mar 2024 12:00:00 GMT", first.Header().Get("Last-Modified"))
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		wantCode int
+	}{
+		{"matching etag", "If-None-Match", etag, http.StatusNotModified},
+		{"matching strong etag", "If-None-Match", `"other", ` + etag[2:], http.StatusNotModified},
+		{"any etag", "If-None-Match", "*", http.StatusNotModified},
+		{"stale etag", "If-None-Match", `W/"1-6-00000000"`, http.StatusOK},
+		{"not modified since", "If-Modified-Since", "Fri, 01 Mar 2024 12:00:00 GMT", http.StatusNotModified},
+		{"modified since", "If-Modified-Since", "Fri, 01 Mar 2024 11:59:59 GMT", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handledBefore := handled
+			w := get(tt.header, tt.value)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, etag, w.Header().Get("ETag"))
+			if tt.wantCode == http.StatusNotModified {
+				assert.Equal(t, handledBefore, handled, "handler ran for a 304 response")
+				assert.Empty(t, w.Body.String())
+			}
+		})
+	}
+
+	store.version.Version++
+	assert.Equal(t, http.StatusOK, get("If-None-Match", etag).Code)
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"go.uber.org/zap"
+)
+
+// defaultRateLimitRoute names the bucket shared by routes without a limit of their own.
+const defaultRateLimitRoute = "*"
+
+const memoryStoreSweepInterval = time.Minute
+
+type RateLimitStore interface {
+	// TakeToken takes a token from the bucket stored under key.
+	TakeToken(ctx context.Context, key string, limit models.RateLimit, now time.Time) (models.RateLimitDecision, error)
+}
+
+// RateLimitPolicy holds the limits of routes, by method and path as gin
+// registers them, e.g. "GET /api/user/orders", or by full gRPC method name,
+// and the limit of the others.
+type RateLimitPolicy struct {
+	Default models.RateLimit
+	Routes  map[string]models.RateLimit
+}
+
+// Limit returns the bucket name and the limit of the route, the routes
+// without a limit of their own share the default bucket.
+func (p RateLimitPolicy) Limit(route string) (string, models.RateLimit) {
+	if limit, ok := p.Routes[route]; ok {
+		return route, limit
+	}
+
+	return defaultRateLimitRoute, p.Default
+}
+
+// RateLimit limits requests per user ID set by AuthUser or, on routes without
+// authentication, per client IP. Every route of the policy has a bucket of its
+// own, the other routes share one. A failing store lets requests through.
+func RateLimit(store RateLimitStore, policy RateLimitPolicy, logger *zap.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		route, limit := policy.Limit(ctx.Request.Method + " " + ctx.FullPath())
+
+		client := "ip:" + ctx.ClientIP()
+		if userID, ok := ctx.Get(UserIDContextKey); ok {
+			client = "user:" + strconv.Itoa(userID.(int))
+		}
+
+		decision, err := store.TakeToken(ctx, route+"|"+client, limit, time.Now())
+		if err != nil {
+			logger.Warn("failed to check rate limit", zap.String("route", route), zap.Error(err))
+			ctx.Next()
+			return
+		}
+
+		ctx.Header("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		ctx.Header("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		ctx.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.Reset)))
+		ctx.Header("RateLimit-Policy", strconv.Itoa(limit.Requests)+";w="+strconv.Itoa(ceilSeconds(limit.Period)))
+
+		if !decision.Allowed {
+			ctx.Header("Retry-After", strconv.Itoa(ceilSeconds(decision.RetryAfter)))
+			ctx.AbortWithStatus(http.StatusTooManyRequests)
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+type memoryBucket struct {
+	bucket models.TokenBucket
+	fullAt time.Time
+}
+
+// MemoryRateLimitStore keeps buckets in the process, so every instance of a
+// deployment limits on its own.
+type MemoryRateLimitStore struct {
+	buckets   map[string]memoryBucket
+	lastSweep time.Time
+	mutex     sync.Mutex
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]memoryBucket),
+	}
+}
+
+func (s *MemoryRateLimitStore) TakeToken(_ context.Context, key string, limit models.RateLimit, now time.Time) (models.RateLimitDecision, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	bucket, decision := s.buckets[key].bucket.Take(limit, now)
+	s.buckets[key] = memoryBucket{bucket: bucket, fullAt: now.Add(decision.Reset)}
+
+	// a full bucket behaves like a missing one, so those are dropped
+	if now.Sub(s.lastSweep) >= memoryStoreSweepInterval {
+		for key, bucket := range s.buckets {
+			if !bucket.fullAt.After(now) {
+				delete(s.buckets, key)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	return decision, nil
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	rateLimit := RateLimit(NewMemoryRateLimitStore(), RateLimitPolicy{
+		Default: models.RateLimit{Requests: 3, Period: time.Minute},
+		Routes: map[string]models.RateLimit{
+			"GET /orders": {Requests: 1, Period: time.Minute},
+		},
+	}, zap.NewNop())
+
+	// the user ID comes from a header here instead of a token
+	setUser := func(ctx *gin.Context) {
+		switch ctx.GetHeader("X-User") {
+		case "1":
+			ctx.Set(UserIDContextKey, 1)
+		case "2":
+			ctx.Set(UserIDContextKey, 2)
+		}
+	}
+
+	r := gin.New()
+	ok := func(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+	r.GET("/orders", setUser, rateLimit, ok)
+	r.GET("/balance", setUser, rateLimit, ok)
+	r.GET("/withdrawals", setUser, rateLimit, ok)
+
+	tests := []struct {
+		name          string
+		path          string
+		user          string
+		wantCode      int
+		wantRemaining string
+	}{
+		{"first order list", "/orders", "1", http.StatusOK, "0"},
+		{"second order list", "/orders", "1", http.StatusTooManyRequests, "0"},
+		{"order list of another user", "/orders", "2", http.StatusOK, "0"},
+		{"default bucket untouched by order lists", "/balance", "1", http.StatusOK, "2"},
+		{"routes without own limit share a bucket", "/withdrawals", "1", http.StatusOK, "1"},
+		{"last request of the default bucket", "/balance", "1", http.StatusOK, "0"},
+		{"default bucket empty", "/withdrawals", "1", http.StatusTooManyRequests, "0"},
+		{"anonymous client by IP", "/balance", "", http.StatusOK, "2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("X-User", tt.user)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantRemaining, w.Header().Get("RateLimit-Remaining"))
+			assert.NotEmpty(t, w.Header().Get("RateLimit-Limit"))
+			assert.NotEmpty(t, w.Header().Get("RateLimit-Reset"))
+			if tt.wantCode == http.StatusTooManyRequests {
+				assert.NotEmpty(t, w.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestRateLimitIgnoresForwardedForFromClients(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	rateLimit := RateLimit(NewMemoryRateLimitStore(), RateLimitPolicy{
+		Default: models.RateLimit{Requests: 1, Period: time.Minute},
+	}, zap.NewNop())
+
+	r := gin.New()
+	// the server trusts no proxy unless configured to
+	assert.NoError(t, r.SetTrustedProxies(nil))
+	r.POST("/login", rateLimit, func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	for i, wantCode := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = "203.0.113.7:50000"
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d", i))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, wantCode, w.Code)
+	}
+}
+
+func TestTokenBucketRefill(t *testing.T) {
+	limit := models.RateLimit{Requests: 2, Period: time.Minute}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	var bucket models.TokenBucket
+	var decision models.RateLimitDecision
+	for range 2 {
+		bucket, decision = bucket.Take(limit, now)
+		assert.True(t, decision.Allowed)
+	}
+
+	bucket, decision = bucket.Take(limit, now.Add(10*time.Second))
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, 20*time.Second, decision.RetryAfter)
+
+	_, decision = bucket.Take(limit, now.Add(30*time.Second))
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 0, decision.Remaining)
+	assert.Equal(t, time.Minute, decision.Reset)
+}
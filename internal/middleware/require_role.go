@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+)
+
+// RequireRole must run after AuthUser. It lets through only callers
+// whose token carries one of the given roles.
+func RequireRole(roles ...models.Role) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		role, exists := ctx.Get(UserRoleContextKey)
+		if !exists {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		userRole, ok := role.(models.Role)
+		if !ok || !slices.Contains(roles, userRole) {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		ctx.Next()
+	}
+}
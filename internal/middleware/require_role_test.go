@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/auth"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tm, err := auth.NewJWTTokenManager([]byte("secret"))
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.GET("/admin", AuthUser(tm, &fakeSessionStore{active: map[int]bool{1: true}}, CookieSettings{}), RequireRole(models.RoleSupport, models.RoleAdmin), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name     string
+		role     models.Role
+		wantCode int
+	}{
+		{"admin", models.RoleAdmin, http.StatusOK},
+		{"support", models.RoleSupport, http.StatusOK},
+		{"user", models.RoleUser, http.StatusForbidden},
+		{"partner", models.RolePartner, http.StatusForbidden},
+		{"token without role", "", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tm.CreateToken(1, tt.role, 1)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package models
+
+import "github.com/shopspring/decimal"
+
+type BalanceAdjustment struct {
+	Amount    decimal.Decimal `json:"amount"`
+	Reason    string          `json:"reason"`
+	CreatedAt RFC3339Time     `json:"created_at"`
+}
+
+type UserExport struct {
+	ExportedAt         RFC3339Time            `json:"exported_at"`
+	Profile            User                   `json:"profile"`
+	Balance            GetUserBalanceResponse `json:"balance"`
+	Orders             []Order                `json:"orders"`
+	Withdrawals        []WithdrawHistoryEntry `json:"withdrawals"`
+	BalanceAdjustments []BalanceAdjustment    `json:"balance_adjustments"`
+}
+
+type AccountDeletionResponse struct {
+	DeletionRequestedAt RFC3339Time `json:"deletion_requested_at"`
+	DeletionScheduledAt RFC3339Time `json:"deletion_scheduled_at"`
+}
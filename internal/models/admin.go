@@ -0,0 +1,22 @@
+package models
+
+import "github.com/shopspring/decimal"
+
+type AdminUserResponse struct {
+	User
+	Balance GetUserBalanceResponse `json:"balance"`
+}
+
+type AdminOrderResponse struct {
+	Order
+	UserID int `json:"user_id"`
+}
+
+type BalanceAdjustmentRequest struct {
+	Amount decimal.Decimal `json:"amount"`
+	Reason string          `json:"reason" binding:"required"`
+}
+
+type SetUserRoleRequest struct {
+	Role Role `json:"role" binding:"required"`
+}
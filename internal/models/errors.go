@@ -0,0 +1,16 @@
+package models
+
+import "github.com/rovany706/loyalty-gopher/internal/validation"
+
+// Problem is an RFC 7807 error body. Code is stable and meant for clients
+// to branch on, Title and Detail are for humans.
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Code     string            `json:"code"`
+	TraceID  string            `json:"trace_id"`
+	Errors   validation.Errors `json:"errors,omitempty"`
+}
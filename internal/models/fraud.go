@@ -0,0 +1,90 @@
+package models
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// FraudThresholds configure the scoring of order uploads over a sliding Window.
+type FraudThresholds struct {
+	Window        time.Duration
+	VelocityLimit int
+	FlagScore     int
+	ThrottleScore int
+}
+
+// OrderUploadStats count the order uploads of a user or an IP over a period.
+type OrderUploadStats struct {
+	Uploads   int
+	Conflicts int
+	Invalid   int
+}
+
+// minScoredUploads keeps a few typos from looking like probing.
+const minScoredUploads = 5
+
+// RiskScore rates the uploads from 0 to 100. The share of orders of other
+// users and the number of uploads, up to velocityLimit, weigh 40 each, the
+// share of invalid numbers 20.
+func (s OrderUploadStats) RiskScore(velocityLimit int) int {
+	if s.Uploads < minScoredUploads {
+		return 0
+	}
+
+	conflictRate := float64(s.Conflicts) / float64(s.Uploads)
+	invalidRate := float64(s.Invalid) / float64(s.Uploads)
+	velocity := min(float64(s.Uploads)/float64(velocityLimit), 1)
+
+	return int(math.Round(40*conflictRate + 20*invalidRate + 40*velocity))
+}
+
+// FraudUserSubject and FraudIPSubject name the throttles of users and client IPs.
+func FraudUserSubject(userID int) string {
+	return "user:" + strconv.Itoa(userID)
+}
+
+func FraudIPSubject(clientIP string) string {
+	return "ip:" + clientIP
+}
+
+// FraudAPIKeyClient names the API key of partner uploads, which is scored and
+// throttled in place of the client IP.
+func FraudAPIKeyClient(apiKeyID int) string {
+	return "api-key:" + strconv.Itoa(apiKeyID)
+}
+
+type FraudReviewStatus string
+
+const (
+	FraudReviewStatusPending   FraudReviewStatus = "pending"
+	FraudReviewStatusCleared   FraudReviewStatus = "cleared"
+	FraudReviewStatusConfirmed FraudReviewStatus = "confirmed"
+)
+
+func (s FraudReviewStatus) IsValid() bool {
+	switch s {
+	case FraudReviewStatusPending, FraudReviewStatusCleared, FraudReviewStatusConfirmed:
+		return true
+	default:
+		return false
+	}
+}
+
+// FraudReview is a flagged account waiting for, or resolved by, an admin.
+type FraudReview struct {
+	ID         int               `json:"id"`
+	UserID     int               `json:"user_id"`
+	Score      int               `json:"score"`
+	Uploads    int               `json:"uploads"`
+	Conflicts  int               `json:"conflicts"`
+	Invalid    int               `json:"invalid"`
+	Status     FraudReviewStatus `json:"status"`
+	FlaggedAt  RFC3339Time       `json:"flagged_at"`
+	ResolvedAt *RFC3339Time      `json:"resolved_at,omitempty"`
+	ResolvedBy *int              `json:"resolved_by,omitempty"`
+}
+
+type ResolveFraudReviewRequest struct {
+	Status FraudReviewStatus `json:"status"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+type HouseholdRole string
+
+const (
+	HouseholdRoleOwner  HouseholdRole = "owner"
+	HouseholdRoleMember HouseholdRole = "member"
+)
+
+type HouseholdMemberStatus string
+
+const (
+	HouseholdMemberInvited HouseholdMemberStatus = "invited"
+	HouseholdMemberActive  HouseholdMemberStatus = "active"
+)
+
+// HouseholdMember may withdraw up to Allowance from the pool, a nil Allowance has no limit.
+type HouseholdMember struct {
+	UserID    int                   `json:"user_id"`
+	Login     string                `json:"login"`
+	Role      HouseholdRole         `json:"role"`
+	Status    HouseholdMemberStatus `json:"status"`
+	Allowance *decimal.Decimal      `json:"allowance"`
+	InvitedAt RFC3339Time           `json:"invited_at"`
+	JoinedAt  *RFC3339Time          `json:"joined_at,omitempty"`
+}
+
+// Household pools the accruals of its active members in one account.
+type Household struct {
+	ID      int               `json:"id"`
+	Name    string            `json:"name"`
+	Balance decimal.Decimal   `json:"balance"`
+	Members []HouseholdMember `json:"members"`
+}
+
+type CreateHouseholdRequest struct {
+	Name string `json:"name"`
+}
+
+type InviteHouseholdMemberRequest struct {
+	Login string `json:"login"`
+}
+
+type SetHouseholdAllowanceRequest struct {
+	Allowance *decimal.Decimal `json:"allowance"`
+}
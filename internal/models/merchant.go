@@ -0,0 +1,80 @@
+package models
+
+import "time"
+
+type APIKeyScope string
+
+const (
+	ScopeOrdersWrite  APIKeyScope = "orders:write"
+	ScopePointsRedeem APIKeyScope = "points:redeem"
+)
+
+func (s APIKeyScope) IsValid() bool {
+	return s == ScopeOrdersWrite || s == ScopePointsRedeem
+}
+
+type Merchant struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type APIKey struct {
+	ID                 int           `json:"id"`
+	MerchantID         int           `json:"merchant_id"`
+	Prefix             string        `json:"prefix"`
+	KeyHash            string        `json:"-"`
+	Scopes             []APIKeyScope `json:"scopes"`
+	RateLimitPerMinute int           `json:"rate_limit_per_minute"`
+	CreatedAt          time.Time     `json:"created_at"`
+	LastUsedAt         *time.Time    `json:"last_used_at,omitempty"`
+	RevokedAt          *time.Time    `json:"revoked_at,omitempty"`
+}
+
+func (k *APIKey) HasScope(scope APIKeyScope) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+type CreateMerchantRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type CreateAPIKeyRequest struct {
+	Scopes             []APIKeyScope `json:"scopes" binding:"required"`
+	RateLimitPerMinute int           `json:"rate_limit_per_minute"`
+}
+
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// Customer identifies a user either by login or by loyalty card ID.
+type Customer struct {
+	Login         string `json:"login"`
+	LoyaltyCardID string `json:"card_id"`
+}
+
+type MerchantOrderRequest struct {
+	Customer Customer `json:"customer"`
+	OrderNum string   `json:"order" binding:"required"`
+}
+
+// MerchantRedeemRequest carries the one-time code the customer issued
+// to confirm the redemption.
+type MerchantRedeemRequest struct {
+	Customer Customer `json:"customer"`
+	Code     string   `json:"code" binding:"required"`
+	WithdrawUserPointsRequest
+}
+
+type RedemptionCodeResponse struct {
+	Code      string      `json:"code"`
+	ExpiresAt RFC3339Time `json:"expires_at"`
+}
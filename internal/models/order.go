@@ -23,3 +23,21 @@ type Order struct {
 }
 
 type GetUserOrdersResponse []Order
+
+type OrderBatchStatus string
+
+const (
+	OrderBatchStatusAccepted     OrderBatchStatus = "accepted"
+	OrderBatchStatusDuplicateOwn OrderBatchStatus = "duplicate_own"
+	OrderBatchStatusConflict     OrderBatchStatus = "conflict"
+	OrderBatchStatusInvalid      OrderBatchStatus = "invalid"
+)
+
+type OrderBatchResult struct {
+	Number string           `json:"number"`
+	Status OrderBatchStatus `json:"status"`
+}
+
+type OrderBatchResponse struct {
+	Results []OrderBatchResult `json:"results"`
+}
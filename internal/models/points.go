@@ -19,3 +19,32 @@ type WithdrawUserPointsRequest struct {
 	OrderNum    string          `json:"order"`
 	WithdrawSum decimal.Decimal `json:"sum"`
 }
+
+type BalanceChangeReason string
+
+const (
+	BalanceChangeAccrual    BalanceChangeReason = "accrual"
+	BalanceChangeWithdrawal BalanceChangeReason = "withdrawal"
+	BalanceChangeAdjustment BalanceChangeReason = "adjustment"
+	BalanceChangeTransfer   BalanceChangeReason = "transfer"
+)
+
+// BalanceDelta is added to the fields of GetUserBalanceResponse
+// to get the balance after a change.
+type BalanceDelta struct {
+	Current   decimal.Decimal     `json:"current"`
+	Withdrawn decimal.Decimal     `json:"withdrawn"`
+	Reason    BalanceChangeReason `json:"reason"`
+}
+
+type BalanceMessageType string
+
+const (
+	BalanceMessageSnapshot BalanceMessageType = "balance"
+	BalanceMessageDelta    BalanceMessageType = "delta"
+)
+
+type BalanceMessage struct {
+	Type BalanceMessageType `json:"type"`
+	Data any                `json:"data"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// RateLimit lets Requests requests through every Period, in bursts of up to Requests.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// TokenBucket is the state of one client's bucket. A bucket never used is full.
+type TokenBucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+type RateLimitDecision struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is how long to wait for the next token when the request is refused.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// Take refills the bucket for the time passed since its last update and takes a
+// token from it if there is one.
+func (b TokenBucket) Take(limit RateLimit, now time.Time) (TokenBucket, RateLimitDecision) {
+	capacity := float64(limit.Requests)
+	interval := limit.Period / time.Duration(limit.Requests)
+
+	tokens := capacity
+	if !b.UpdatedAt.IsZero() {
+		// clocks of several instances may disagree, so time never runs backwards here
+		elapsed := max(now.Sub(b.UpdatedAt), 0)
+		tokens = min(b.Tokens+float64(elapsed)/float64(interval), capacity)
+	}
+
+	decision := RateLimitDecision{Allowed: tokens >= 1}
+	if decision.Allowed {
+		tokens--
+	} else {
+		decision.RetryAfter = time.Duration((1 - tokens) * float64(interval))
+	}
+
+	decision.Remaining = int(math.Floor(tokens))
+	decision.Reset = time.Duration((capacity - tokens) * float64(interval))
+
+	return TokenBucket{Tokens: tokens, UpdatedAt: now}, decision
+}
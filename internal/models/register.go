@@ -1,6 +1,6 @@
 package models
 
 type RegisterRequest struct {
-	Login    string `json:"login" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Login    string `json:"login"`
+	Password string `json:"password"`
 }
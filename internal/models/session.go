@@ -0,0 +1,11 @@
+package models
+
+type Session struct {
+	ID         int         `json:"id"`
+	UserID     int         `json:"-"`
+	UserAgent  string      `json:"user_agent"`
+	IP         string      `json:"ip"`
+	CreatedAt  RFC3339Time `json:"created_at"`
+	LastSeenAt RFC3339Time `json:"last_seen_at"`
+	Current    bool        `json:"current"`
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type StatementEntryKind string
+
+const (
+	StatementEntryAccrual    StatementEntryKind = "accrual"
+	StatementEntryWithdrawal StatementEntryKind = "withdrawal"
+	StatementEntryAdjustment StatementEntryKind = "adjustment"
+	StatementEntryTransfer   StatementEntryKind = "transfer"
+	// StatementEntryHouseholdWithdrawal is paid from the household pool, not the balance.
+	StatementEntryHouseholdWithdrawal StatementEntryKind = "household withdrawal"
+)
+
+// StatementEntry is one change of the balance. Amount is negative for debits.
+type StatementEntry struct {
+	Date      time.Time
+	Kind      StatementEntryKind
+	Reference string
+	Amount    decimal.Decimal
+	Balance   decimal.Decimal
+}
+
+// Ledger is the account activity of a period as read from the database.
+type Ledger struct {
+	OpeningBalance decimal.Decimal
+	// LifetimeAccrued is the sum of all accruals up to the end of the period.
+	LifetimeAccrued decimal.Decimal
+	Entries         []StatementEntry
+}
+
+type Tier struct {
+	Name       string
+	MinAccrued decimal.Decimal
+}
+
+// Tiers are ordered by the lifetime accrual they require.
+var Tiers = []Tier{
+	{Name: "Bronze", MinAccrued: decimal.Zero},
+	{Name: "Silver", MinAccrued: decimal.NewFromInt(5000)},
+	{Name: "Gold", MinAccrued: decimal.NewFromInt(20000)},
+	{Name: "Platinum", MinAccrued: decimal.NewFromInt(50000)},
+}
+
+// TierFor returns the tier reached with the lifetime accrual and the next one, if any.
+func TierFor(lifetimeAccrued decimal.Decimal) (Tier, *Tier) {
+	current := Tiers[0]
+	for i, tier := range Tiers {
+		if lifetimeAccrued.LessThan(tier.MinAccrued) {
+			return current, &Tiers[i]
+		}
+		current = tier
+	}
+
+	return current, nil
+}
+
+type Statement struct {
+	Login          string
+	CardID         string
+	PeriodStart    time.Time
+	PeriodEnd      time.Time
+	GeneratedAt    time.Time
+	OpeningBalance decimal.Decimal
+	ClosingBalance decimal.Decimal
+	TotalAccrued   decimal.Decimal
+	TotalWithdrawn decimal.Decimal
+	TotalAdjusted  decimal.Decimal
+	// TotalTransferred is received minus sent transfers.
+	TotalTransferred decimal.Decimal
+	// TotalHouseholdWithdrawn is paid from the household pool and not part of the balance.
+	TotalHouseholdWithdrawn decimal.Decimal
+	Entries                 []StatementEntry
+	LifetimeAccrued         decimal.Decimal
+	Tier                    Tier
+	NextTier                *Tier
+}
+
+type StatementPeriod struct {
+	Month string `json:"month"`
+}
+
+// StatementDocument is a statement rendered in every supported format.
+type StatementDocument struct {
+	HTML []byte
+	PDF  []byte
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// TransferLimits cap what a user transfers to others in 24 hours, zero disables a limit.
+type TransferLimits struct {
+	DailyMaxSum   decimal.Decimal
+	DailyMaxCount int
+}
+
+type TransferStatus string
+
+const (
+	TransferStatusPending   TransferStatus = "pending"
+	TransferStatusCompleted TransferStatus = "completed"
+	TransferStatusDeclined  TransferStatus = "declined"
+	TransferStatusCancelled TransferStatus = "cancelled"
+)
+
+type TransferDirection string
+
+const (
+	TransferIncoming TransferDirection = "incoming"
+	TransferOutgoing TransferDirection = "outgoing"
+)
+
+// CreateTransferRequest names the recipient by login. A transfer that requires
+// acceptance holds the points of the sender until the recipient accepts or declines it.
+type CreateTransferRequest struct {
+	Recipient         string          `json:"recipient"`
+	Sum               decimal.Decimal `json:"sum"`
+	RequireAcceptance bool            `json:"require_acceptance"`
+}
+
+// Transfer is seen from the side of one user: Counterparty is the login of the other one.
+type Transfer struct {
+	ID           int               `json:"id"`
+	Direction    TransferDirection `json:"direction"`
+	Counterparty string            `json:"counterparty"`
+	Sum          decimal.Decimal   `json:"sum"`
+	Status       TransferStatus    `json:"status"`
+	CreatedAt    RFC3339Time       `json:"created_at"`
+	ResolvedAt   *RFC3339Time      `json:"resolved_at,omitempty"`
+}
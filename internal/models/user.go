@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+type Role string
+
+const (
+	RoleUser    Role = "user"
+	RoleSupport Role = "support"
+	RoleAdmin   Role = "admin"
+	RolePartner Role = "partner"
+)
+
+func (r Role) IsValid() bool {
+	switch r {
+	case RoleUser, RoleSupport, RoleAdmin, RolePartner:
+		return true
+	}
+
+	return false
+}
+
+type User struct {
+	ID            int        `json:"id"`
+	Login         string     `json:"login"`
+	Role          Role       `json:"role"`
+	LoyaltyCardID string     `json:"card_id"`
+	DisabledAt    *time.Time `json:"disabled_at,omitempty"`
+	// DeletionRequestedAt is set while the account waits to be anonymized.
+	DeletionRequestedAt *time.Time `json:"deletion_requested_at,omitempty"`
+}
+
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
+}
+
+// DataVersion changes whenever the user's orders or balance change, so clients
+// can revalidate what they fetched before.
+type DataVersion struct {
+	Version    int64
+	ModifiedAt time.Time
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Envelope wraps single resources returned by /api/v2.
+type Envelope[T any] struct {
+	Data T `json:"data"`
+}
+
+// ListEnvelope wraps pages of resources returned by /api/v2.
+type ListEnvelope[T any] struct {
+	Data  []T       `json:"data"`
+	Meta  PageMeta  `json:"meta"`
+	Links PageLinks `json:"links"`
+}
+
+type PageMeta struct {
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type PageLinks struct {
+	Self string  `json:"self"`
+	Next *string `json:"next"`
+	Prev *string `json:"prev"`
+}
+
+// OrderResource reports the stored status as is, so NEW is never shown:
+// an order is registered as soon as it is uploaded. Accrual is null until
+// the order is processed.
+type OrderResource struct {
+	Number     string           `json:"number"`
+	Status     string           `json:"status"`
+	Accrual    *decimal.Decimal `json:"accrual"`
+	UploadedAt RFC3339Time      `json:"uploaded_at"`
+}
+
+func NewOrderResource(order Order) OrderResource {
+	resource := OrderResource{
+		Number:     order.OrderNum,
+		Status:     strings.ToLower(string(order.AccrualStatus)),
+		UploadedAt: order.UploadedAt,
+	}
+
+	if order.AccrualStatus == AccrualStatusNew {
+		resource.Status = strings.ToLower(string(AccrualStatusRegistered))
+	}
+
+	if order.AccrualStatus == AccrualStatusProcessed && order.Accrual != nil {
+		resource.Accrual = order.Accrual
+	}
+
+	return resource
+}
+
+type WithdrawalResource struct {
+	Order       string          `json:"order"`
+	Sum         decimal.Decimal `json:"sum"`
+	ProcessedAt RFC3339Time     `json:"processed_at"`
+}
+
+func NewWithdrawalResource(entry WithdrawHistoryEntry) WithdrawalResource {
+	return WithdrawalResource{
+		Order:       entry.OrderNum,
+		Sum:         entry.WithdrawSum,
+		ProcessedAt: entry.ProcessedAt,
+	}
+}
+
+type CreateOrderRequest struct {
+	Number string `json:"number" binding:"required"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// WithdrawalLimits configure the rules checked before points are withdrawn.
+// Zero sums and counts turn their rules off.
+type WithdrawalLimits struct {
+	MinSum         decimal.Decimal
+	MaxSum         decimal.Decimal
+	DailyMaxSum    decimal.Decimal
+	HourlyMaxCount int
+	MaxDecimals    int
+}
+
+// WithdrawalTotals sum up the withdrawals of a user over a period.
+type WithdrawalTotals struct {
+	Count int
+	Sum   decimal.Decimal
+}
+
+// WithdrawalRuleEvaluation is the audit record of one rule checked for one withdrawal.
+type WithdrawalRuleEvaluation struct {
+	UserID      int
+	OrderNum    string
+	Sum         decimal.Decimal
+	Rule        string
+	Passed      bool
+	Limit       string
+	Actual      string
+	EvaluatedAt time.Time
+}
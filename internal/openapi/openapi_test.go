@@ -0,0 +1,490 @@
+package openapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/auth"
+	"github.com/rovany706/loyalty-gopher/internal/events"
+	"github.com/rovany706/loyalty-gopher/internal/graph"
+	"github.com/rovany706/loyalty-gopher/internal/handlers"
+	"github.com/rovany706/loyalty-gopher/internal/helpers"
+	"github.com/rovany706/loyalty-gopher/internal/middleware"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/openapi"
+	"github.com/rovany706/loyalty-gopher/internal/problems"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/routes"
+	"github.com/rovany706/loyalty-gopher/internal/services"
+	"github.com/rovany706/loyalty-gopher/internal/validation"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// The fakes embed the interfaces they stand in for, so calling
+// a method the documented routes never use panics.
+
+type fakeUserRepository struct {
+	repository.UserRepository
+	mutex sync.Mutex
+	users map[string]string
+	ids   map[string]int
+}
+
+func (r *fakeUserRepository) Register(_ context.Context, login string, password string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.users[login]; ok {
+		return repository.UnauthorizedUserID, repository.ErrUserConfict
+	}
+
+	r.users[login] = password
+	r.ids[login] = len(r.ids) + 1
+
+	return r.ids[login], nil
+}
+
+func (r *fakeUserRepository) Login(_ context.Context, login string, password string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if stored, ok := r.users[login]; !ok || stored != password {
+		return repository.UnauthorizedUserID, nil
+	}
+
+	return r.ids[login], nil
+}
+
+func (r *fakeUserRepository) GetUser(_ context.Context, userID int) (*models.User, error) {
+	return &models.User{ID: userID, Role: models.RoleUser}, nil
+}
+
+func (r *fakeUserRepository) GetDataVersion(context.Context, int) (models.DataVersion, error) {
+	return models.DataVersion{Version: 1, ModifiedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}, nil
+}
+
+type fakeSessionRepository struct {
+	repository.SessionRepository
+}
+
+func (r *fakeSessionRepository) CreateSession(context.Context, int, string, string) (int, error) {
+	return 1, nil
+}
+
+func (r *fakeSessionRepository) TouchSession(context.Context, int, int) (bool, error) {
+	return true, nil
+}
+
+type fakeMFAService struct {
+	services.MFAService
+}
+
+func (s *fakeMFAService) IsEnabled(context.Context, int) (bool, error) {
+	return false, nil
+}
+
+// fakeWithdrawalRulesService refuses withdrawals above maxSum.
+type fakeWithdrawalRulesService struct {
+	services.WithdrawalRulesService
+	maxSum decimal.Decimal
+}
+
+func (s *fakeWithdrawalRulesService) Withdraw(_ context.Context, _ int, _ string, sum decimal.Decimal, withdraw func(guard repository.WithdrawalGuard) error) error {
+	return withdraw(func(repository.WithdrawalTotalsFunc) error {
+		if sum.GreaterThan(s.maxSum) {
+			return services.ErrWithdrawalAboveMaximum
+		}
+
+		return nil
+	})
+}
+
+type fakeAccrualService struct {
+	services.AccrualService
+}
+
+func (s *fakeAccrualService) QueueStatusUpdate(models.Order) {}
+
+type fakeFraudService struct {
+	services.FraudService
+}
+
+func (s *fakeFraudService) CheckOrderUpload(context.Context, int, string) error {
+	return nil
+}
+
+func (s *fakeFraudService) RecordOrderUploads(context.Context, int, string, ...models.OrderBatchStatus) {
+}
+
+type fakeOrderRepository struct {
+	repository.OrderRepository
+	orders []models.Order
+}
+
+func (r *fakeOrderRepository) GetOrder(_ context.Context, orderNum string) (*models.Order, error) {
+	for _, order := range r.orders {
+		if order.OrderNum == orderNum {
+			return &order, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r *fakeOrderRepository) AddOrder(_ context.Context, userID int, orderNum string) error {
+	accrual := decimal.NewFromInt(500)
+	r.orders = append(r.orders, models.Order{
+		UserID:        userID,
+		OrderNum:      orderNum,
+		UploadedAt:    models.RFC3339Time(time.Now()),
+		AccrualStatus: models.AccrualStatusProcessed,
+		Accrual:       &accrual,
+	})
+
+	return nil
+}
+
+func (r *fakeOrderRepository) AddOrders(ctx context.Context, userID int, orderNums []string) ([]models.OrderBatchResult, error) {
+	results := make([]models.OrderBatchResult, 0, len(orderNums))
+	for _, orderNum := range orderNums {
+		result := models.OrderBatchResult{Number: orderNum, Status: models.OrderBatchStatusAccepted}
+		if existing, _ := r.GetOrder(ctx, orderNum); existing != nil {
+			result.Status = models.OrderBatchStatusConflict
+			if existing.UserID == userID {
+				result.Status = models.OrderBatchStatusDuplicateOwn
+			}
+		} else {
+			_ = r.AddOrder(ctx, userID, orderNum)
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (r *fakeOrderRepository) GetUserOrders(_ context.Context, userID int) ([]models.Order, error) {
+	orders := make([]models.Order, 0)
+	for _, order := range r.orders {
+		if order.UserID == userID {
+			orders = append(orders, order)
+		}
+	}
+
+	return orders, nil
+}
+
+func (r *fakeOrderRepository) GetUserPendingOrders(ctx context.Context, userID int) ([]models.Order, error) {
+	orders, _ := r.GetUserOrders(ctx, userID)
+	pending := make([]models.Order, 0)
+	for _, order := range orders {
+		if !helpers.IsOrderAccrualCalculated(order.AccrualStatus) {
+			pending = append(pending, order)
+		}
+	}
+
+	return pending, nil
+}
+
+func (r *fakeOrderRepository) GetUserOrdersPage(ctx context.Context, userID int, limit int, offset int) ([]models.Order, int, error) {
+	orders, _ := r.GetUserOrders(ctx, userID)
+
+	return orders[min(offset, len(orders)):min(offset+limit, len(orders))], len(orders), nil
+}
+
+type fakePointsRepository struct {
+	repository.PointsRepository
+	balance     decimal.Decimal
+	withdrawals []models.WithdrawHistoryEntry
+}
+
+func (r *fakePointsRepository) GetUserBalance(context.Context, int) (decimal.Decimal, error) {
+	return r.balance, nil
+}
+
+func (r *fakePointsRepository) GetUserWithdrawalHistory(context.Context, int) ([]models.WithdrawHistoryEntry, error) {
+	return r.withdrawals, nil
+}
+
+func (r *fakePointsRepository) GetUserWithdrawalsPage(_ context.Context, _ int, limit int, offset int) ([]models.WithdrawHistoryEntry, int, error) {
+	return r.withdrawals[min(offset, len(r.withdrawals)):min(offset+limit, len(r.withdrawals))], len(r.withdrawals), nil
+}
+
+func (r *fakePointsRepository) WithdrawPoints(_ context.Context, _ int, orderNum string, amount decimal.Decimal, guard repository.WithdrawalGuard) error {
+	if err := guard(func(time.Time) (models.WithdrawalTotals, error) { return models.WithdrawalTotals{}, nil }); err != nil {
+		return err
+	}
+
+	if r.balance.LessThan(amount) {
+		return repository.ErrNotEnoughPoints
+	}
+
+	r.balance = r.balance.Sub(amount)
+	r.withdrawals = append(r.withdrawals, models.WithdrawHistoryEntry{
+		OrderNum:    orderNum,
+		WithdrawSum: amount,
+		ProcessedAt: models.RFC3339Time(time.Now()),
+	})
+
+	return nil
+}
+
+func newTestRouter(t *testing.T, spec *openapi.Spec) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	tm, err := auth.NewJWTTokenManager([]byte("secret"))
+	require.NoError(t, err)
+
+	userRepository := &fakeUserRepository{users: make(map[string]string), ids: make(map[string]int)}
+	sessionRepository := &fakeSessionRepository{}
+	mfaService := &fakeMFAService{}
+	withdrawalRules := &fakeWithdrawalRulesService{maxSum: decimal.NewFromInt(800)}
+	fraudService := &fakeFraudService{}
+	registrationValidator := validation.NewRegistrationValidator(validation.RegistrationPolicy{
+		LoginMinLength:    3,
+		LoginMaxLength:    64,
+		LoginPattern:      regexp.MustCompile(`^[\p{L}\p{N}._@+-]+$`),
+		PasswordMinLength: 8,
+		PasswordMaxLength: 72,
+	})
+	authUser := middleware.AuthUser(tm, sessionRepository, middleware.CookieSettings{})
+	conditionalGET := middleware.ConditionalGET(userRepository)
+	rateLimit := middleware.RateLimit(middleware.NewMemoryRateLimitStore(), middleware.RateLimitPolicy{
+		Default: models.RateLimit{Requests: 1000, Period: time.Minute},
+		Routes: map[string]models.RateLimit{
+			"POST /api/user/login/mfa": {Requests: 1, Period: time.Minute},
+		},
+	}, zap.NewNop())
+
+	r := gin.New()
+	r.Use(problems.Middleware())
+	if spec != nil {
+		r.Use(openapi.ValidateRequests(spec))
+	}
+
+	routes.RegisterAuthHandlers(r, handlers.NewAuthHandlers(userRepository, sessionRepository, tm, registrationValidator, mfaService, middleware.CookieSettings{}), rateLimit)
+	routes.RegisterOrderHandlers(r, handlers.NewOrderHandlers(&fakeOrderRepository{}, &fakeAccrualService{}, fraudService, 3), authUser, rateLimit, conditionalGET)
+	routes.RegisterOrderEventHandlers(r, handlers.NewOrderEventHandlers(events.NewHub()), authUser, rateLimit)
+	routes.RegisterBalanceStreamHandlers(r, handlers.NewBalanceStreamHandlers(&fakePointsRepository{}, events.NewHub(), 1), authUser, rateLimit)
+	routes.RegisterPointsHandlers(r, handlers.NewPointsHandlers(&fakePointsRepository{balance: decimal.NewFromInt(1000)}, mfaService, decimal.NewFromInt(1000), withdrawalRules), authUser, rateLimit, conditionalGET)
+	routes.RegisterV2Handlers(r, handlers.NewV2Handlers(&fakeOrderRepository{}, &fakePointsRepository{balance: decimal.NewFromInt(1000)}, &fakeAccrualService{}, mfaService, decimal.NewFromInt(1000), withdrawalRules, fraudService), authUser, rateLimit, conditionalGET)
+	routes.RegisterGraphQLHandlers(r, handlers.NewGraphQLHandlers(graph.NewServer(userRepository, &fakeOrderRepository{}, &fakePointsRepository{}, &fakeAccrualService{}, mfaService, decimal.NewFromInt(1000), withdrawalRules, fraudService, 6, 500, zap.NewNop())), authUser, rateLimit)
+
+	return r
+}
+
+func TestSpecCoversRoutes(t *testing.T) {
+	spec, err := openapi.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "3.1.0", spec.OpenAPI)
+
+	r := newTestRouter(t, nil)
+
+	registered := make(map[string]bool)
+	for _, route := range r.Routes() {
+		registered[route.Method+" "+route.Path] = true
+		assert.NotNil(t, spec.Operation(route.Method, route.Path), "route %s %s is not documented", route.Method, route.Path)
+	}
+
+	toRoutePath := strings.NewReplacer("{", ":", "}", "")
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			assert.True(t, registered[strings.ToUpper(method)+" "+toRoutePath.Replace(path)], "documented operation %s %s is not registered", method, path)
+		}
+	}
+}
+
+func TestHandlersConformToSpec(t *testing.T) {
+	decimal.MarshalJSONWithoutQuotes = true
+
+	spec, err := openapi.Load()
+	require.NoError(t, err)
+
+	r := newTestRouter(t, nil)
+
+	register := httptest.NewRecorder()
+	r.ServeHTTP(register, newRequest(http.MethodPost, "/api/user/register", "application/json", `{"login":"gopher","password":"dont-panic-42"}`, ""))
+	require.Equal(t, http.StatusOK, register.Code)
+	token := register.Header().Get("Authorization")
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		token       string
+		wantStatus  int
+	}{
+		{"register with weak password", http.MethodPost, "/api/user/register", "application/json", `{"login":"gopher2","password":"short"}`, "", http.StatusBadRequest},
+		{"register taken login", http.MethodPost, "/api/user/register", "application/json", `{"login":"gopher","password":"dont-panic-42"}`, "", http.StatusConflict},
+		{"login", http.MethodPost, "/api/user/login", "application/json", `{"login":"gopher","password":"dont-panic-42"}`, "", http.StatusOK},
+		{"login with wrong password", http.MethodPost, "/api/user/login", "application/json", `{"login":"gopher","password":"wrong"}`, "", http.StatusUnauthorized},
+		{"login with expired challenge", http.MethodPost, "/api/user/login/mfa", "application/json", `{"mfa_token":"expired","code":"123456"}`, "", http.StatusUnauthorized},
+		{"login with challenge too often", http.MethodPost, "/api/user/login/mfa", "application/json", `{"mfa_token":"expired","code":"123456"}`, "", http.StatusTooManyRequests},
+		{"no orders yet", http.MethodGet, "/api/user/orders", "", "", token, http.StatusNoContent},
+		{"upload order", http.MethodPost, "/api/user/orders", "text/plain", "12345678903", token, http.StatusAccepted},
+		{"upload same order", http.MethodPost, "/api/user/orders", "text/plain", "12345678903", token, http.StatusOK},
+		{"upload invalid order", http.MethodPost, "/api/user/orders", "text/plain", "12345678904", token, http.StatusUnprocessableEntity},
+		{"upload order batch", http.MethodPost, "/api/user/orders/batch", "application/json", `["12345678903","2377225624","12345678904"]`, token, http.StatusOK},
+		{"upload newline-delimited order batch", http.MethodPost, "/api/user/orders/batch", "text/plain", "2377225624\n\n49927398716\n", token, http.StatusOK},
+		{"upload oversized order batch", http.MethodPost, "/api/user/orders/batch", "application/json", `["12345678903","2377225624","49927398716","79927398713"]`, token, http.StatusBadRequest},
+		{"upload empty order batch", http.MethodPost, "/api/user/orders/batch", "text/plain", "", token, http.StatusBadRequest},
+		{"list orders", http.MethodGet, "/api/user/orders", "", "", token, http.StatusOK},
+		{"list orders unauthenticated", http.MethodGet, "/api/user/orders", "", "", "", http.StatusUnauthorized},
+		{"no withdrawals yet", http.MethodGet, "/api/user/withdrawals", "", "", token, http.StatusNoContent},
+		{"withdraw", http.MethodPost, "/api/user/balance/withdraw", "application/json", `{"order":"2377225624","sum":751}`, token, http.StatusOK},
+		{"withdraw too much", http.MethodPost, "/api/user/balance/withdraw", "application/json", `{"order":"2377225624","sum":751}`, token, http.StatusPaymentRequired},
+		{"withdraw above maximum", http.MethodPost, "/api/user/balance/withdraw", "application/json", `{"order":"2377225624","sum":900}`, token, http.StatusUnprocessableEntity},
+		{"withdraw to invalid order", http.MethodPost, "/api/user/balance/withdraw", "application/json", `{"order":"2377225625","sum":1}`, token, http.StatusUnprocessableEntity},
+		{"balance", http.MethodGet, "/api/user/balance", "", "", token, http.StatusOK},
+		{"list withdrawals", http.MethodGet, "/api/user/withdrawals", "", "", token, http.StatusOK},
+		{"v2 no orders yet", http.MethodGet, "/api/v2/orders", "", "", token, http.StatusOK},
+		{"v2 upload order", http.MethodPost, "/api/v2/orders", "application/json", `{"number":"12345678903"}`, token, http.StatusCreated},
+		{"v2 upload same order", http.MethodPost, "/api/v2/orders", "application/json", `{"number":"12345678903"}`, token, http.StatusOK},
+		{"v2 upload invalid order", http.MethodPost, "/api/v2/orders", "application/json", `{"number":"12345678904"}`, token, http.StatusUnprocessableEntity},
+		{"v2 upload malformed body", http.MethodPost, "/api/v2/orders", "application/json", `12345678903`, token, http.StatusBadRequest},
+		{"v2 list orders", http.MethodGet, "/api/v2/orders?limit=1", "", "", token, http.StatusOK},
+		{"v2 list orders with invalid limit", http.MethodGet, "/api/v2/orders?limit=1000", "", "", token, http.StatusBadRequest},
+		{"v2 get order", http.MethodGet, "/api/v2/orders/12345678903", "", "", token, http.StatusOK},
+		{"v2 get missing order", http.MethodGet, "/api/v2/orders/2377225624", "", "", token, http.StatusNotFound},
+		{"v2 withdraw", http.MethodPost, "/api/v2/withdrawals", "application/json", `{"order":"2377225624","sum":751}`, token, http.StatusCreated},
+		{"v2 withdraw too much", http.MethodPost, "/api/v2/withdrawals", "application/json", `{"order":"2377225624","sum":751}`, token, http.StatusPaymentRequired},
+		{"v2 balance", http.MethodGet, "/api/v2/balance", "", "", token, http.StatusOK},
+		{"v2 list withdrawals", http.MethodGet, "/api/v2/withdrawals", "", "", token, http.StatusOK},
+		{"graphql query", http.MethodPost, "/graphql", "application/json", `{"query":"{ __typename }"}`, token, http.StatusOK},
+		{"graphql too complex query", http.MethodPost, "/graphql", "application/json", `{"query":"{ me { orders(first: 100) { number status accrual uploadedAt } withdrawals(first: 100) { order sum processedAt } } }"}`, token, http.StatusOK},
+		{"graphql malformed body", http.MethodPost, "/graphql", "application/json", `{"variables":{}}`, token, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, newRequest(tt.method, tt.path, tt.contentType, tt.body, tt.token))
+
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+
+			operation := findOperation(spec, tt.method, tt.path)
+			require.NotNil(t, operation)
+
+			response := spec.Response(operation, w.Code)
+			require.NotNil(t, response, "status %d is not documented", w.Code)
+
+			for name := range response.Headers {
+				assert.NotEmpty(t, w.Header().Get(name), "documented header %s is missing", name)
+			}
+
+			if len(response.Content) == 0 {
+				assert.Empty(t, w.Body.String())
+				return
+			}
+
+			mediaType, _, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+			require.NoError(t, err)
+
+			content, ok := response.Content[mediaType]
+			require.True(t, ok, "content type %s is not documented", mediaType)
+
+			var body any
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Empty(t, spec.Validate(content.Schema, body, ""))
+		})
+	}
+}
+
+func TestValidateRequests(t *testing.T) {
+	spec, err := openapi.Load()
+	require.NoError(t, err)
+
+	r := newTestRouter(t, spec)
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantFields  []string
+	}{
+		{"missing password", "/api/user/register", "application/json", `{"login":"gopher"}`, http.StatusBadRequest, []string{"password"}},
+		{"wrong type", "/api/user/register", "application/json", `{"login":"gopher","password":42}`, http.StatusBadRequest, []string{"password"}},
+		{"malformed JSON", "/api/user/register", "application/json", `{"login":`, http.StatusBadRequest, []string{validation.FieldBody}},
+		{"non-numeric order", "/api/user/orders", "text/plain", "abc", http.StatusBadRequest, []string{validation.FieldBody}},
+		{"unsupported media type", "/api/user/register", "text/plain", "gopher", http.StatusUnsupportedMediaType, nil},
+		{"valid request", "/api/user/register", "application/json", `{"login":"gopher","password":"dont-panic-42"}`, http.StatusOK, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, newRequest(http.MethodPost, tt.path, tt.contentType, tt.body, ""))
+
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+
+			if tt.wantFields == nil {
+				return
+			}
+
+			var problem models.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+
+			fields := make([]string, 0, len(problem.Errors))
+			for _, fieldErr := range problem.Errors {
+				fields = append(fields, fieldErr.Field)
+			}
+			assert.Equal(t, tt.wantFields, fields)
+		})
+	}
+}
+
+// findOperation matches a request path such as /api/v2/orders/42?limit=1 against the documented templates.
+func findOperation(spec *openapi.Spec, method string, requestPath string) *openapi.Operation {
+	requestPath, _, _ = strings.Cut(requestPath, "?")
+	segments := strings.Split(requestPath, "/")
+
+	for path := range spec.Paths {
+		templateSegments := strings.Split(path, "/")
+		if len(templateSegments) != len(segments) {
+			continue
+		}
+
+		matches := true
+		for i, segment := range templateSegments {
+			if segment != segments[i] && !strings.HasPrefix(segment, "{") {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			return spec.Operation(method, path)
+		}
+	}
+
+	return nil
+}
+
+func newRequest(method string, path string, contentType string, body string, token string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	return req
+}
@@ -0,0 +1,173 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rovany706/loyalty-gopher/internal/validation"
+)
+
+// Schema is the subset of JSON Schema 2020-12 used by the document.
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 SchemaType         `json:"type"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	Enum                 []any              `json:"enum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Pattern              string             `json:"pattern"`
+	Minimum              *float64           `json:"minimum"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum"`
+	Format               string             `json:"format"`
+}
+
+// SchemaType holds "type", which OpenAPI 3.1 allows to be a string or a list.
+type SchemaType []string
+
+func (t *SchemaType) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = SchemaType{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+
+	*t = multiple
+
+	return nil
+}
+
+// Validate checks a decoded JSON value against the schema and returns
+// every violation with the path to the offending field.
+func (s *Spec) Validate(schema *Schema, value any, field string) validation.Errors {
+	var errs validation.Errors
+	s.validate(schema, value, field, &errs)
+
+	return errs
+}
+
+func (s *Spec) validate(schema *Schema, value any, field string, errs *validation.Errors) {
+	schema = s.resolve(schema)
+	if schema == nil {
+		return
+	}
+
+	addErr := func(code string, format string, args ...any) {
+		*errs = append(*errs, validation.FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(schema.Type) > 0 && !slices.Contains(schema.Type, jsonType(value)) &&
+		!(jsonType(value) == "integer" && slices.Contains(schema.Type, "number")) {
+		addErr(validation.CodeInvalidType, "must be of type %s", strings.Join(schema.Type, " or "))
+		return
+	}
+
+	if len(schema.Enum) > 0 && !slices.ContainsFunc(schema.Enum, func(allowed any) bool { return reflect.DeepEqual(allowed, value) }) {
+		addErr(validation.CodeNotAllowed, "must be one of %v", schema.Enum)
+	}
+
+	switch v := value.(type) {
+	case string:
+		length := len([]rune(v))
+		if schema.MinLength != nil && length < *schema.MinLength {
+			addErr(validation.CodeTooShort, "must be at least %d characters long", *schema.MinLength)
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			addErr(validation.CodeTooLong, "must be at most %d characters long", *schema.MaxLength)
+		}
+		if schema.Pattern != "" {
+			if pattern, err := regexp.Compile(schema.Pattern); err == nil && !pattern.MatchString(v) {
+				addErr(validation.CodeInvalidFormat, "must match %s", schema.Pattern)
+			}
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				addErr(validation.CodeInvalidFormat, "must be an RFC 3339 date-time")
+			}
+		}
+	case float64:
+		if schema.Minimum != nil && v < *schema.Minimum {
+			addErr(validation.CodeOutOfRange, "must be at least %v", *schema.Minimum)
+		}
+		if schema.ExclusiveMinimum != nil && v <= *schema.ExclusiveMinimum {
+			addErr(validation.CodeOutOfRange, "must be greater than %v", *schema.ExclusiveMinimum)
+		}
+	case []any:
+		for i, item := range v {
+			s.validate(schema.Items, item, fmt.Sprintf("%s[%d]", field, i), errs)
+		}
+	case map[string]any:
+		for _, name := range schema.Required {
+			if _, ok := v[name]; !ok {
+				*errs = append(*errs, validation.FieldError{Field: joinField(field, name), Code: validation.CodeRequired, Message: "is required"})
+			}
+		}
+
+		for name, property := range v {
+			propertySchema, ok := schema.Properties[name]
+			if !ok {
+				if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+					*errs = append(*errs, validation.FieldError{Field: joinField(field, name), Code: validation.CodeUnknownField, Message: "is not allowed"})
+				}
+				continue
+			}
+
+			s.validate(propertySchema, property, joinField(field, name), errs)
+		}
+	}
+}
+
+func (s *Spec) resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		name, ok := strings.CutPrefix(schema.Ref, "#/components/schemas/")
+		if !ok {
+			return nil
+		}
+
+		schema = s.Components.Schemas[name]
+	}
+
+	return schema
+}
+
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return ""
+	}
+}
+
+func joinField(parent string, name string) string {
+	if parent == "" {
+		return name
+	}
+
+	return parent + "." + name
+}
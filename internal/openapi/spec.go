@@ -0,0 +1,107 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed openapi.json
+var specJSON []byte
+
+type Spec struct {
+	OpenAPI    string                           `json:"openapi"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+type Components struct {
+	Schemas   map[string]*Schema   `json:"schemas"`
+	Responses map[string]*Response `json:"responses"`
+}
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Parameters  []Parameter          `json:"parameters"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Ref         string               `json:"$ref"`
+	Description string               `json:"description"`
+	Headers     map[string]Parameter `json:"headers"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// Load parses the embedded document. Path items may only hold operations.
+func Load() (*Spec, error) {
+	var spec Spec
+	if err := json.Unmarshal(specJSON, &spec); err != nil {
+		return nil, err
+	}
+
+	return &spec, nil
+}
+
+// Handler serves the document at /openapi.json.
+func Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Data(http.StatusOK, "application/json", specJSON)
+	}
+}
+
+// Operation finds the operation for a gin route such as /api/user/sessions/:id.
+func (s *Spec) Operation(method string, routePath string) *Operation {
+	pathItem, ok := s.Paths[toOpenAPIPath(routePath)]
+	if !ok {
+		return nil
+	}
+
+	return pathItem[strings.ToLower(method)]
+}
+
+// Response returns the documented response for the status, following $ref.
+func (s *Spec) Response(operation *Operation, status int) *Response {
+	response, ok := operation.Responses[strconv.Itoa(status)]
+	if !ok {
+		return nil
+	}
+
+	if name, ok := strings.CutPrefix(response.Ref, "#/components/responses/"); ok {
+		return s.Components.Responses[name]
+	}
+
+	return response
+}
+
+func toOpenAPIPath(routePath string) string {
+	segments := strings.Split(routePath, "/")
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			segments[i] = "{" + name + "}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,126 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/validation"
+)
+
+var ErrUnsupportedMediaType = errors.New("request content type is not supported by this operation")
+
+// ValidateRequests rejects requests whose parameters or body do not match
+// the document. Routes the document does not describe are let through.
+func ValidateRequests(spec *Spec) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		operation := spec.Operation(ctx.Request.Method, ctx.FullPath())
+		if operation == nil {
+			ctx.Next()
+			return
+		}
+
+		errs := spec.validateParameters(ctx, operation)
+
+		if operation.RequestBody != nil {
+			bodyErrs, err := spec.validateBody(ctx, operation.RequestBody)
+			if errors.Is(err, ErrUnsupportedMediaType) {
+				ctx.AbortWithError(http.StatusUnsupportedMediaType, err)
+				return
+			}
+
+			if err != nil {
+				ctx.AbortWithError(http.StatusBadRequest, err)
+				return
+			}
+
+			errs = append(errs, bodyErrs...)
+		}
+
+		if len(errs) > 0 {
+			ctx.AbortWithError(http.StatusBadRequest, errs)
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+func (s *Spec) validateParameters(ctx *gin.Context, operation *Operation) validation.Errors {
+	var errs validation.Errors
+
+	for _, parameter := range operation.Parameters {
+		var value string
+		var present bool
+
+		switch parameter.In {
+		case "header":
+			value = ctx.GetHeader(parameter.Name)
+			present = value != ""
+		case "query":
+			value, present = ctx.GetQuery(parameter.Name)
+		case "path":
+			value = ctx.Param(parameter.Name)
+			present = value != ""
+		default:
+			continue
+		}
+
+		if !present {
+			if parameter.Required {
+				errs = append(errs, validation.FieldError{Field: parameter.Name, Code: validation.CodeRequired, Message: "is required"})
+			}
+			continue
+		}
+
+		// parameters arrive as text, so only string schemas are checked
+		if schema := s.resolve(parameter.Schema); schema != nil && (len(schema.Type) == 0 || schema.Type[0] == "string") {
+			errs = append(errs, s.Validate(schema, value, parameter.Name)...)
+		}
+	}
+
+	return errs
+}
+
+// validateBody checks the body and puts it back for the handler.
+func (s *Spec) validateBody(ctx *gin.Context, requestBody *RequestBody) (validation.Errors, error) {
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	if len(body) == 0 {
+		if requestBody.Required {
+			return validation.Errors{{Field: validation.FieldBody, Code: validation.CodeRequired, Message: "request body is required"}}, nil
+		}
+
+		return nil, nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(ctx.ContentType())
+	if err != nil {
+		mediaType = ctx.ContentType()
+	}
+
+	content, ok := requestBody.Content[mediaType]
+	if !ok {
+		return nil, ErrUnsupportedMediaType
+	}
+
+	if mediaType != "application/json" {
+		return s.Validate(content.Schema, string(body), validation.FieldBody), nil
+	}
+
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return validation.Errors{{Field: validation.FieldBody, Code: validation.CodeMalformedBody, Message: "request body must be valid JSON"}}, nil
+	}
+
+	return s.Validate(content.Schema, value, ""), nil
+}
@@ -0,0 +1,103 @@
+package problems
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+)
+
+const (
+	TraceIDContextKey = "trace_id"
+	TraceIDHeader     = "X-Request-ID"
+	traceparentHeader = "traceparent"
+)
+
+var (
+	requestIDPattern   = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+	traceparentPattern = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}$`)
+)
+
+// Middleware assigns every request a trace ID and turns failed responses
+// without a body into application/problem+json documents. It must be
+// registered before the routes.
+func Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		traceID := requestTraceID(ctx.Request)
+		ctx.Set(TraceIDContextKey, traceID)
+		ctx.Header(TraceIDHeader, traceID)
+
+		writer := &deferredErrorWriter{ResponseWriter: ctx.Writer}
+		ctx.Writer = writer
+
+		ctx.Next()
+
+		ctx.Writer = writer.ResponseWriter
+
+		status := writer.Status()
+		if status < http.StatusBadRequest || writer.Written() {
+			return
+		}
+
+		var err error
+		if last := ctx.Errors.Last(); last != nil {
+			err = last.Err
+		}
+
+		code, title, detail, fieldErrors := describe(status, err)
+		body, err := json.Marshal(models.Problem{
+			Type:     typePrefix + code,
+			Title:    title,
+			Status:   status,
+			Detail:   detail,
+			Instance: ctx.Request.URL.Path,
+			Code:     code,
+			TraceID:  traceID,
+			Errors:   fieldErrors,
+		})
+		if err != nil {
+			writer.WriteHeaderNow()
+			return
+		}
+
+		ctx.Header("Content-Type", ContentType)
+		_, _ = writer.Write(body)
+	}
+}
+
+// deferredErrorWriter holds back the headers of failed responses that have
+// no body yet, so that the middleware can still write one after the
+// handler aborted with a bare status.
+type deferredErrorWriter struct {
+	gin.ResponseWriter
+}
+
+func (w *deferredErrorWriter) WriteHeaderNow() {
+	if w.Status() >= http.StatusBadRequest {
+		return
+	}
+
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// requestTraceID reuses the caller's W3C trace or request ID when present.
+func requestTraceID(req *http.Request) string {
+	if match := traceparentPattern.FindStringSubmatch(req.Header.Get(traceparentHeader)); match != nil {
+		return match[1]
+	}
+
+	if requestID := req.Header.Get(TraceIDHeader); requestIDPattern.MatchString(requestID) {
+		return requestID
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,106 @@
+package problems
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(Middleware())
+	r.POST("/withdraw", func(ctx *gin.Context) {
+		ctx.AbortWithError(http.StatusPaymentRequired, repository.ErrNotEnoughPoints)
+	})
+	r.POST("/register", func(ctx *gin.Context) {
+		ctx.AbortWithError(http.StatusBadRequest, validation.Errors{{Field: validation.FieldLogin, Code: validation.CodeTooShort, Message: "too short"}})
+	})
+	r.GET("/protected", func(ctx *gin.Context) {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+	})
+	r.GET("/internal", func(ctx *gin.Context) {
+		ctx.AbortWithError(http.StatusInternalServerError, assert.AnError)
+	})
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		wantStatus    int
+		wantCode      string
+		wantDetail    string
+		wantFieldErrs int
+	}{
+		{"known error", http.MethodPost, "/withdraw", http.StatusPaymentRequired, CodeNotEnoughPoints, repository.ErrNotEnoughPoints.Error(), 0},
+		{"validation errors", http.MethodPost, "/register", http.StatusBadRequest, CodeValidationFailed, "", 1},
+		{"bare status", http.MethodGet, "/protected", http.StatusUnauthorized, "unauthorized", "", 0},
+		{"internal error hides details", http.MethodGet, "/internal", http.StatusInternalServerError, "internal_error", "", 0},
+		{"unknown route", http.MethodGet, "/missing", http.StatusNotFound, "not_found", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+
+			var problem models.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, tt.wantStatus, problem.Status)
+			assert.Equal(t, tt.wantCode, problem.Code)
+			assert.Equal(t, "/problems/"+tt.wantCode, problem.Type)
+			assert.Equal(t, tt.wantDetail, problem.Detail)
+			assert.Equal(t, tt.path, problem.Instance)
+			assert.Len(t, problem.Errors, tt.wantFieldErrs)
+			assert.NotEmpty(t, problem.TraceID)
+			assert.Equal(t, problem.TraceID, w.Header().Get(TraceIDHeader))
+		})
+	}
+}
+
+func TestMiddlewareKeepsSuccessfulResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/orders", func(ctx *gin.Context) {
+		ctx.Status(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set(TraceIDHeader, "client-request-1")
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, "client-request-1", w.Header().Get(TraceIDHeader))
+}
+
+func TestRequestTraceID(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(traceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(TraceIDHeader, "ignored")
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", requestTraceID(req))
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(TraceIDHeader, "bad id with spaces")
+
+	assert.Len(t, requestTraceID(req), 32)
+}
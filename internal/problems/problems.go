@@ -0,0 +1,165 @@
+package problems
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/rovany706/loyalty-gopher/internal/auth"
+	"github.com/rovany706/loyalty-gopher/internal/handlers"
+	"github.com/rovany706/loyalty-gopher/internal/helpers"
+	"github.com/rovany706/loyalty-gopher/internal/middleware"
+	"github.com/rovany706/loyalty-gopher/internal/openapi"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/services"
+	"github.com/rovany706/loyalty-gopher/internal/validation"
+)
+
+const (
+	ContentType = "application/problem+json"
+	typePrefix  = "/problems/"
+)
+
+// Codes are part of the API: never rename one, add a new one instead.
+const (
+	CodeValidationFailed   = "validation_failed"
+	CodeInvalidOrderNumber = "invalid_order_number"
+	CodeNotEnoughPoints    = "not_enough_points"
+	CodeOrderConflict      = "order_conflict"
+	CodeLoginTaken         = "login_taken"
+	CodeUserDisabled       = "user_disabled"
+	CodeUserNotFound       = "user_not_found"
+	CodeSessionNotFound    = "session_not_found"
+	CodeMerchantNotFound   = "merchant_not_found"
+	CodeAPIKeyNotFound     = "api_key_not_found"
+	CodeRedemptionCode     = "invalid_redemption_code"
+	CodeMFAAlreadyEnabled  = "mfa_already_enabled"
+	CodeMFANotEnrolled     = "mfa_not_enrolled"
+	CodeInvalidMFACode     = "invalid_mfa_code"
+	CodeInvalidCSRFToken   = "invalid_csrf_token"
+	CodeDeletionNotPending = "deletion_not_requested"
+	CodeIdentityConflict   = "identity_conflict"
+	CodeIdentityNotLinked  = "identity_not_linked"
+	CodeInvalidOIDCState   = "invalid_oidc_state"
+	CodeInvalidIDToken     = "invalid_id_token"
+	CodeIdentityNoLogin    = "identity_without_login"
+	CodeUnsupportedMedia   = "unsupported_media_type"
+	CodeOrderNotFound      = "order_not_found"
+	CodeTooManyConnections = "too_many_connections"
+	CodeWithdrawalNotPos   = "withdrawal_not_positive"
+	CodeWithdrawalTooSmall = "withdrawal_below_minimum"
+	CodeWithdrawalTooLarge = "withdrawal_above_maximum"
+	CodeWithdrawalPrecise  = "withdrawal_too_precise"
+	CodeDailyWithdrawLimit = "daily_withdrawal_limit_exceeded"
+	CodeHourlyWithdrawals  = "hourly_withdrawal_limit_exceeded"
+	CodeUploadsThrottled   = "order_uploads_throttled"
+	CodeReviewNotFound     = "fraud_review_not_found"
+	CodeInvalidTransferSum = "invalid_transfer_sum"
+	CodeRecipientNotFound  = "recipient_not_found"
+	CodeTransferToSelf     = "transfer_to_self"
+	CodeDailyTransferLimit = "daily_transfer_limit_exceeded"
+	CodeTransferNotFound   = "transfer_not_found"
+	CodeHouseholdNotFound  = "household_not_found"
+	CodeHouseholdName      = "invalid_household_name"
+	CodeNotHouseholdOwner  = "not_household_owner"
+	CodeAlreadyInHousehold = "already_in_household"
+	CodeInviteeNotFound    = "invitee_not_found"
+	CodeMemberNotFound     = "household_member_not_found"
+	CodeOwnerHasMembers    = "household_has_members"
+	CodeAllowanceExceeded  = "household_allowance_exceeded"
+)
+
+type knownError struct {
+	err   error
+	code  string
+	title string
+}
+
+// knownErrors are the errors whose messages are safe to show to clients.
+var knownErrors = []knownError{
+	{helpers.ErrInvalidOrderNumber, CodeInvalidOrderNumber, "Invalid order number"},
+	{repository.ErrNotEnoughPoints, CodeNotEnoughPoints, "Not enough points"},
+	{repository.ErrOrderConflict, CodeOrderConflict, "Order uploaded by another user"},
+	{repository.ErrUserConfict, CodeLoginTaken, "Login already taken"},
+	{repository.ErrUserDisabled, CodeUserDisabled, "User is disabled"},
+	{repository.ErrUserNotFound, CodeUserNotFound, "User not found"},
+	{repository.ErrSessionNotFound, CodeSessionNotFound, "Session not found"},
+	{repository.ErrMerchantNotFound, CodeMerchantNotFound, "Merchant not found"},
+	{repository.ErrAPIKeyNotFound, CodeAPIKeyNotFound, "API key not found"},
+	{repository.ErrInvalidRedemptionCode, CodeRedemptionCode, "Invalid redemption code"},
+	{repository.ErrMFAAlreadyEnabled, CodeMFAAlreadyEnabled, "Two-factor authentication already enabled"},
+	{repository.ErrMFANotEnrolled, CodeMFANotEnrolled, "Two-factor authentication not enrolled"},
+	{services.ErrInvalidMFACode, CodeInvalidMFACode, "Invalid two-factor authentication code"},
+	{middleware.ErrInvalidCSRFToken, CodeInvalidCSRFToken, "Invalid CSRF token"},
+	{repository.ErrDeletionNotRequested, CodeDeletionNotPending, "Account deletion not requested"},
+	{repository.ErrIdentityConflict, CodeIdentityConflict, "External identity linked to another user"},
+	{services.ErrOIDCUserNotLinked, CodeIdentityNotLinked, "External identity not linked"},
+	{services.ErrInvalidOIDCState, CodeInvalidOIDCState, "Invalid sign in state"},
+	{auth.ErrInvalidIDToken, CodeInvalidIDToken, "Invalid identity provider token"},
+	{auth.ErrOIDCExchange, CodeInvalidIDToken, "Invalid identity provider token"},
+	{services.ErrOIDCNoLogin, CodeIdentityNoLogin, "Identity provider returned no login"},
+	{openapi.ErrUnsupportedMediaType, CodeUnsupportedMedia, "Unsupported media type"},
+	{repository.ErrOrderNotFound, CodeOrderNotFound, "Order not found"},
+	{handlers.ErrTooManyConnections, CodeTooManyConnections, "Too many open connections"},
+	{services.ErrWithdrawalNotPositive, CodeWithdrawalNotPos, "Withdrawal sum not positive"},
+	{services.ErrWithdrawalBelowMinimum, CodeWithdrawalTooSmall, "Withdrawal below minimum"},
+	{services.ErrWithdrawalAboveMaximum, CodeWithdrawalTooLarge, "Withdrawal above maximum"},
+	{services.ErrWithdrawalTooPrecise, CodeWithdrawalPrecise, "Withdrawal sum too precise"},
+	{services.ErrDailyWithdrawalLimit, CodeDailyWithdrawLimit, "Daily withdrawal limit exceeded"},
+	{services.ErrHourlyWithdrawalLimit, CodeHourlyWithdrawals, "Hourly withdrawal limit exceeded"},
+	{services.ErrOrderUploadsThrottled, CodeUploadsThrottled, "Order uploads throttled"},
+	{repository.ErrFraudReviewNotFound, CodeReviewNotFound, "Fraud review not found"},
+	{services.ErrInvalidTransferSum, CodeInvalidTransferSum, "Invalid transfer sum"},
+	{repository.ErrRecipientNotFound, CodeRecipientNotFound, "Recipient not found"},
+	{repository.ErrTransferToSelf, CodeTransferToSelf, "Transfer to yourself"},
+	{repository.ErrDailyTransferLimit, CodeDailyTransferLimit, "Daily transfer limit exceeded"},
+	{repository.ErrTransferNotFound, CodeTransferNotFound, "Transfer not found"},
+	{repository.ErrHouseholdNotFound, CodeHouseholdNotFound, "Household not found"},
+	{handlers.ErrInvalidHouseholdName, CodeHouseholdName, "Invalid household name"},
+	{repository.ErrNotHouseholdOwner, CodeNotHouseholdOwner, "Not the household owner"},
+	{repository.ErrAlreadyInHousehold, CodeAlreadyInHousehold, "Already in a household"},
+	{repository.ErrInviteeNotFound, CodeInviteeNotFound, "User to invite not found"},
+	{repository.ErrHouseholdMemberNotFound, CodeMemberNotFound, "Household member not found"},
+	{repository.ErrOwnerHasMembers, CodeOwnerHasMembers, "Household still has members"},
+	{repository.ErrAllowanceExceeded, CodeAllowanceExceeded, "Household allowance exceeded"},
+}
+
+// statusCodes describe failures that carry no known error.
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusPaymentRequired:       "payment_required",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusRequestEntityTooLarge: "request_too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusUnprocessableEntity:   "unprocessable_entity",
+	http.StatusTooManyRequests:       "too_many_requests",
+	http.StatusInternalServerError:   "internal_error",
+	http.StatusBadGateway:            "bad_gateway",
+	http.StatusServiceUnavailable:    "service_unavailable",
+}
+
+// describe returns the code, title and detail for a failed request. Details
+// of unknown errors are never shown, they may contain internals.
+func describe(status int, err error) (code string, title string, detail string, fieldErrors validation.Errors) {
+	if err != nil {
+		if errors.As(err, &fieldErrors) {
+			return CodeValidationFailed, "Request validation failed", "", fieldErrors
+		}
+
+		for _, known := range knownErrors {
+			if errors.Is(err, known.err) {
+				return known.code, known.title, known.err.Error(), nil
+			}
+		}
+	}
+
+	code, ok := statusCodes[status]
+	if !ok {
+		code = "error"
+	}
+
+	return code, http.StatusText(status), "", nil
+}
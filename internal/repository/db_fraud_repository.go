@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/rovany706/loyalty-gopher/internal/database"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"go.uber.org/zap"
+)
+
+type DBFraudRepository struct {
+	db     *database.Database
+	logger *zap.Logger
+}
+
+func NewDBFraudRepository(db *database.Database, logger *zap.Logger) *DBFraudRepository {
+	return &DBFraudRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *DBFraudRepository) RecordOrderUploads(ctx context.Context, userID int, clientIP string, outcomes []models.OrderBatchStatus, now time.Time) error {
+	tx, err := r.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, outcome := range outcomes {
+		_, err := tx.ExecContext(ctx, "INSERT INTO order_upload_events (user_id, client_ip, outcome, created_at) VALUES ($1, $2, $3, $4)", userID, clientIP, outcome, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *DBFraudRepository) GetUserUploadStats(ctx context.Context, userID int, since time.Time) (models.OrderUploadStats, error) {
+	return r.getUploadStats(ctx, "user_id", userID, since)
+}
+
+func (r *DBFraudRepository) GetIPUploadStats(ctx context.Context, clientIP string, since time.Time) (models.OrderUploadStats, error) {
+	return r.getUploadStats(ctx, "client_ip", clientIP, since)
+}
+
+func (r *DBFraudRepository) getUploadStats(ctx context.Context, column string, value any, since time.Time) (models.OrderUploadStats, error) {
+	row := r.db.DBConnection.QueryRowContext(ctx, `SELECT COUNT(*),
+												   COUNT(*) FILTER (WHERE outcome=$3),
+												   COUNT(*) FILTER (WHERE outcome=$4)
+												   FROM order_upload_events
+												   WHERE `+column+`=$1 AND created_at >= $2`, value, since, models.OrderBatchStatusConflict, models.OrderBatchStatusInvalid)
+
+	var stats models.OrderUploadStats
+	if err := row.Scan(&stats.Uploads, &stats.Conflicts, &stats.Invalid); err != nil {
+		return models.OrderUploadStats{}, err
+	}
+
+	return stats, nil
+}
+
+func (r *DBFraudRepository) Throttle(ctx context.Context, subject string, until time.Time) error {
+	_, err := r.db.DBConnection.ExecContext(ctx, `INSERT INTO fraud_throttles (subject, until) VALUES ($1, $2)
+												 ON CONFLICT (subject) DO UPDATE SET until=GREATEST(fraud_throttles.until, EXCLUDED.until)`, subject, until)
+
+	return err
+}
+
+func (r *DBFraudRepository) GetThrottledUntil(ctx context.Context, subjects []string, now time.Time) (time.Time, error) {
+	row := r.db.DBConnection.QueryRowContext(ctx, "SELECT MAX(until) FROM fraud_throttles WHERE subject = ANY($1) AND until > $2", subjects, now)
+
+	var until sql.NullTime
+	if err := row.Scan(&until); err != nil {
+		return time.Time{}, err
+	}
+
+	return until.Time, nil
+}
+
+func (r *DBFraudRepository) ClearUser(ctx context.Context, userID int) error {
+	tx, err := r.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM fraud_throttles WHERE subject=$1", models.FraudUserSubject(userID)); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM order_upload_events WHERE user_id=$1", userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *DBFraudRepository) FlagUser(ctx context.Context, userID int, score int, stats models.OrderUploadStats, now time.Time) error {
+	_, err := r.db.DBConnection.ExecContext(ctx, `INSERT INTO fraud_reviews (user_id, score, uploads, conflicts, invalid, flagged_at)
+												 VALUES ($1, $2, $3, $4, $5, $6)
+												 ON CONFLICT (user_id) WHERE status='pending' DO UPDATE
+												 SET score=EXCLUDED.score, uploads=EXCLUDED.uploads, conflicts=EXCLUDED.conflicts, invalid=EXCLUDED.invalid
+												 WHERE fraud_reviews.score < EXCLUDED.score`,
+		userID, score, stats.Uploads, stats.Conflicts, stats.Invalid, now)
+
+	return err
+}
+
+func (r *DBFraudRepository) GetFraudReviews(ctx context.Context, status models.FraudReviewStatus) ([]models.FraudReview, error) {
+	rows, err := r.db.DBConnection.QueryContext(ctx, `SELECT id, user_id, score, uploads, conflicts, invalid, status, flagged_at, resolved_at, resolved_by
+													  FROM fraud_reviews
+													  WHERE status=$1
+													  ORDER BY score DESC, flagged_at`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := make([]models.FraudReview, 0)
+	for rows.Next() {
+		var review models.FraudReview
+		if err := rows.Scan(&review.ID, &review.UserID, &review.Score, &review.Uploads, &review.Conflicts, &review.Invalid, &review.Status, &review.FlaggedAt, &review.ResolvedAt, &review.ResolvedBy); err != nil {
+			return nil, err
+		}
+
+		reviews = append(reviews, review)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reviews, nil
+}
+
+func (r *DBFraudRepository) ResolveFraudReview(ctx context.Context, reviewID int, status models.FraudReviewStatus, resolvedBy int, now time.Time) (*models.FraudReview, error) {
+	row := r.db.DBConnection.QueryRowContext(ctx, `UPDATE fraud_reviews
+												   SET status=$2, resolved_at=$3, resolved_by=$4
+												   WHERE id=$1 AND status='pending'
+												   RETURNING id, user_id, score, uploads, conflicts, invalid, status, flagged_at, resolved_at, resolved_by`,
+		reviewID, status, now, resolvedBy)
+
+	var review models.FraudReview
+	err := row.Scan(&review.ID, &review.UserID, &review.Score, &review.Uploads, &review.Conflicts, &review.Invalid, &review.Status, &review.FlaggedAt, &review.ResolvedAt, &review.ResolvedBy)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrFraudReviewNotFound
+		}
+
+		return nil, err
+	}
+
+	return &review, nil
+}
+
+func (r *DBFraudRepository) PruneFraudData(ctx context.Context, before time.Time, now time.Time) error {
+	if _, err := r.db.DBConnection.ExecContext(ctx, "DELETE FROM order_upload_events WHERE created_at < $1", before); err != nil {
+		return err
+	}
+
+	_, err := r.db.DBConnection.ExecContext(ctx, "DELETE FROM fraud_throttles WHERE until <= $1", now)
+
+	return err
+}
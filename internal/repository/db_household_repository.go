@@ -0,0 +1,490 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rovany706/loyalty-gopher/internal/database"
+	"github.com/rovany706/loyalty-gopher/internal/events"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+type DBHouseholdRepository struct {
+	db        *database.Database
+	publisher events.Publisher
+	logger    *zap.Logger
+}
+
+func NewDBHouseholdRepository(db *database.Database, publisher events.Publisher, logger *zap.Logger) *DBHouseholdRepository {
+	return &DBHouseholdRepository{
+		db:        db,
+		publisher: publisher,
+		logger:    logger,
+	}
+}
+
+func (r *DBHouseholdRepository) CreateHousehold(ctx context.Context, ownerID int, name string) (*models.Household, error) {
+	tx, err := r.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	accountID, err := createPointAccount(ctx, tx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var householdID int
+	row := tx.QueryRowContext(ctx, "INSERT INTO households (name, point_account_id) VALUES ($1, $2) RETURNING id", name, accountID)
+	if err := row.Scan(&householdID); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO household_members (household_id, user_id, role, status, joined_at)
+								  VALUES ($1, $2, $3, $4, NOW())`, householdID, ownerID, models.HouseholdRoleOwner, models.HouseholdMemberActive)
+	if err != nil {
+		return nil, householdMemberError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	r.logger.Info("created household", zap.Int("household_id", householdID), zap.Int("owner_id", ownerID))
+
+	return r.GetUserHousehold(ctx, ownerID)
+}
+
+func (r *DBHouseholdRepository) GetUserHousehold(ctx context.Context, userID int) (*models.Household, error) {
+	var household models.Household
+	row := r.db.DBConnection.QueryRowContext(ctx, `SELECT H.id, H.name, P.balance
+												   FROM household_members AS M
+												   JOIN households AS H ON H.id = M.household_id
+												   JOIN point_accounts AS P ON P.id = H.point_account_id
+												   WHERE M.user_id=$1`, userID)
+	if err := row.Scan(&household.ID, &household.Name, &household.Balance); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrHouseholdNotFound
+		}
+		return nil, err
+	}
+
+	rows, err := r.db.DBConnection.QueryContext(ctx, `SELECT M.user_id, U.username, M.role, M.status, M.allowance, M.invited_at, M.joined_at
+													  FROM household_members AS M
+													  JOIN users AS U ON U.id = M.user_id
+													  WHERE M.household_id=$1
+													  ORDER BY M.role='owner' DESC, M.invited_at, M.user_id`, household.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	household.Members = make([]models.HouseholdMember, 0)
+
+	for rows.Next() {
+		var member models.HouseholdMember
+		if err := rows.Scan(&member.UserID, &member.Login, &member.Role, &member.Status, &member.Allowance, &member.InvitedAt, &member.JoinedAt); err != nil {
+			return nil, err
+		}
+
+		household.Members = append(household.Members, member)
+	}
+
+	rerr := rows.Close()
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &household, nil
+}
+
+// InviteMember adds the user with no allowance, the owner grants one separately.
+func (r *DBHouseholdRepository) InviteMember(ctx context.Context, ownerID int, login string) (*models.Household, error) {
+	tx, err := r.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	householdID, err := ownedHousehold(ctx, tx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var inviteeID int
+	row := tx.QueryRowContext(ctx, "SELECT id FROM users WHERE LOWER(username)=LOWER($1) AND disabled_at IS NULL AND deleted_at IS NULL", login)
+	if err := row.Scan(&inviteeID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInviteeNotFound
+		}
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO household_members (household_id, user_id, role, status, allowance)
+								  VALUES ($1, $2, $3, $4, 0)`, householdID, inviteeID, models.HouseholdRoleMember, models.HouseholdMemberInvited)
+	if err != nil {
+		return nil, householdMemberError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return r.GetUserHousehold(ctx, ownerID)
+}
+
+func (r *DBHouseholdRepository) AcceptInvitation(ctx context.Context, userID int) (*models.Household, error) {
+	result, err := r.db.DBConnection.ExecContext(ctx, "UPDATE household_members SET status=$2, joined_at=NOW() WHERE user_id=$1 AND status=$3",
+		userID, models.HouseholdMemberActive, models.HouseholdMemberInvited)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if affected == 0 {
+		return nil, ErrHouseholdNotFound
+	}
+
+	return r.GetUserHousehold(ctx, userID)
+}
+
+func (r *DBHouseholdRepository) LeaveHousehold(ctx context.Context, userID int) error {
+	tx, err := r.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var householdID int
+	var role models.HouseholdRole
+	row := tx.QueryRowContext(ctx, "SELECT household_id, role FROM household_members WHERE user_id=$1 FOR UPDATE", userID)
+	if err := row.Scan(&householdID, &role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrHouseholdNotFound
+		}
+		return err
+	}
+
+	var closedPool decimal.Decimal
+	if role == models.HouseholdRoleOwner {
+		closedPool, err = closeHousehold(ctx, tx, householdID, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// a closed household keeps its row, the pool account and its history still refer to it
+	if _, err := tx.ExecContext(ctx, "DELETE FROM household_members WHERE user_id=$1", userID); err != nil {
+		return err
+	}
+
+	if closedPool.IsPositive() {
+		if err := bumpDataVersion(ctx, tx, userID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if closedPool.IsPositive() {
+		r.logger.Info("closed household", zap.Int("household_id", householdID), zap.String("pool", closedPool.String()))
+		r.publisher.PublishBalanceDelta(userID, models.BalanceDelta{
+			Current:   closedPool,
+			Withdrawn: decimal.Zero,
+			Reason:    models.BalanceChangeTransfer,
+		})
+	}
+
+	return nil
+}
+
+// closeHousehold moves the pool of a household without other members to the owner's
+// account, recorded as a completed transfer so statements account for it. The pool
+// is locked before the account of the owner, as in every transaction touching both.
+func closeHousehold(ctx context.Context, tx *sql.Tx, householdID int, ownerID int) (decimal.Decimal, error) {
+	var others int
+	row := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM household_members WHERE household_id=$1 AND user_id<>$2", householdID, ownerID)
+	if err := row.Scan(&others); err != nil {
+		return decimal.Zero, err
+	}
+
+	if others > 0 {
+		return decimal.Zero, ErrOwnerHasMembers
+	}
+
+	var poolID int
+	var pool decimal.Decimal
+	row = tx.QueryRowContext(ctx, `SELECT P.id, P.balance
+								   FROM households AS H
+								   JOIN point_accounts AS P ON P.id = H.point_account_id
+								   WHERE H.id=$1
+								   FOR UPDATE OF P`, householdID)
+	if err := row.Scan(&poolID, &pool); err != nil {
+		return decimal.Zero, err
+	}
+
+	if !pool.IsPositive() {
+		return decimal.Zero, nil
+	}
+
+	var ownerAccountID int
+	row = tx.QueryRowContext(ctx, "UPDATE point_accounts SET balance=balance+$1 WHERE user_id=$2 RETURNING id", pool, ownerID)
+	if err := row.Scan(&ownerAccountID); err != nil {
+		return decimal.Zero, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE point_accounts SET balance=0 WHERE id=$1", poolID); err != nil {
+		return decimal.Zero, err
+	}
+
+	_, err := tx.ExecContext(ctx, `INSERT INTO point_transfers (sender_account_id, recipient_account_id, amount, status, created_at, resolved_at)
+								   VALUES ($1, $2, $3, $4, NOW(), NOW())`, poolID, ownerAccountID, pool, models.TransferStatusCompleted)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	return pool, nil
+}
+
+func (r *DBHouseholdRepository) RemoveMember(ctx context.Context, ownerID int, memberID int) error {
+	tx, err := r.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	householdID, err := ownedHousehold(ctx, tx, ownerID)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM household_members WHERE household_id=$1 AND user_id=$2 AND role=$3", householdID, memberID, models.HouseholdRoleMember)
+	if err != nil {
+		return err
+	}
+
+	if err := checkHouseholdMemberAffected(result); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *DBHouseholdRepository) SetAllowance(ctx context.Context, ownerID int, memberID int, allowance *decimal.Decimal) (*models.Household, error) {
+	tx, err := r.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	householdID, err := ownedHousehold(ctx, tx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.ExecContext(ctx, "UPDATE household_members SET allowance=$3 WHERE household_id=$1 AND user_id=$2 AND role=$4", householdID, memberID, allowance, models.HouseholdRoleMember)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkHouseholdMemberAffected(result); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return r.GetUserHousehold(ctx, ownerID)
+}
+
+func (r *DBHouseholdRepository) WithdrawFromHousehold(ctx context.Context, userID int, orderNum string, amount decimal.Decimal, guard WithdrawalGuard) error {
+	// a negative sum would pass the allowance and pool checks and credit both
+	if !amount.IsPositive() {
+		return ErrInvalidWithdrawSum
+	}
+
+	tx, err := r.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var poolID int
+	var allowance *decimal.Decimal
+	row := tx.QueryRowContext(ctx, `SELECT H.point_account_id, M.allowance
+									FROM household_members AS M
+									JOIN households AS H ON H.id = M.household_id
+									WHERE M.user_id=$1 AND M.status=$2
+									FOR UPDATE OF M`, userID, models.HouseholdMemberActive)
+	if err := row.Scan(&poolID, &allowance); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrHouseholdNotFound
+		}
+		return err
+	}
+
+	if allowance != nil && allowance.LessThan(amount) {
+		return ErrAllowanceExceeded
+	}
+
+	var pool decimal.Decimal
+	row = tx.QueryRowContext(ctx, "SELECT balance FROM point_accounts WHERE id=$1 FOR UPDATE", poolID)
+	if err := row.Scan(&pool); err != nil {
+		return err
+	}
+
+	// the lock on the own account serializes the check with the personal withdrawals of the member
+	if _, err := tx.ExecContext(ctx, "SELECT 1 FROM point_accounts WHERE user_id=$1 FOR UPDATE", userID); err != nil {
+		return err
+	}
+
+	if err := guardWithdrawal(ctx, tx, userID, guard); err != nil {
+		return err
+	}
+
+	if pool.LessThan(amount) {
+		return ErrNotEnoughPoints
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE point_accounts SET balance=balance-$1 WHERE id=$2", amount, poolID); err != nil {
+		return err
+	}
+
+	if allowance != nil {
+		if _, err := tx.ExecContext(ctx, "UPDATE household_members SET allowance=allowance-$1 WHERE user_id=$2", amount, userID); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO withdrawal_history (order_num, amount, point_account_id, user_id) VALUES ($1, $2, $3, $4)", orderNum, amount, poolID, userID)
+	if err != nil {
+		return err
+	}
+
+	// the withdrawal shows up in the history and the withdrawn total of the member
+	if err := bumpDataVersion(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	r.logger.Info("withdrew from household", zap.Int("user_id", userID), zap.Int("point_account_id", poolID), zap.String("amount", amount.String()))
+	r.publisher.PublishBalanceDelta(userID, models.BalanceDelta{
+		Current:   decimal.Zero,
+		Withdrawn: amount,
+		Reason:    models.BalanceChangeWithdrawal,
+	})
+
+	return nil
+}
+
+// ownedHousehold returns the household of the owner. The owner's membership is
+// locked against the owner leaving meanwhile.
+func ownedHousehold(ctx context.Context, tx *sql.Tx, ownerID int) (int, error) {
+	var householdID int
+	row := tx.QueryRowContext(ctx, "SELECT household_id FROM household_members WHERE user_id=$1 AND role=$2 FOR SHARE", ownerID, models.HouseholdRoleOwner)
+	if err := row.Scan(&householdID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNotHouseholdOwner
+		}
+		return 0, err
+	}
+
+	return householdID, nil
+}
+
+// accrualAccount returns the account accruals of the user go to: the pool of the
+// household while the user is an active member of one, the user's own account
+// otherwise. The membership stays locked, so the household cannot close meanwhile.
+func accrualAccount(ctx context.Context, tx *sql.Tx, userID int) (accountID int, pooled bool, err error) {
+	row := tx.QueryRowContext(ctx, `SELECT H.point_account_id
+									FROM household_members AS M
+									JOIN households AS H ON H.id = M.household_id
+									WHERE M.user_id=$1 AND M.status=$2
+									FOR SHARE OF M`, userID, models.HouseholdMemberActive)
+	err = row.Scan(&accountID)
+	if err == nil {
+		return accountID, true, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, false, err
+	}
+
+	row = tx.QueryRowContext(ctx, "SELECT id FROM point_accounts WHERE user_id=$1", userID)
+	err = row.Scan(&accountID)
+
+	return accountID, false, err
+}
+
+// handOverHousehold makes the member who joined first the owner of the household
+// of a deleted owner. With nobody to take over, the invitations lapse.
+func handOverHousehold(ctx context.Context, tx *sql.Tx, userID int) error {
+	var householdID int
+	row := tx.QueryRowContext(ctx, "SELECT household_id FROM household_members WHERE user_id=$1 AND role=$2", userID, models.HouseholdRoleOwner)
+	if err := row.Scan(&householdID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE household_members SET role=$3, allowance=NULL
+										WHERE household_id=$1 AND user_id = (SELECT user_id FROM household_members
+																			 WHERE household_id=$1 AND user_id<>$2 AND status=$4
+																			 ORDER BY joined_at, user_id
+																			 LIMIT 1)`,
+		householdID, userID, models.HouseholdRoleOwner, models.HouseholdMemberActive)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil || affected > 0 {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM household_members WHERE household_id=$1 AND user_id<>$2", householdID, userID)
+
+	return err
+}
+
+func householdMemberError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		return ErrAlreadyInHousehold
+	}
+
+	return err
+}
+
+func checkHouseholdMemberAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrHouseholdMemberNotFound
+	}
+
+	return nil
+}
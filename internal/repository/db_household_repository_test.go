@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rovany706/loyalty-gopher/internal/auth"
+	"github.com/rovany706/loyalty-gopher/internal/database"
+	"github.com/rovany706/loyalty-gopher/internal/events"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// householdTestEnv runs against the PostgreSQL database in TEST_DATABASE_URI,
+// the tests are skipped without one. Every test registers its own users.
+type householdTestEnv struct {
+	db         *database.Database
+	users      *DBUserRepository
+	orders     *DBOrderRepository
+	points     *DBPointsRepository
+	households *DBHouseholdRepository
+}
+
+func newHouseholdTestEnv(t *testing.T) *householdTestEnv {
+	databaseURI := os.Getenv("TEST_DATABASE_URI")
+	if databaseURI == "" {
+		t.Skip("TEST_DATABASE_URI is not set")
+	}
+
+	ctx := context.Background()
+	db, err := database.InitConnection(ctx, databaseURI)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, db.RunMigrations(ctx))
+
+	hasher, err := auth.NewBcryptPasswordHasher(bcrypt.MinCost)
+	require.NoError(t, err)
+
+	logger := zap.NewNop()
+	hub := events.NewHub()
+
+	return &householdTestEnv{
+		db:         db,
+		users:      NewDBUserRepository(db, hasher, logger),
+		orders:     NewDBOrderRepository(db, hub, logger),
+		points:     NewDBPointsRepository(db, hub, logger),
+		households: NewDBHouseholdRepository(db, hub, logger),
+	}
+}
+
+func (env *householdTestEnv) register(t *testing.T, name string) (int, string) {
+	login := fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+	userID, err := env.users.Register(context.Background(), login, "Dont-Panic-42")
+	require.NoError(t, err)
+
+	return userID, login
+}
+
+// householdOf creates a household of the owner with the given members and puts pool points in it.
+func (env *householdTestEnv) householdOf(t *testing.T, ownerID int, memberLogins []string, pool decimal.Decimal) *models.Household {
+	ctx := context.Background()
+	household, err := env.households.CreateHousehold(ctx, ownerID, "family")
+	require.NoError(t, err)
+
+	for _, login := range memberLogins {
+		_, err := env.households.InviteMember(ctx, ownerID, login)
+		require.NoError(t, err)
+	}
+
+	_, err = env.db.DBConnection.ExecContext(ctx, `UPDATE point_accounts SET balance=$1
+													FROM households AS H
+													WHERE H.id=$2 AND H.point_account_id = point_accounts.id`, pool, household.ID)
+	require.NoError(t, err)
+
+	return household
+}
+
+func uniqueOrderNum() string {
+	return fmt.Sprintf("%d", time.Now().UnixNano())
+}
+
+func allowWithdrawal(WithdrawalTotalsFunc) error {
+	return nil
+}
+
+func TestWithdrawFromHouseholdAllowance(t *testing.T) {
+	env := newHouseholdTestEnv(t)
+	ctx := context.Background()
+
+	ownerID, _ := env.register(t, "owner")
+	memberID, memberLogin := env.register(t, "member")
+	env.householdOf(t, ownerID, []string{memberLogin}, decimal.NewFromInt(500))
+	_, err := env.households.AcceptInvitation(ctx, memberID)
+	require.NoError(t, err)
+
+	allowance := decimal.NewFromInt(100)
+	_, err = env.households.SetAllowance(ctx, ownerID, memberID, &allowance)
+	require.NoError(t, err)
+
+	err = env.households.WithdrawFromHousehold(ctx, memberID, uniqueOrderNum(), decimal.NewFromInt(150), allowWithdrawal)
+	assert.ErrorIs(t, err, ErrAllowanceExceeded)
+
+	err = env.households.WithdrawFromHousehold(ctx, memberID, uniqueOrderNum(), decimal.NewFromInt(-100), allowWithdrawal)
+	assert.ErrorIs(t, err, ErrInvalidWithdrawSum)
+
+	require.NoError(t, env.households.WithdrawFromHousehold(ctx, memberID, uniqueOrderNum(), decimal.NewFromInt(60), allowWithdrawal))
+
+	err = env.households.WithdrawFromHousehold(ctx, memberID, uniqueOrderNum(), decimal.NewFromInt(50), allowWithdrawal)
+	assert.ErrorIs(t, err, ErrAllowanceExceeded)
+
+	household, err := env.households.GetUserHousehold(ctx, memberID)
+	require.NoError(t, err)
+	assert.Equal(t, "440.00", household.Balance.StringFixed(2))
+	for _, member := range household.Members {
+		if member.UserID == memberID {
+			require.NotNil(t, member.Allowance)
+			assert.Equal(t, "40.00", member.Allowance.StringFixed(2))
+		}
+	}
+
+	// the withdrawal is part of the history of the member, not of the pool owner
+	history, err := env.points.GetUserWithdrawalHistory(ctx, memberID)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, "60.00", history[0].WithdrawSum.StringFixed(2))
+
+	history, err = env.points.GetUserWithdrawalHistory(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func TestAccrualGoesToHouseholdPool(t *testing.T) {
+	env := newHouseholdTestEnv(t)
+	ctx := context.Background()
+
+	ownerID, _ := env.register(t, "owner")
+	memberID, memberLogin := env.register(t, "member")
+	outsiderID, _ := env.register(t, "outsider")
+	env.householdOf(t, ownerID, []string{memberLogin}, decimal.Zero)
+
+	// an invited member keeps accruing to the own account until accepting
+	invitedOrder := uniqueOrderNum()
+	require.NoError(t, env.orders.AddOrder(ctx, memberID, invitedOrder))
+	accrual := decimal.NewFromInt(30)
+	require.NoError(t, env.orders.UpdateOrderStatus(ctx, invitedOrder, models.AccrualStatusProcessed, &accrual))
+
+	_, err := env.households.AcceptInvitation(ctx, memberID)
+	require.NoError(t, err)
+
+	memberOrder := uniqueOrderNum()
+	require.NoError(t, env.orders.AddOrder(ctx, memberID, memberOrder))
+	accrual = decimal.NewFromInt(100)
+	require.NoError(t, env.orders.UpdateOrderStatus(ctx, memberOrder, models.AccrualStatusProcessed, &accrual))
+
+	outsiderOrder := uniqueOrderNum()
+	require.NoError(t, env.orders.AddOrder(ctx, outsiderID, outsiderOrder))
+	accrual = decimal.NewFromInt(70)
+	require.NoError(t, env.orders.UpdateOrderStatus(ctx, outsiderOrder, models.AccrualStatusProcessed, &accrual))
+
+	household, err := env.households.GetUserHousehold(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Equal(t, "100.00", household.Balance.StringFixed(2))
+
+	memberBalance, err := env.points.GetUserBalance(ctx, memberID)
+	require.NoError(t, err)
+	assert.Equal(t, "30.00", memberBalance.StringFixed(2))
+
+	outsiderBalance, err := env.points.GetUserBalance(ctx, outsiderID)
+	require.NoError(t, err)
+	assert.Equal(t, "70.00", outsiderBalance.StringFixed(2))
+}
+
+func TestCloseHouseholdReturnsPoolToOwner(t *testing.T) {
+	env := newHouseholdTestEnv(t)
+	ctx := context.Background()
+
+	ownerID, _ := env.register(t, "owner")
+	memberID, memberLogin := env.register(t, "member")
+	env.householdOf(t, ownerID, []string{memberLogin}, decimal.NewFromInt(200))
+	_, err := env.households.AcceptInvitation(ctx, memberID)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, env.households.LeaveHousehold(ctx, ownerID), ErrOwnerHasMembers)
+
+	require.NoError(t, env.households.LeaveHousehold(ctx, memberID))
+	require.NoError(t, env.households.LeaveHousehold(ctx, ownerID))
+
+	ownerBalance, err := env.points.GetUserBalance(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Equal(t, "200.00", ownerBalance.StringFixed(2))
+
+	memberBalance, err := env.points.GetUserBalance(ctx, memberID)
+	require.NoError(t, err)
+	assert.True(t, memberBalance.IsZero())
+
+	_, err = env.households.GetUserHousehold(ctx, ownerID)
+	assert.ErrorIs(t, err, ErrHouseholdNotFound)
+}
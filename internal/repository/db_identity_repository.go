@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rovany706/loyalty-gopher/internal/database"
+	"go.uber.org/zap"
+)
+
+type DBIdentityRepository struct {
+	db     *database.Database
+	logger *zap.Logger
+}
+
+func NewDBIdentityRepository(db *database.Database, logger *zap.Logger) *DBIdentityRepository {
+	return &DBIdentityRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *DBIdentityRepository) FindUserByIdentity(ctx context.Context, issuer string, subject string) (userID int, err error) {
+	row := r.db.DBConnection.QueryRowContext(ctx, "SELECT user_id FROM user_identities WHERE issuer=$1 AND subject=$2", issuer, subject)
+
+	if err := row.Scan(&userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return UnauthorizedUserID, ErrIdentityNotFound
+		}
+
+		return UnauthorizedUserID, err
+	}
+
+	return userID, nil
+}
+
+func (r *DBIdentityRepository) LinkIdentity(ctx context.Context, userID int, issuer string, subject string) error {
+	row := r.db.DBConnection.QueryRowContext(ctx, `INSERT INTO user_identities (user_id, issuer, subject) VALUES ($1, $2, $3)
+													ON CONFLICT (issuer, subject) DO UPDATE SET issuer=EXCLUDED.issuer
+													RETURNING user_id`, userID, issuer, subject)
+
+	var linkedUserID int
+	if err := row.Scan(&linkedUserID); err != nil {
+		return err
+	}
+
+	if linkedUserID != userID {
+		return ErrIdentityConflict
+	}
+
+	r.logger.Info("linked external identity", zap.Int("user_id", userID), zap.String("issuer", issuer))
+
+	return nil
+}
+
+func (r *DBIdentityRepository) RegisterExternalUser(ctx context.Context, login string, issuer string, subject string) (userID int, err error) {
+	tx, err := r.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return UnauthorizedUserID, err
+	}
+
+	defer tx.Rollback()
+
+	userID, err = createUser(ctx, tx, login, nil)
+	if err != nil {
+		return UnauthorizedUserID, err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO user_identities (user_id, issuer, subject) VALUES ($1, $2, $3)", userID, issuer, subject)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
+			return UnauthorizedUserID, ErrIdentityConflict
+		}
+
+		return UnauthorizedUserID, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return UnauthorizedUserID, err
+	}
+
+	r.logger.Info("registered external user", zap.Int("user_id", userID), zap.String("issuer", issuer))
+
+	return userID, nil
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rovany706/loyalty-gopher/internal/database"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"go.uber.org/zap"
+)
+
+const apiKeyColumns = "id, merchant_id, prefix, key_hash, scopes, rate_limit_per_minute, created_at, last_used_at, revoked_at"
+
+type DBMerchantRepository struct {
+	db     *database.Database
+	logger *zap.Logger
+}
+
+func NewDBMerchantRepository(db *database.Database, logger *zap.Logger) *DBMerchantRepository {
+	return &DBMerchantRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *DBMerchantRepository) CreateMerchant(ctx context.Context, name string) (*models.Merchant, error) {
+	merchant := models.Merchant{Name: name}
+	row := r.db.DBConnection.QueryRowContext(ctx, "INSERT INTO merchants (name) VALUES ($1) RETURNING id, created_at", name)
+
+	if err := row.Scan(&merchant.ID, &merchant.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	r.logger.Info("created merchant", zap.Int("merchant_id", merchant.ID))
+
+	return &merchant, nil
+}
+
+func (r *DBMerchantRepository) GetMerchant(ctx context.Context, merchantID int) (*models.Merchant, error) {
+	var merchant models.Merchant
+	row := r.db.DBConnection.QueryRowContext(ctx, "SELECT id, name, created_at FROM merchants WHERE id=$1", merchantID)
+
+	if err := row.Scan(&merchant.ID, &merchant.Name, &merchant.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMerchantNotFound
+		}
+		return nil, err
+	}
+
+	return &merchant, nil
+}
+
+func (r *DBMerchantRepository) CreateAPIKey(ctx context.Context, key models.APIKey) (*models.APIKey, error) {
+	row := r.db.DBConnection.QueryRowContext(ctx, `INSERT INTO api_keys (merchant_id, prefix, key_hash, scopes, rate_limit_per_minute)
+												   VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`,
+		key.MerchantID, key.Prefix, key.KeyHash, scopesToStrings(key.Scopes), key.RateLimitPerMinute)
+
+	if err := row.Scan(&key.ID, &key.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	r.logger.Info("created api key", zap.Int("merchant_id", key.MerchantID), zap.String("prefix", key.Prefix))
+
+	return &key, nil
+}
+
+func (r *DBMerchantRepository) GetMerchantAPIKeys(ctx context.Context, merchantID int) ([]models.APIKey, error) {
+	rows, err := r.db.DBConnection.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE merchant_id=$1 ORDER BY created_at", merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := make([]models.APIKey, 0)
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	rerr := rows.Close()
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (r *DBMerchantRepository) GetActiveAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	row := r.db.DBConnection.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE prefix=$1 AND revoked_at IS NULL", prefix)
+
+	key, err := scanAPIKey(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+func (r *DBMerchantRepository) RevokeAPIKey(ctx context.Context, merchantID int, keyID int) error {
+	result, err := r.db.DBConnection.ExecContext(ctx, "UPDATE api_keys SET revoked_at=NOW() WHERE id=$1 AND merchant_id=$2 AND revoked_at IS NULL", keyID, merchantID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	r.logger.Info("revoked api key", zap.Int("merchant_id", merchantID), zap.Int("key_id", keyID))
+
+	return nil
+}
+
+// TouchAPIKey updates last_used_at at most once a minute to avoid a write per request.
+func (r *DBMerchantRepository) TouchAPIKey(ctx context.Context, keyID int) error {
+	_, err := r.db.DBConnection.ExecContext(ctx, `UPDATE api_keys SET last_used_at=NOW()
+												  WHERE id=$1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`, keyID)
+
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row rowScanner) (models.APIKey, error) {
+	var key models.APIKey
+	var scopes []string
+	err := row.Scan(&key.ID, &key.MerchantID, &key.Prefix, &key.KeyHash, pgtype.NewMap().SQLScanner(&scopes), &key.RateLimitPerMinute, &key.CreatedAt, &key.LastUsedAt, &key.RevokedAt)
+	if err != nil {
+		return key, err
+	}
+
+	key.Scopes = make([]models.APIKeyScope, 0, len(scopes))
+	for _, scope := range scopes {
+		key.Scopes = append(key.Scopes, models.APIKeyScope(scope))
+	}
+
+	return key, nil
+}
+
+func scopesToStrings(scopes []models.APIKeyScope) []string {
+	result := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		result = append(result, string(scope))
+	}
+
+	return result
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/rovany706/loyalty-gopher/internal/database"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"go.uber.org/zap"
+)
+
+type DBMFARepository struct {
+	db     *database.Database
+	logger *zap.Logger
+}
+
+func NewDBMFARepository(db *database.Database, logger *zap.Logger) *DBMFARepository {
+	return &DBMFARepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *DBMFARepository) GetTOTP(ctx context.Context, userID int) (*models.TOTPSettings, error) {
+	row := r.db.DBConnection.QueryRowContext(ctx, "SELECT user_id, secret, confirmed_at, last_used_step FROM user_totp WHERE user_id=$1", userID)
+
+	var settings models.TOTPSettings
+	err := row.Scan(&settings.UserID, &settings.Secret, &settings.ConfirmedAt, &settings.LastUsedStep)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+func (r *DBMFARepository) SaveTOTPSecret(ctx context.Context, userID int, secret string) error {
+	result, err := r.db.DBConnection.ExecContext(ctx, `INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+													   ON CONFLICT (user_id) DO UPDATE SET secret=EXCLUDED.secret, last_used_step=0
+													   WHERE user_totp.confirmed_at IS NULL`, userID, secret)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+
+	return nil
+}
+
+func (r *DBMFARepository) ConfirmTOTP(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error {
+	tx, err := r.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "UPDATE user_totp SET confirmed_at=NOW(), last_used_step=$1 WHERE user_id=$2 AND confirmed_at IS NULL", step, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM user_recovery_codes WHERE user_id=$1", userID)
+	if err != nil {
+		return err
+	}
+
+	for _, codeHash := range recoveryCodeHashes {
+		_, err = tx.ExecContext(ctx, "INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, codeHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	r.logger.Info("enabled totp", zap.Int("user_id", userID))
+
+	return nil
+}
+
+func (r *DBMFARepository) DisableTOTP(ctx context.Context, userID int) error {
+	tx, err := r.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM user_recovery_codes WHERE user_id=$1", userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM user_totp WHERE user_id=$1", userID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	r.logger.Info("disabled totp", zap.Int("user_id", userID))
+
+	return nil
+}
+
+// UseTOTPStep records step as used and reports false if it
+// or a later step was already used, which rejects replayed codes.
+func (r *DBMFARepository) UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	result, err := r.db.DBConnection.ExecContext(ctx, "UPDATE user_totp SET last_used_step=$1 WHERE user_id=$2 AND last_used_step < $1", step, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (r *DBMFARepository) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	result, err := r.db.DBConnection.ExecContext(ctx, "UPDATE user_recovery_codes SET used_at=NOW() WHERE user_id=$1 AND code_hash=$2 AND used_at IS NULL", userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if affected > 0 {
+		r.logger.Info("used recovery code", zap.Int("user_id", userID))
+	}
+
+	return affected > 0, nil
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/rovany706/loyalty-gopher/internal/database"
+	"github.com/rovany706/loyalty-gopher/internal/events"
 	"github.com/rovany706/loyalty-gopher/internal/helpers"
 	"github.com/rovany706/loyalty-gopher/internal/models"
 	"github.com/shopspring/decimal"
@@ -15,14 +17,16 @@ import (
 )
 
 type DBOrderRepository struct {
-	db     *database.Database
-	logger *zap.Logger
+	db        *database.Database
+	publisher events.Publisher
+	logger    *zap.Logger
 }
 
-func NewDBOrderRepository(db *database.Database, logger *zap.Logger) *DBOrderRepository {
+func NewDBOrderRepository(db *database.Database, publisher events.Publisher, logger *zap.Logger) *DBOrderRepository {
 	return &DBOrderRepository{
-		db:     db,
-		logger: logger,
+		db:        db,
+		publisher: publisher,
+		logger:    logger,
 	}
 }
 
@@ -41,7 +45,12 @@ func (r *DBOrderRepository) GetOrder(ctx context.Context, orderNum string) (*mod
 }
 
 func (r *DBOrderRepository) AddOrder(ctx context.Context, userID int, orderNum string) error {
-	_, err := r.db.DBConnection.ExecContext(ctx, "INSERT INTO orders (order_num, user_id, accrual_status, accrual) VALUES ($1, $2, $3, 0)", orderNum, userID, models.AccrualStatusRegistered)
+	_, err := r.db.DBConnection.ExecContext(ctx, `WITH inserted AS (
+													INSERT INTO orders (order_num, user_id, accrual_status, accrual) VALUES ($1, $2, $3, 0)
+													RETURNING user_id
+												)
+												UPDATE users SET data_version=data_version+1, data_modified_at=NOW()
+												WHERE id IN (SELECT user_id FROM inserted)`, orderNum, userID, models.AccrualStatusRegistered)
 
 	r.logger.Info("added order", zap.String("num", orderNum), zap.Int("user_id", userID))
 	if err != nil {
@@ -54,6 +63,66 @@ func (r *DBOrderRepository) AddOrder(ctx context.Context, userID int, orderNum s
 	return err
 }
 
+func (r *DBOrderRepository) AddOrders(ctx context.Context, userID int, orderNums []string) ([]models.OrderBatchResult, error) {
+	tx, err := r.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	results := make([]models.OrderBatchResult, 0, len(orderNums))
+	accepted := 0
+	for _, orderNum := range orderNums {
+		// an order already in the table, including one earlier in the batch, comes back with its owner
+		row := tx.QueryRowContext(ctx, `WITH inserted AS (
+											INSERT INTO orders (order_num, user_id, accrual_status, accrual) VALUES ($1, $2, $3, 0)
+											ON CONFLICT (order_num) DO NOTHING
+											RETURNING user_id
+										)
+										SELECT user_id, TRUE FROM inserted
+										UNION ALL
+										SELECT user_id, FALSE FROM orders WHERE order_num=$1 AND NOT EXISTS (SELECT 1 FROM inserted)`, orderNum, userID, models.AccrualStatusRegistered)
+
+		var ownerID int
+		var inserted bool
+		if err := row.Scan(&ownerID, &inserted); err != nil {
+			// a concurrent upload committed after the statement snapshot was taken
+			if !errors.Is(err, sql.ErrNoRows) {
+				return nil, err
+			}
+
+			ownerID = UnauthorizedUserID
+		}
+
+		result := models.OrderBatchResult{Number: orderNum}
+		switch {
+		case inserted:
+			result.Status = models.OrderBatchStatusAccepted
+			accepted++
+		case ownerID == userID:
+			result.Status = models.OrderBatchStatusDuplicateOwn
+		default:
+			result.Status = models.OrderBatchStatusConflict
+		}
+
+		results = append(results, result)
+	}
+
+	if accepted > 0 {
+		if err := bumpDataVersion(ctx, tx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	r.logger.Info("added order batch", zap.Int("user_id", userID), zap.Int("size", len(orderNums)), zap.Int("accepted", accepted))
+
+	return results, nil
+}
+
 func (r *DBOrderRepository) GetUserOrders(ctx context.Context, userID int) ([]models.Order, error) {
 	rows, err := r.db.DBConnection.QueryContext(ctx, "SELECT order_num, user_id, uploaded_at, accrual_status, accrual FROM orders WHERE user_id=$1", userID)
 	if err != nil {
@@ -91,6 +160,114 @@ func (r *DBOrderRepository) GetUserOrders(ctx context.Context, userID int) ([]mo
 	return orders, nil
 }
 
+func (r *DBOrderRepository) GetUserPendingOrders(ctx context.Context, userID int) ([]models.Order, error) {
+	rows, err := r.db.DBConnection.QueryContext(ctx, `SELECT order_num, user_id, accrual_status
+													  FROM orders
+													  WHERE user_id=$1 AND accrual_status NOT IN ($2, $3)`, userID, models.AccrualStatusProcessed, models.AccrualStatusInvalid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	orders := make([]models.Order, 0)
+
+	for rows.Next() {
+		var order models.Order
+		if err := rows.Scan(&order.OrderNum, &order.UserID, &order.AccrualStatus); err != nil {
+			return nil, err
+		}
+
+		orders = append(orders, order)
+	}
+
+	rerr := rows.Close()
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+func (r *DBOrderRepository) StreamUserOrders(ctx context.Context, userID int, fn func(models.Order) error) error {
+	rows, err := r.db.DBConnection.QueryContext(ctx, `SELECT order_num, user_id, uploaded_at, accrual_status, accrual
+													  FROM orders
+													  WHERE user_id=$1
+													  ORDER BY uploaded_at DESC, id DESC`, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var order models.Order
+		if err := rows.Scan(&order.OrderNum, &order.UserID, &order.UploadedAt, &order.AccrualStatus, &order.Accrual); err != nil {
+			return err
+		}
+
+		if order.Accrual.IsZero() {
+			order.Accrual = nil
+		}
+
+		if order.AccrualStatus == models.AccrualStatusRegistered {
+			order.AccrualStatus = models.AccrualStatusNew
+		}
+
+		if err := fn(order); err != nil {
+			return err
+		}
+	}
+
+	rerr := rows.Close()
+	if rerr != nil {
+		return rerr
+	}
+
+	return rows.Err()
+}
+
+func (r *DBOrderRepository) GetOrdersByUserIDs(ctx context.Context, userIDs []int) (map[int][]models.Order, error) {
+	rows, err := r.db.DBConnection.QueryContext(ctx, `SELECT order_num, user_id, uploaded_at, accrual_status, accrual
+													  FROM orders
+													  WHERE user_id = ANY($1)
+													  ORDER BY uploaded_at DESC, id DESC`, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	orders := make(map[int][]models.Order, len(userIDs))
+
+	for rows.Next() {
+		var order models.Order
+		if err := rows.Scan(&order.OrderNum, &order.UserID, &order.UploadedAt, &order.AccrualStatus, &order.Accrual); err != nil {
+			return nil, err
+		}
+
+		if order.Accrual.IsZero() {
+			order.Accrual = nil
+		}
+
+		if order.AccrualStatus == models.AccrualStatusRegistered {
+			order.AccrualStatus = models.AccrualStatusNew
+		}
+
+		orders[order.UserID] = append(orders[order.UserID], order)
+	}
+
+	rerr := rows.Close()
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
 func (r *DBOrderRepository) UpdateOrderStatus(ctx context.Context, orderNum string, newAccrualStatus models.AccrualStatus, accrualAmount *decimal.Decimal) error {
 	tx, err := r.db.DBConnection.BeginTx(ctx, nil)
 	if err != nil {
@@ -115,25 +292,59 @@ func (r *DBOrderRepository) UpdateOrderStatus(ctx context.Context, orderNum stri
 	}
 
 	// update status
-	_, err = tx.ExecContext(ctx, "UPDATE orders SET accrual_status=$1, accrual=$2 WHERE order_num=$3", newAccrualStatus, *accrualAmount, orderNum)
+	// statements date accruals by the time they were credited and find them by the
+	// account credited, which is the household pool for its active members
+	var processedAt *time.Time
+	var accountID *int
+	var pooled bool
+	if helpers.IsOrderAccrualCalculated(newAccrualStatus) {
+		now := time.Now()
+		processedAt = &now
+
+		var id int
+		id, pooled, err = accrualAccount(ctx, tx, order.UserID)
+		if err != nil {
+			return err
+		}
+		accountID = &id
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE orders SET accrual_status=$1, accrual=$2, processed_at=$3, point_account_id=$4 WHERE order_num=$5", newAccrualStatus, *accrualAmount, processedAt, accountID, orderNum)
 	if err != nil {
 		return err
 	}
 
 	// if calculated, then add points
-	if helpers.IsOrderAccrualCalculated(newAccrualStatus) {
-		_, err = tx.ExecContext(ctx, "UPDATE point_accounts SET balance=balance+$1 WHERE user_id=$2", accrualAmount, order.UserID)
+	if accountID != nil {
+		_, err = tx.ExecContext(ctx, "UPDATE point_accounts SET balance=balance+$1 WHERE id=$2", accrualAmount, *accountID)
 		if err != nil {
 			return err
 		}
 	}
 
+	if err := bumpDataVersion(ctx, tx, order.UserID); err != nil {
+		return err
+	}
+
 	err = tx.Commit()
 
 	if err != nil {
 		return err
 	}
 
+	order.AccrualStatus = newAccrualStatus
+	order.Accrual = accrualAmount
+	r.publisher.PublishOrderEvent(order.UserID, order)
+
+	// the balance of the user is unchanged by accruals into a household pool
+	if helpers.IsOrderAccrualCalculated(newAccrualStatus) && !pooled && !accrualAmount.IsZero() {
+		r.publisher.PublishBalanceDelta(order.UserID, models.BalanceDelta{
+			Current:   *accrualAmount,
+			Withdrawn: decimal.Zero,
+			Reason:    models.BalanceChangeAccrual,
+		})
+	}
+
 	return nil
 }
 
@@ -146,3 +357,42 @@ func scanOrderRow(row *sql.Row) (models.Order, error) {
 
 	return order, nil
 }
+
+func (r *DBOrderRepository) GetUserOrdersPage(ctx context.Context, userID int, limit int, offset int) ([]models.Order, int, error) {
+	var total int
+	row := r.db.DBConnection.QueryRowContext(ctx, "SELECT COUNT(*) FROM orders WHERE user_id=$1", userID)
+	if err := row.Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.DBConnection.QueryContext(ctx, `SELECT order_num, user_id, uploaded_at, accrual_status, accrual
+													  FROM orders
+													  WHERE user_id=$1
+													  ORDER BY uploaded_at DESC, id DESC
+													  LIMIT $2 OFFSET $3`, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	orders := make([]models.Order, 0)
+
+	for rows.Next() {
+		var order models.Order
+		if err := rows.Scan(&order.OrderNum, &order.UserID, &order.UploadedAt, &order.AccrualStatus, &order.Accrual); err != nil {
+			return nil, 0, err
+		}
+
+		orders = append(orders, order)
+	}
+
+	rerr := rows.Close()
+	if rerr != nil {
+		return nil, 0, rerr
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return orders, total, nil
+}
@@ -2,22 +2,27 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 
 	"github.com/rovany706/loyalty-gopher/internal/database"
+	"github.com/rovany706/loyalty-gopher/internal/events"
 	"github.com/rovany706/loyalty-gopher/internal/models"
 	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
 
 type DBPointsRepository struct {
-	db     *database.Database
-	logger *zap.Logger
+	db        *database.Database
+	publisher events.Publisher
+	logger    *zap.Logger
 }
 
-func NewDBPointsRepository(db *database.Database, logger *zap.Logger) *DBPointsRepository {
+func NewDBPointsRepository(db *database.Database, publisher events.Publisher, logger *zap.Logger) *DBPointsRepository {
 	return &DBPointsRepository{
-		db:     db,
-		logger: logger,
+		db:        db,
+		publisher: publisher,
+		logger:    logger,
 	}
 }
 
@@ -37,9 +42,7 @@ func (pr *DBPointsRepository) GetUserBalance(ctx context.Context, userID int) (d
 func (pr *DBPointsRepository) GetUserWithdrawalHistory(ctx context.Context, userID int) ([]models.WithdrawHistoryEntry, error) {
 	rows, err := pr.db.DBConnection.QueryContext(ctx, `SELECT W.order_num, W.amount, W.processed_at
 													   FROM withdrawal_history AS W
-													   JOIN point_accounts AS P
-													   ON P.id = W.point_account_id
-													   WHERE P.user_id=$1
+													   WHERE W.user_id=$1
 													   ORDER BY W.processed_at DESC`, userID)
 
 	if err != nil {
@@ -69,7 +72,104 @@ func (pr *DBPointsRepository) GetUserWithdrawalHistory(ctx context.Context, user
 	return entries, nil
 }
 
-func (pr *DBPointsRepository) WithdrawPoints(ctx context.Context, userID int, orderNum string, amount decimal.Decimal) error {
+func (pr *DBPointsRepository) StreamUserWithdrawals(ctx context.Context, userID int, fn func(models.WithdrawHistoryEntry) error) error {
+	rows, err := pr.db.DBConnection.QueryContext(ctx, `SELECT W.order_num, W.amount, W.processed_at
+													   FROM withdrawal_history AS W
+													   WHERE W.user_id=$1
+													   ORDER BY W.processed_at DESC`, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.WithdrawHistoryEntry
+		if err := rows.Scan(&entry.OrderNum, &entry.WithdrawSum, &entry.ProcessedAt); err != nil {
+			return err
+		}
+
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	rerr := rows.Close()
+	if rerr != nil {
+		return rerr
+	}
+
+	return rows.Err()
+}
+
+func (pr *DBPointsRepository) GetBalancesByUserIDs(ctx context.Context, userIDs []int) (map[int]models.GetUserBalanceResponse, error) {
+	rows, err := pr.db.DBConnection.QueryContext(ctx, `SELECT P.user_id, P.balance, COALESCE(SUM(W.amount), 0)
+													   FROM point_accounts AS P
+													   LEFT JOIN withdrawal_history AS W
+													   ON W.user_id = P.user_id
+													   WHERE P.user_id = ANY($1)
+													   GROUP BY P.user_id, P.balance`, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	balances := make(map[int]models.GetUserBalanceResponse, len(userIDs))
+
+	for rows.Next() {
+		var userID int
+		var balance models.GetUserBalanceResponse
+		if err := rows.Scan(&userID, &balance.Current, &balance.Withdrawn); err != nil {
+			return nil, err
+		}
+
+		balances[userID] = balance
+	}
+
+	rerr := rows.Close()
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return balances, nil
+}
+
+func (pr *DBPointsRepository) GetWithdrawalsByUserIDs(ctx context.Context, userIDs []int) (map[int][]models.WithdrawHistoryEntry, error) {
+	rows, err := pr.db.DBConnection.QueryContext(ctx, `SELECT W.user_id, W.order_num, W.amount, W.processed_at
+													   FROM withdrawal_history AS W
+													   WHERE W.user_id = ANY($1)
+													   ORDER BY W.processed_at DESC`, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	withdrawals := make(map[int][]models.WithdrawHistoryEntry, len(userIDs))
+
+	for rows.Next() {
+		var userID int
+		var entry models.WithdrawHistoryEntry
+		if err := rows.Scan(&userID, &entry.OrderNum, &entry.WithdrawSum, &entry.ProcessedAt); err != nil {
+			return nil, err
+		}
+
+		withdrawals[userID] = append(withdrawals[userID], entry)
+	}
+
+	rerr := rows.Close()
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return withdrawals, nil
+}
+
+func (pr *DBPointsRepository) WithdrawPoints(ctx context.Context, userID int, orderNum string, amount decimal.Decimal, guard WithdrawalGuard) error {
 	tx, err := pr.db.DBConnection.BeginTx(ctx, nil)
 	if err != nil {
 		return err
@@ -80,7 +180,8 @@ func (pr *DBPointsRepository) WithdrawPoints(ctx context.Context, userID int, or
 		id      int
 		balance decimal.Decimal
 	}
-	row := tx.QueryRowContext(ctx, "SELECT id, balance FROM point_accounts WHERE user_id=$1", userID)
+	// the lock keeps transfers and other withdrawals from changing the balance until commit
+	row := tx.QueryRowContext(ctx, "SELECT id, balance FROM point_accounts WHERE user_id=$1 FOR UPDATE", userID)
 
 	err = row.Scan(&userPointsAccount.id, &userPointsAccount.balance)
 
@@ -88,30 +189,175 @@ func (pr *DBPointsRepository) WithdrawPoints(ctx context.Context, userID int, or
 		return err
 	}
 
+	if err := guardWithdrawal(ctx, tx, userID, guard); err != nil {
+		return err
+	}
+
 	if userPointsAccount.balance.LessThan(amount) {
 		pr.logger.Info("not enough points", zap.String("balance", userPointsAccount.balance.String()), zap.String("required", amount.String()))
 		return ErrNotEnoughPoints
 	}
 
-	newBalance := userPointsAccount.balance.Sub(amount)
-
-	_, err = tx.ExecContext(ctx, "UPDATE point_accounts SET balance=$1 WHERE user_id=$2", newBalance, userID)
+	_, err = tx.ExecContext(ctx, "UPDATE point_accounts SET balance=balance-$1 WHERE id=$2", amount, userPointsAccount.id)
 
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.ExecContext(ctx, "INSERT INTO withdrawal_history (order_num, amount, point_account_id) VALUES ($1, $2, $3)", orderNum, amount, userPointsAccount.id)
+	_, err = tx.ExecContext(ctx, "INSERT INTO withdrawal_history (order_num, amount, point_account_id, user_id) VALUES ($1, $2, $3, $4)", orderNum, amount, userPointsAccount.id, userID)
 
 	if err != nil {
 		return err
 	}
 
+	if err := bumpDataVersion(ctx, tx, userID); err != nil {
+		return err
+	}
+
 	err = tx.Commit()
 
 	if err != nil {
 		return err
 	}
 
+	pr.publisher.PublishBalanceDelta(userID, models.BalanceDelta{
+		Current:   amount.Neg(),
+		Withdrawn: amount,
+		Reason:    models.BalanceChangeWithdrawal,
+	})
+
 	return nil
 }
+
+// AdjustBalance credits (positive amount) or debits (negative amount) the user's
+// account on behalf of an admin and records the reason.
+func (pr *DBPointsRepository) AdjustBalance(ctx context.Context, userID int, adminUserID int, amount decimal.Decimal, reason string) error {
+	tx, err := pr.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userPointsAccount struct {
+		id      int
+		balance decimal.Decimal
+	}
+	row := tx.QueryRowContext(ctx, "SELECT id, balance FROM point_accounts WHERE user_id=$1 FOR UPDATE", userID)
+
+	err = row.Scan(&userPointsAccount.id, &userPointsAccount.balance)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	newBalance := userPointsAccount.balance.Add(amount)
+	if newBalance.IsNegative() {
+		return ErrNotEnoughPoints
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE point_accounts SET balance=$1 WHERE id=$2", newBalance, userPointsAccount.id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO balance_adjustments (point_account_id, amount, reason, admin_user_id) VALUES ($1, $2, $3, $4)", userPointsAccount.id, amount, reason, adminUserID)
+	if err != nil {
+		return err
+	}
+
+	if err := bumpDataVersion(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	pr.logger.Info("adjusted balance", zap.Int("user_id", userID), zap.Int("admin_user_id", adminUserID), zap.String("amount", amount.String()))
+	pr.publisher.PublishBalanceDelta(userID, models.BalanceDelta{
+		Current:   amount,
+		Withdrawn: decimal.Zero,
+		Reason:    models.BalanceChangeAdjustment,
+	})
+
+	return nil
+}
+
+func (pr *DBPointsRepository) GetUserBalanceAdjustments(ctx context.Context, userID int) ([]models.BalanceAdjustment, error) {
+	rows, err := pr.db.DBConnection.QueryContext(ctx, `SELECT A.amount, A.reason, A.created_at
+													   FROM balance_adjustments AS A
+													   JOIN point_accounts AS P
+													   ON P.id = A.point_account_id
+													   WHERE P.user_id=$1
+													   ORDER BY A.created_at DESC`, userID)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	adjustments := make([]models.BalanceAdjustment, 0)
+
+	for rows.Next() {
+		var adjustment models.BalanceAdjustment
+		if err := rows.Scan(&adjustment.Amount, &adjustment.Reason, &adjustment.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		adjustments = append(adjustments, adjustment)
+	}
+
+	rerr := rows.Close()
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return adjustments, nil
+}
+
+func (pr *DBPointsRepository) GetUserWithdrawalsPage(ctx context.Context, userID int, limit int, offset int) ([]models.WithdrawHistoryEntry, int, error) {
+	var total int
+	row := pr.db.DBConnection.QueryRowContext(ctx, `SELECT COUNT(*)
+													 FROM withdrawal_history AS W
+													 WHERE W.user_id=$1`, userID)
+	if err := row.Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := pr.db.DBConnection.QueryContext(ctx, `SELECT W.order_num, W.amount, W.processed_at
+													   FROM withdrawal_history AS W
+													   WHERE W.user_id=$1
+													   ORDER BY W.processed_at DESC, W.id DESC
+													   LIMIT $2 OFFSET $3`, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	entries := make([]models.WithdrawHistoryEntry, 0)
+
+	for rows.Next() {
+		var entry models.WithdrawHistoryEntry
+		if err := rows.Scan(&entry.OrderNum, &entry.WithdrawSum, &entry.ProcessedAt); err != nil {
+			return nil, 0, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	rerr := rows.Close()
+	if rerr != nil {
+		return nil, 0, rerr
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
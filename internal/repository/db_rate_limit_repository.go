@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rovany706/loyalty-gopher/internal/database"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"go.uber.org/zap"
+)
+
+const rateLimitPruneInterval = time.Minute
+
+type DBRateLimitRepository struct {
+	db        *database.Database
+	logger    *zap.Logger
+	lastPrune time.Time
+	mutex     sync.Mutex
+}
+
+func NewDBRateLimitRepository(db *database.Database, logger *zap.Logger) *DBRateLimitRepository {
+	return &DBRateLimitRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *DBRateLimitRepository) TakeToken(ctx context.Context, key string, limit models.RateLimit, now time.Time) (models.RateLimitDecision, error) {
+	tx, err := r.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return models.RateLimitDecision{}, err
+	}
+	defer tx.Rollback()
+
+	// the row is created full first, so concurrent requests of a new client queue on its lock
+	_, err = tx.ExecContext(ctx, `INSERT INTO rate_limit_buckets (key, tokens, updated_at, full_at) VALUES ($1, $2, $3, $3)
+								  ON CONFLICT (key) DO NOTHING`, key, float64(limit.Requests), now)
+	if err != nil {
+		return models.RateLimitDecision{}, err
+	}
+
+	var bucket models.TokenBucket
+	row := tx.QueryRowContext(ctx, "SELECT tokens, updated_at FROM rate_limit_buckets WHERE key=$1 FOR UPDATE", key)
+	if err := row.Scan(&bucket.Tokens, &bucket.UpdatedAt); err != nil {
+		return models.RateLimitDecision{}, err
+	}
+
+	bucket, decision := bucket.Take(limit, now)
+	_, err = tx.ExecContext(ctx, "UPDATE rate_limit_buckets SET tokens=$1, updated_at=$2, full_at=$3 WHERE key=$4", bucket.Tokens, bucket.UpdatedAt, now.Add(decision.Reset), key)
+	if err != nil {
+		return models.RateLimitDecision{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.RateLimitDecision{}, err
+	}
+
+	r.pruneFullBuckets(ctx, now)
+
+	return decision, nil
+}
+
+// pruneFullBuckets deletes buckets that have refilled, as a missing bucket is a full one.
+func (r *DBRateLimitRepository) pruneFullBuckets(ctx context.Context, now time.Time) {
+	r.mutex.Lock()
+	if now.Sub(r.lastPrune) < rateLimitPruneInterval {
+		r.mutex.Unlock()
+		return
+	}
+	r.lastPrune = now
+	r.mutex.Unlock()
+
+	result, err := r.db.DBConnection.ExecContext(ctx, "DELETE FROM rate_limit_buckets WHERE full_at <= $1", now)
+	if err != nil {
+		r.logger.Warn("failed to prune rate limit buckets", zap.Error(err))
+		return
+	}
+
+	if pruned, err := result.RowsAffected(); err == nil && pruned > 0 {
+		r.logger.Debug("pruned rate limit buckets", zap.Int64("pruned", pruned))
+	}
+}
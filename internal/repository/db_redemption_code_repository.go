@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/rovany706/loyalty-gopher/internal/database"
+	"go.uber.org/zap"
+)
+
+type DBRedemptionCodeRepository struct {
+	db     *database.Database
+	logger *zap.Logger
+}
+
+func NewDBRedemptionCodeRepository(db *database.Database, logger *zap.Logger) *DBRedemptionCodeRepository {
+	return &DBRedemptionCodeRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *DBRedemptionCodeRepository) CreateRedemptionCode(ctx context.Context, userID int, codeHash string, expiresAt time.Time) error {
+	_, err := r.db.DBConnection.ExecContext(ctx, "INSERT INTO redemption_codes (user_id, code_hash, expires_at) VALUES ($1, $2, $3)", userID, codeHash, expiresAt)
+	return err
+}
+
+func (r *DBRedemptionCodeRepository) UseRedemptionCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	result, err := r.db.DBConnection.ExecContext(ctx, `UPDATE redemption_codes SET used_at=NOW()
+													   WHERE user_id=$1 AND code_hash=$2 AND used_at IS NULL AND expires_at > NOW()`, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if affected > 0 {
+		r.logger.Info("used redemption code", zap.Int("user_id", userID))
+	}
+
+	return affected > 0, nil
+}
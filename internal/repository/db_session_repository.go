@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/rovany706/loyalty-gopher/internal/database"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"go.uber.org/zap"
+)
+
+type DBSessionRepository struct {
+	db     *database.Database
+	logger *zap.Logger
+}
+
+func NewDBSessionRepository(db *database.Database, logger *zap.Logger) *DBSessionRepository {
+	return &DBSessionRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *DBSessionRepository) CreateSession(ctx context.Context, userID int, userAgent string, ip string) (sessionID int, err error) {
+	row := r.db.DBConnection.QueryRowContext(ctx, "INSERT INTO sessions (user_id, user_agent, ip) VALUES ($1, $2, $3) RETURNING id", userID, userAgent, ip)
+
+	if err := row.Scan(&sessionID); err != nil {
+		return 0, err
+	}
+
+	r.logger.Info("created session", zap.Int("user_id", userID), zap.Int("session_id", sessionID))
+
+	return sessionID, nil
+}
+
+func (r *DBSessionRepository) GetUserSessions(ctx context.Context, userID int) ([]models.Session, error) {
+	rows, err := r.db.DBConnection.QueryContext(ctx, `SELECT id, user_id, user_agent, ip, created_at, last_seen_at
+													  FROM sessions
+													  WHERE user_id=$1 AND revoked_at IS NULL
+													  ORDER BY last_seen_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := make([]models.Session, 0)
+
+	for rows.Next() {
+		var session models.Session
+		if err := rows.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeenAt); err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	rerr := rows.Close()
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (r *DBSessionRepository) RevokeSession(ctx context.Context, userID int, sessionID int) error {
+	result, err := r.db.DBConnection.ExecContext(ctx, "UPDATE sessions SET revoked_at=NOW() WHERE id=$1 AND user_id=$2 AND revoked_at IS NULL", sessionID, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrSessionNotFound
+	}
+
+	r.logger.Info("revoked session", zap.Int("user_id", userID), zap.Int("session_id", sessionID))
+
+	return nil
+}
+
+// revokeUserSessions ends every session of the user in the transaction changing
+// the user, so tokens issued before the change stop working with it.
+func revokeUserSessions(ctx context.Context, tx *sql.Tx, userID int) error {
+	_, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked_at=NOW() WHERE user_id=$1 AND revoked_at IS NULL", userID)
+	return err
+}
+
+func (r *DBSessionRepository) TouchSession(ctx context.Context, userID int, sessionID int) (bool, error) {
+	// last_seen_at is only written once a minute, so most requests are a single read
+	row := r.db.DBConnection.QueryRowContext(ctx, `WITH active AS (
+														SELECT S.id, S.last_seen_at
+														FROM sessions AS S
+														JOIN users AS U
+														ON U.id = S.user_id
+														WHERE S.id=$1 AND S.user_id=$2 AND S.revoked_at IS NULL AND U.disabled_at IS NULL
+												   ), touched AS (
+														UPDATE sessions SET last_seen_at=NOW()
+														WHERE id IN (SELECT id FROM active WHERE last_seen_at < NOW() - INTERVAL '1 minute')
+												   )
+												   SELECT COUNT(*) FROM active`, sessionID, userID)
+
+	var count int
+	if err := row.Scan(&count); err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/rovany706/loyalty-gopher/internal/database"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"go.uber.org/zap"
+)
+
+type DBStatementRepository struct {
+	db     *database.Database
+	logger *zap.Logger
+}
+
+func NewDBStatementRepository(db *database.Database, logger *zap.Logger) *DBStatementRepository {
+	return &DBStatementRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *DBStatementRepository) GetLedger(ctx context.Context, userID int, from time.Time, to time.Time) (models.Ledger, error) {
+	var ledger models.Ledger
+
+	// the balance only changes through accruals, withdrawals, adjustments and transfers:
+	// a transfer leaves the sender when it is made and reaches the recipient, or returns
+	// to the sender, when it is resolved. Withdrawals the user paid from a household
+	// pool are listed as well, they leave the balance as it is.
+	row := r.db.DBConnection.QueryRowContext(ctx, `SELECT
+		COALESCE((SELECT SUM(O.accrual) FROM orders AS O
+				  JOIN point_accounts AS P ON P.id = O.point_account_id
+				  WHERE P.user_id=$1 AND O.accrual_status='PROCESSED' AND O.processed_at < $2), 0)
+		- COALESCE((SELECT SUM(W.amount) FROM withdrawal_history AS W
+					JOIN point_accounts AS P ON P.id = W.point_account_id
+					WHERE P.user_id=$1 AND W.processed_at < $2), 0)
+		+ COALESCE((SELECT SUM(A.amount) FROM balance_adjustments AS A
+					JOIN point_accounts AS P ON P.id = A.point_account_id
+					WHERE P.user_id=$1 AND A.created_at < $2), 0)
+		- COALESCE((SELECT SUM(T.amount) FROM point_transfers AS T
+					JOIN point_accounts AS P ON P.id = T.sender_account_id
+					WHERE P.user_id=$1 AND T.created_at < $2), 0)
+		+ COALESCE((SELECT SUM(T.amount) FROM point_transfers AS T
+					JOIN point_accounts AS P ON P.id = T.sender_account_id
+					WHERE P.user_id=$1 AND T.status IN ('declined', 'cancelled') AND T.resolved_at < $2), 0)
+		+ COALESCE((SELECT SUM(T.amount) FROM point_transfers AS T
+					JOIN point_accounts AS P ON P.id = T.recipient_account_id
+					WHERE P.user_id=$1 AND T.status='completed' AND T.resolved_at < $2), 0),
+		COALESCE((SELECT SUM(accrual) FROM orders
+				  WHERE user_id=$1 AND accrual_status='PROCESSED' AND processed_at < $3), 0)`, userID, from, to)
+	if err := row.Scan(&ledger.OpeningBalance, &ledger.LifetimeAccrued); err != nil {
+		return models.Ledger{}, err
+	}
+
+	rows, err := r.db.DBConnection.QueryContext(ctx, `SELECT O.processed_at, 'accrual', O.order_num, O.accrual
+													  FROM orders AS O
+													  JOIN point_accounts AS P ON P.id = O.point_account_id
+													  WHERE P.user_id=$1 AND O.accrual_status='PROCESSED' AND O.accrual > 0 AND O.processed_at >= $2 AND O.processed_at < $3
+													  UNION ALL
+													  SELECT W.processed_at, CASE WHEN P.user_id IS NULL THEN 'household withdrawal' ELSE 'withdrawal' END, W.order_num, -W.amount
+													  FROM withdrawal_history AS W
+													  JOIN point_accounts AS P ON P.id = W.point_account_id
+													  WHERE W.user_id=$1 AND W.processed_at >= $2 AND W.processed_at < $3
+													  UNION ALL
+													  SELECT A.created_at, 'adjustment', A.reason, A.amount
+													  FROM balance_adjustments AS A
+													  JOIN point_accounts AS P ON P.id = A.point_account_id
+													  WHERE P.user_id=$1 AND A.created_at >= $2 AND A.created_at < $3
+													  UNION ALL
+													  SELECT T.created_at, 'transfer', U.username, -T.amount
+													  FROM point_transfers AS T
+													  JOIN point_accounts AS P ON P.id = T.sender_account_id
+													  JOIN point_accounts AS R ON R.id = T.recipient_account_id
+													  JOIN users AS U ON U.id = R.user_id
+													  WHERE P.user_id=$1 AND T.created_at >= $2 AND T.created_at < $3
+													  UNION ALL
+													  SELECT T.resolved_at, 'transfer', U.username, T.amount
+													  FROM point_transfers AS T
+													  JOIN point_accounts AS P ON P.id = T.sender_account_id
+													  JOIN point_accounts AS R ON R.id = T.recipient_account_id
+													  JOIN users AS U ON U.id = R.user_id
+													  WHERE P.user_id=$1 AND T.status IN ('declined', 'cancelled') AND T.resolved_at >= $2 AND T.resolved_at < $3
+													  UNION ALL
+													  SELECT T.resolved_at, 'transfer', COALESCE(U.username, H.name), T.amount
+													  FROM point_transfers AS T
+													  JOIN point_accounts AS P ON P.id = T.recipient_account_id
+													  JOIN point_accounts AS S ON S.id = T.sender_account_id
+													  LEFT JOIN users AS U ON U.id = S.user_id
+													  LEFT JOIN households AS H ON H.point_account_id = S.id
+													  WHERE P.user_id=$1 AND T.status='completed' AND T.resolved_at >= $2 AND T.resolved_at < $3
+													  ORDER BY 1, 2`, userID, from, to)
+	if err != nil {
+		return models.Ledger{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.StatementEntry
+		if err := rows.Scan(&entry.Date, &entry.Kind, &entry.Reference, &entry.Amount); err != nil {
+			return models.Ledger{}, err
+		}
+
+		ledger.Entries = append(ledger.Entries, entry)
+	}
+
+	rerr := rows.Close()
+	if rerr != nil {
+		return models.Ledger{}, rerr
+	}
+
+	if err := rows.Err(); err != nil {
+		return models.Ledger{}, err
+	}
+
+	return ledger, nil
+}
+
+func (r *DBStatementRepository) GetStatement(ctx context.Context, userID int, periodStart time.Time) (*models.StatementDocument, error) {
+	var document models.StatementDocument
+	row := r.db.DBConnection.QueryRowContext(ctx, "SELECT html, pdf FROM statements WHERE user_id=$1 AND period_start=$2", userID, periodStart)
+
+	if err := row.Scan(&document.HTML, &document.PDF); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &document, nil
+}
+
+func (r *DBStatementRepository) SaveStatement(ctx context.Context, userID int, periodStart time.Time, document models.StatementDocument) error {
+	_, err := r.db.DBConnection.ExecContext(ctx, `INSERT INTO statements (user_id, period_start, html, pdf) VALUES ($1, $2, $3, $4)
+												  ON CONFLICT (user_id, period_start) DO NOTHING`, userID, periodStart, string(document.HTML), document.PDF)
+
+	return err
+}
+
+func (r *DBStatementRepository) ListStatementPeriods(ctx context.Context, userID int) ([]time.Time, error) {
+	rows, err := r.db.DBConnection.QueryContext(ctx, "SELECT period_start FROM statements WHERE user_id=$1 ORDER BY period_start DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	periods := make([]time.Time, 0)
+
+	for rows.Next() {
+		var periodStart time.Time
+		if err := rows.Scan(&periodStart); err != nil {
+			return nil, err
+		}
+
+		periods = append(periods, periodStart)
+	}
+
+	rerr := rows.Close()
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return periods, nil
+}
+
+func (r *DBStatementRepository) GetUserIDsWithoutStatement(ctx context.Context, periodStart time.Time) ([]int, error) {
+	rows, err := r.db.DBConnection.QueryContext(ctx, `SELECT U.id
+													  FROM users AS U
+													  WHERE U.deleted_at IS NULL
+													  AND NOT EXISTS (SELECT 1 FROM statements AS S WHERE S.user_id = U.id AND S.period_start = $1)
+													  ORDER BY U.id`, periodStart)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	userIDs := make([]int, 0)
+
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+
+		userIDs = append(userIDs, userID)
+	}
+
+	rerr := rows.Close()
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return userIDs, nil
+}
@@ -0,0 +1,316 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/rovany706/loyalty-gopher/internal/database"
+	"github.com/rovany706/loyalty-gopher/internal/events"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// transfersQuery selects the transfers of the user $1 as seen by that user. The pool
+// of a closed household is sent to its owner and named after the household.
+const transfersQuery = `SELECT T.id,
+						CASE WHEN S.user_id=$1 THEN 'outgoing' ELSE 'incoming' END,
+						CASE WHEN S.user_id=$1 THEN RU.username ELSE COALESCE(SU.username, H.name) END,
+						T.amount, T.status, T.created_at, T.resolved_at
+						FROM point_transfers AS T
+						JOIN point_accounts AS S ON S.id = T.sender_account_id
+						JOIN point_accounts AS R ON R.id = T.recipient_account_id
+						LEFT JOIN users AS SU ON SU.id = S.user_id
+						LEFT JOIN households AS H ON H.point_account_id = S.id
+						JOIN users AS RU ON RU.id = R.user_id
+						WHERE (S.user_id=$1 OR R.user_id=$1)`
+
+type DBTransferRepository struct {
+	db        *database.Database
+	publisher events.Publisher
+	logger    *zap.Logger
+}
+
+func NewDBTransferRepository(db *database.Database, publisher events.Publisher, logger *zap.Logger) *DBTransferRepository {
+	return &DBTransferRepository{
+		db:        db,
+		publisher: publisher,
+		logger:    logger,
+	}
+}
+
+type transferAccount struct {
+	id      int
+	balance decimal.Decimal
+}
+
+func (r *DBTransferRepository) CreateTransfer(ctx context.Context, senderID int, recipientLogin string, amount decimal.Decimal, requireAcceptance bool, limits models.TransferLimits) (*models.Transfer, error) {
+	tx, err := r.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var recipientID int
+	var recipientName string
+	row := tx.QueryRowContext(ctx, `SELECT id, username FROM users
+									WHERE LOWER(username)=LOWER($1) AND disabled_at IS NULL AND deleted_at IS NULL`, recipientLogin)
+	if err := row.Scan(&recipientID, &recipientName); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecipientNotFound
+		}
+		return nil, err
+	}
+
+	if recipientID == senderID {
+		return nil, ErrTransferToSelf
+	}
+
+	accounts, err := lockTransferAccounts(ctx, tx, senderID, recipientID)
+	if err != nil {
+		return nil, err
+	}
+
+	sender, recipient := accounts[senderID], accounts[recipientID]
+	now := time.Now()
+
+	if err := checkTransferLimits(ctx, tx, sender.id, amount, limits, now); err != nil {
+		return nil, err
+	}
+
+	if sender.balance.LessThan(amount) {
+		return nil, ErrNotEnoughPoints
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE point_accounts SET balance=balance-$1 WHERE id=$2", amount, sender.id); err != nil {
+		return nil, err
+	}
+
+	transfer := &models.Transfer{
+		Direction:    models.TransferOutgoing,
+		Counterparty: recipientName,
+		Sum:          amount,
+		Status:       models.TransferStatusPending,
+		CreatedAt:    models.RFC3339Time(now),
+	}
+
+	var resolvedAt *time.Time
+	if !requireAcceptance {
+		if _, err := tx.ExecContext(ctx, "UPDATE point_accounts SET balance=balance+$1 WHERE id=$2", amount, recipient.id); err != nil {
+			return nil, err
+		}
+
+		transfer.Status = models.TransferStatusCompleted
+		transfer.ResolvedAt = &transfer.CreatedAt
+		resolvedAt = &now
+	}
+
+	row = tx.QueryRowContext(ctx, `INSERT INTO point_transfers (sender_account_id, recipient_account_id, amount, status, created_at, resolved_at)
+								   VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`, sender.id, recipient.id, amount, transfer.Status, now, resolvedAt)
+	if err := row.Scan(&transfer.ID); err != nil {
+		return nil, err
+	}
+
+	// a pending transfer changes the transfer list of the recipient too
+	for _, userID := range []int{senderID, recipientID} {
+		if err := bumpDataVersion(ctx, tx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	r.logger.Info("transferred points", zap.Int("transfer_id", transfer.ID), zap.Int("sender_id", senderID), zap.Int("recipient_id", recipientID), zap.String("amount", amount.String()))
+	r.publisher.PublishBalanceDelta(senderID, models.BalanceDelta{
+		Current:   amount.Neg(),
+		Withdrawn: decimal.Zero,
+		Reason:    models.BalanceChangeTransfer,
+	})
+	if transfer.Status == models.TransferStatusCompleted {
+		r.publisher.PublishBalanceDelta(recipientID, models.BalanceDelta{
+			Current:   amount,
+			Withdrawn: decimal.Zero,
+			Reason:    models.BalanceChangeTransfer,
+		})
+	}
+
+	return transfer, nil
+}
+
+// lockTransferAccounts locks the accounts of both users in the order of their ids,
+// so that transfers between the same users in opposite directions cannot deadlock.
+func lockTransferAccounts(ctx context.Context, tx *sql.Tx, senderID int, recipientID int) (map[int]transferAccount, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT id, user_id, balance FROM point_accounts WHERE user_id = ANY($1) ORDER BY id FOR UPDATE", []int{senderID, recipientID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	accounts := make(map[int]transferAccount, 2)
+
+	for rows.Next() {
+		var userID int
+		var account transferAccount
+		if err := rows.Scan(&account.id, &userID, &account.balance); err != nil {
+			return nil, err
+		}
+
+		accounts[userID] = account
+	}
+
+	rerr := rows.Close()
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, ok := accounts[senderID]; !ok {
+		return nil, ErrUserNotFound
+	}
+
+	if _, ok := accounts[recipientID]; !ok {
+		return nil, ErrRecipientNotFound
+	}
+
+	return accounts, nil
+}
+
+// checkTransferLimits counts the pending and completed transfers of the last 24 hours.
+func checkTransferLimits(ctx context.Context, tx *sql.Tx, senderAccountID int, amount decimal.Decimal, limits models.TransferLimits, now time.Time) error {
+	if !limits.DailyMaxSum.IsPositive() && limits.DailyMaxCount <= 0 {
+		return nil
+	}
+
+	var count int
+	var sum decimal.Decimal
+	row := tx.QueryRowContext(ctx, `SELECT COUNT(*), COALESCE(SUM(amount), 0)
+									FROM point_transfers
+									WHERE sender_account_id=$1 AND created_at >= $2 AND status IN ($3, $4)`,
+		senderAccountID, now.Add(-24*time.Hour), models.TransferStatusPending, models.TransferStatusCompleted)
+	if err := row.Scan(&count, &sum); err != nil {
+		return err
+	}
+
+	if limits.DailyMaxCount > 0 && count+1 > limits.DailyMaxCount {
+		return ErrDailyTransferLimit
+	}
+
+	if limits.DailyMaxSum.IsPositive() && sum.Add(amount).GreaterThan(limits.DailyMaxSum) {
+		return ErrDailyTransferLimit
+	}
+
+	return nil
+}
+
+func (r *DBTransferRepository) AcceptTransfer(ctx context.Context, recipientID int, transferID int) (*models.Transfer, error) {
+	return r.resolveTransfer(ctx, "R", recipientID, transferID, models.TransferStatusCompleted)
+}
+
+func (r *DBTransferRepository) DeclineTransfer(ctx context.Context, recipientID int, transferID int) (*models.Transfer, error) {
+	return r.resolveTransfer(ctx, "R", recipientID, transferID, models.TransferStatusDeclined)
+}
+
+func (r *DBTransferRepository) CancelTransfer(ctx context.Context, senderID int, transferID int) (*models.Transfer, error) {
+	return r.resolveTransfer(ctx, "S", senderID, transferID, models.TransferStatusCancelled)
+}
+
+// resolveTransfer moves a pending transfer of the user on the given side, S for
+// the sender or R for the recipient, to status and credits the held points to the
+// recipient of a completed transfer or back to the sender otherwise.
+func (r *DBTransferRepository) resolveTransfer(ctx context.Context, side string, userID int, transferID int, status models.TransferStatus) (*models.Transfer, error) {
+	tx, err := r.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var amount decimal.Decimal
+	var senderID, recipientID int
+	// claiming the row first makes concurrent accepts and cancels of a transfer wait for each other
+	row := tx.QueryRowContext(ctx, `UPDATE point_transfers AS T
+									SET status=$3, resolved_at=NOW()
+									FROM point_accounts AS S, point_accounts AS R
+									WHERE T.id=$1 AND T.status='pending' AND S.id = T.sender_account_id AND R.id = T.recipient_account_id AND `+side+`.user_id=$2
+									RETURNING T.amount, S.user_id, R.user_id`, transferID, userID, status)
+	if err := row.Scan(&amount, &senderID, &recipientID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTransferNotFound
+		}
+		return nil, err
+	}
+
+	creditedID := senderID
+	if status == models.TransferStatusCompleted {
+		creditedID = recipientID
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE point_accounts SET balance=balance+$1 WHERE user_id=$2", amount, creditedID); err != nil {
+		return nil, err
+	}
+
+	for _, id := range []int{senderID, recipientID} {
+		if err := bumpDataVersion(ctx, tx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	r.logger.Info("resolved transfer", zap.Int("transfer_id", transferID), zap.String("status", string(status)))
+	r.publisher.PublishBalanceDelta(creditedID, models.BalanceDelta{
+		Current:   amount,
+		Withdrawn: decimal.Zero,
+		Reason:    models.BalanceChangeTransfer,
+	})
+
+	row = r.db.DBConnection.QueryRowContext(ctx, transfersQuery+" AND T.id=$2", userID, transferID)
+
+	return scanTransfer(row)
+}
+
+func (r *DBTransferRepository) GetUserTransfers(ctx context.Context, userID int) ([]models.Transfer, error) {
+	rows, err := r.db.DBConnection.QueryContext(ctx, transfersQuery+" ORDER BY T.created_at DESC, T.id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	transfers := make([]models.Transfer, 0)
+
+	for rows.Next() {
+		transfer, err := scanTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		transfers = append(transfers, *transfer)
+	}
+
+	rerr := rows.Close()
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return transfers, nil
+}
+
+func scanTransfer(row rowScanner) (*models.Transfer, error) {
+	var transfer models.Transfer
+	err := row.Scan(&transfer.ID, &transfer.Direction, &transfer.Counterparty, &transfer.Sum, &transfer.Status, &transfer.CreatedAt, &transfer.ResolvedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &transfer, nil
+}
@@ -4,25 +4,33 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rovany706/loyalty-gopher/internal/auth"
 	"github.com/rovany706/loyalty-gopher/internal/database"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/rovany706/loyalty-gopher/internal/helpers"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"go.uber.org/zap"
 )
 
 type DBUserRepository struct {
-	db *database.Database
+	db             *database.Database
+	passwordHasher auth.PasswordHasher
+	logger         *zap.Logger
 }
 
-func NewDBUserRepository(db *database.Database) *DBUserRepository {
+func NewDBUserRepository(db *database.Database, passwordHasher auth.PasswordHasher, logger *zap.Logger) *DBUserRepository {
 	return &DBUserRepository{
-		db: db,
+		db:             db,
+		passwordHasher: passwordHasher,
+		logger:         logger,
 	}
 }
 
 func (r *DBUserRepository) Register(ctx context.Context, login string, password string) (userID int, err error) {
-	hashedPassword, err := hashPassword(password)
+	hashedPassword, err := r.passwordHasher.Hash(password)
 
 	if err != nil {
 		return UnauthorizedUserID, err
@@ -35,8 +43,29 @@ func (r *DBUserRepository) Register(ctx context.Context, login string, password
 
 	defer tx.Rollback()
 
+	userID, err = createUser(ctx, tx, login, &hashedPassword)
+	if err != nil {
+		return UnauthorizedUserID, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return UnauthorizedUserID, err
+	}
+
+	return userID, nil
+}
+
+// createUser inserts the user together with their points account. Users
+// signing in only through an identity provider have no password hash.
+func createUser(ctx context.Context, tx *sql.Tx, login string, hashedPassword *string) (userID int, err error) {
+	loyaltyCardID, err := helpers.GenerateLoyaltyCardID()
+	if err != nil {
+		return UnauthorizedUserID, err
+	}
+
 	// create user
-	row := tx.QueryRowContext(ctx, "INSERT INTO users (username, pw_hash) VALUES ($1, $2) RETURNING id", login, hashedPassword)
+	row := tx.QueryRowContext(ctx, "INSERT INTO users (username, pw_hash, loyalty_card_id) VALUES ($1, $2, $3) RETURNING id", login, hashedPassword, loyaltyCardID)
 	err = row.Scan(&userID)
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -47,27 +76,30 @@ func (r *DBUserRepository) Register(ctx context.Context, login string, password
 		return UnauthorizedUserID, err
 	}
 
-	// create points account for user
-	_, err = tx.ExecContext(ctx, "INSERT INTO point_accounts (user_id, balance) VALUES ($1, 0)", userID)
-	if err != nil {
-		return UnauthorizedUserID, err
-	}
-
-	err = tx.Commit()
-	if err != nil {
+	if _, err := createPointAccount(ctx, tx, &userID); err != nil {
 		return UnauthorizedUserID, err
 	}
 
 	return userID, nil
 }
 
+// createPointAccount opens an empty account of the user, or of a household
+// shared by several users when userID is nil.
+func createPointAccount(ctx context.Context, tx *sql.Tx, userID *int) (accountID int, err error) {
+	row := tx.QueryRowContext(ctx, "INSERT INTO point_accounts (user_id, balance) VALUES ($1, 0) RETURNING id", userID)
+	err = row.Scan(&accountID)
+
+	return accountID, err
+}
+
 func (r *DBUserRepository) Login(ctx context.Context, login string, password string) (userID int, err error) {
-	row := r.db.DBConnection.QueryRowContext(ctx, "SELECT id, pw_hash FROM users WHERE username=$1", login)
+	row := r.db.DBConnection.QueryRowContext(ctx, "SELECT id, pw_hash, disabled_at IS NOT NULL FROM users WHERE LOWER(username)=LOWER($1)", login)
 	var userInfo struct {
 		id             int
-		hashedPassword string
+		hashedPassword sql.NullString
+		disabled       bool
 	}
-	err = row.Scan(&userInfo.id, &userInfo.hashedPassword)
+	err = row.Scan(&userInfo.id, &userInfo.hashedPassword, &userInfo.disabled)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -77,21 +109,312 @@ func (r *DBUserRepository) Login(ctx context.Context, login string, password str
 		return UnauthorizedUserID, err
 	}
 
-	isPasswordCorrect := checkPassword(password, userInfo.hashedPassword)
+	// users created through an identity provider cannot sign in with a password
+	if !userInfo.hashedPassword.Valid {
+		return UnauthorizedUserID, nil
+	}
+
+	isPasswordCorrect, err := r.passwordHasher.Verify(password, userInfo.hashedPassword.String)
+	if err != nil {
+		return UnauthorizedUserID, err
+	}
 
 	if !isPasswordCorrect {
 		return UnauthorizedUserID, nil
 	}
 
+	if userInfo.disabled {
+		return UnauthorizedUserID, ErrUserDisabled
+	}
+
+	if r.passwordHasher.NeedsRehash(userInfo.hashedPassword.String) {
+		// the password is already verified, so a failed rehash must not fail the login
+		if err := r.rehashPassword(ctx, userInfo.id, password); err != nil {
+			r.logger.Warn("failed to rehash password", zap.Int("user_id", userInfo.id), zap.Error(err))
+		}
+	}
+
 	return userInfo.id, nil
 }
 
-func hashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return string(bytes), err
+func (r *DBUserRepository) GetUser(ctx context.Context, userID int) (*models.User, error) {
+	row := r.db.DBConnection.QueryRowContext(ctx, "SELECT id, username, role, loyalty_card_id, disabled_at, deletion_requested_at FROM users WHERE id=$1", userID)
+
+	return scanUserRow(row)
+}
+
+func (r *DBUserRepository) FindUserByLogin(ctx context.Context, login string) (*models.User, error) {
+	row := r.db.DBConnection.QueryRowContext(ctx, "SELECT id, username, role, loyalty_card_id, disabled_at, deletion_requested_at FROM users WHERE LOWER(username)=LOWER($1)", login)
+
+	return scanUserRow(row)
+}
+
+func (r *DBUserRepository) FindUserByLoyaltyCard(ctx context.Context, cardID string) (*models.User, error) {
+	row := r.db.DBConnection.QueryRowContext(ctx, "SELECT id, username, role, loyalty_card_id, disabled_at, deletion_requested_at FROM users WHERE loyalty_card_id=$1", cardID)
+
+	return scanUserRow(row)
+}
+
+// SetUserRole ends the sessions of the user, their tokens carry the old role.
+func (r *DBUserRepository) SetUserRole(ctx context.Context, userID int, role models.Role) error {
+	tx, err := r.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "UPDATE users SET role=$1 WHERE id=$2", role, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := checkUserAffected(result); err != nil {
+		return err
+	}
+
+	if err := revokeUserSessions(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	r.logger.Info("changed user role", zap.Int("user_id", userID), zap.String("role", string(role)))
+
+	return nil
+}
+
+// SetUserDisabled ends the sessions of a disabled user, so enabling
+// the user again does not bring them back.
+func (r *DBUserRepository) SetUserDisabled(ctx context.Context, userID int, disabled bool) error {
+	query := "UPDATE users SET disabled_at=NULL WHERE id=$1"
+	if disabled {
+		query = "UPDATE users SET disabled_at=COALESCE(disabled_at, NOW()) WHERE id=$1"
+	}
+
+	tx, err := r.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := checkUserAffected(result); err != nil {
+		return err
+	}
+
+	if disabled {
+		if err := revokeUserSessions(ctx, tx, userID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	r.logger.Info("changed user disabled state", zap.Int("user_id", userID), zap.Bool("disabled", disabled))
+
+	return nil
+}
+
+func (r *DBUserRepository) RequestDeletion(ctx context.Context, userID int) (time.Time, error) {
+	row := r.db.DBConnection.QueryRowContext(ctx, `UPDATE users SET deletion_requested_at=COALESCE(deletion_requested_at, NOW())
+													WHERE id=$1 AND deleted_at IS NULL
+													RETURNING deletion_requested_at`, userID)
+
+	var requestedAt time.Time
+	if err := row.Scan(&requestedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, ErrUserNotFound
+		}
+
+		return time.Time{}, err
+	}
+
+	r.logger.Info("user requested account deletion", zap.Int("user_id", userID))
+
+	return requestedAt, nil
+}
+
+func (r *DBUserRepository) CancelDeletion(ctx context.Context, userID int) error {
+	result, err := r.db.DBConnection.ExecContext(ctx, "UPDATE users SET deletion_requested_at=NULL WHERE id=$1 AND deletion_requested_at IS NOT NULL AND deleted_at IS NULL", userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrDeletionNotRequested
+	}
+
+	r.logger.Info("user cancelled account deletion", zap.Int("user_id", userID))
+
+	return nil
+}
+
+func (r *DBUserRepository) AnonymizeUsers(ctx context.Context, requestedBefore time.Time) (int, error) {
+	rows, err := r.db.DBConnection.QueryContext(ctx, "SELECT id FROM users WHERE deletion_requested_at < $1 AND deleted_at IS NULL", requestedBefore)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	userIDs := make([]int, 0)
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return 0, err
+		}
+
+		userIDs = append(userIDs, userID)
+	}
+
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	anonymized := 0
+	for _, userID := range userIDs {
+		ok, err := r.anonymizeUser(ctx, userID, requestedBefore)
+		if err != nil {
+			return anonymized, err
+		}
+
+		if ok {
+			anonymized++
+		}
+	}
+
+	return anonymized, nil
 }
 
-func checkPassword(password string, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+// anonymizeUser removes everything that identifies the user but keeps the
+// users row, so orders, point accounts and withdrawals still reference it.
+// The card number gets a leading 0, which is never issued.
+func (r *DBUserRepository) anonymizeUser(ctx context.Context, userID int, requestedBefore time.Time) (bool, error) {
+	tx, err := r.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	defer tx.Rollback()
+
+	// the user may have cancelled since the batch was selected
+	result, err := tx.ExecContext(ctx, `UPDATE users
+										SET username='~deleted-' || id,
+											pw_hash=NULL,
+											loyalty_card_id='0' || LPAD(id::TEXT, 15, '0'),
+											disabled_at=COALESCE(disabled_at, NOW()),
+											deleted_at=NOW()
+										WHERE id=$1 AND deletion_requested_at < $2 AND deleted_at IS NULL`, userID, requestedBefore)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if affected == 0 {
+		return false, nil
+	}
+
+	if err := handOverHousehold(ctx, tx, userID); err != nil {
+		return false, err
+	}
+
+	// deleting the sessions ends them as well, a token without its session is refused
+	for _, table := range []string{"user_totp", "user_recovery_codes", "sessions", "user_identities", "statements", "order_upload_events", "household_members", "redemption_codes"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE user_id=$1", userID); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	r.logger.Info("anonymized deleted user", zap.Int("user_id", userID))
+
+	return true, nil
+}
+
+func (r *DBUserRepository) GetDataVersion(ctx context.Context, userID int) (models.DataVersion, error) {
+	row := r.db.DBConnection.QueryRowContext(ctx, "SELECT data_version, data_modified_at FROM users WHERE id=$1", userID)
+
+	var version models.DataVersion
+	if err := row.Scan(&version.Version, &version.ModifiedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.DataVersion{}, ErrUserNotFound
+		}
+
+		return models.DataVersion{}, err
+	}
+
+	return version, nil
+}
+
+// bumpDataVersion marks the user's orders and balance as changed. It runs in the
+// transaction making the change, so a new version is never seen before the data.
+func bumpDataVersion(ctx context.Context, tx *sql.Tx, userID int) error {
+	_, err := tx.ExecContext(ctx, "UPDATE users SET data_version=data_version+1, data_modified_at=NOW() WHERE id=$1", userID)
+
+	return err
+}
+
+func scanUserRow(row *sql.Row) (*models.User, error) {
+	var user models.User
+	err := row.Scan(&user.ID, &user.Login, &user.Role, &user.LoyaltyCardID, &user.DisabledAt, &user.DeletionRequestedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func checkUserAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *DBUserRepository) rehashPassword(ctx context.Context, userID int, password string) error {
+	hashedPassword, err := r.passwordHasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.DBConnection.ExecContext(ctx, "UPDATE users SET pw_hash=$1 WHERE id=$2", hashedPassword, userID)
+	if err != nil {
+		return err
+	}
+
+	r.logger.Info("rehashed password", zap.Int("user_id", userID))
+
+	return nil
 }
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/rovany706/loyalty-gopher/internal/database"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+type DBWithdrawalRuleRepository struct {
+	db     *database.Database
+	logger *zap.Logger
+}
+
+func NewDBWithdrawalRuleRepository(db *database.Database, logger *zap.Logger) *DBWithdrawalRuleRepository {
+	return &DBWithdrawalRuleRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// maxEvaluationAmount is the largest sum the amount column of the evaluations holds.
+var maxEvaluationAmount = decimal.RequireFromString("9999999999.99")
+
+// guardWithdrawal calls guard with the totals of the user read in the withdrawal transaction tx.
+func guardWithdrawal(ctx context.Context, tx *sql.Tx, userID int, guard WithdrawalGuard) error {
+	return guard(func(since time.Time) (models.WithdrawalTotals, error) {
+		// withdrawals from a household pool count towards the limits of the member who made them
+		row := tx.QueryRowContext(ctx, `SELECT COUNT(*), COALESCE(SUM(amount), 0)
+										FROM withdrawal_history
+										WHERE user_id=$1 AND processed_at >= $2`, userID, since)
+
+		var totals models.WithdrawalTotals
+		if err := row.Scan(&totals.Count, &totals.Sum); err != nil {
+			return models.WithdrawalTotals{}, err
+		}
+
+		return totals, nil
+	})
+}
+
+func (r *DBWithdrawalRuleRepository) RecordRuleEvaluations(ctx context.Context, evaluations []models.WithdrawalRuleEvaluation) error {
+	tx, err := r.db.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, evaluation := range evaluations {
+		// sums beyond the column are stored capped, no balance covers them anyway
+		amount := decimal.Max(decimal.Min(evaluation.Sum.Round(2), maxEvaluationAmount), maxEvaluationAmount.Neg())
+		_, err := tx.ExecContext(ctx, `INSERT INTO withdrawal_rule_evaluations (user_id, order_num, amount, rule, passed, limit_value, actual_value, evaluated_at)
+									   VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			evaluation.UserID, evaluation.OrderNum, amount, evaluation.Rule, evaluation.Passed, evaluation.Limit, evaluation.Actual, evaluation.EvaluatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rovany706/loyalty-gopher/internal/models"
+)
+
+var ErrFraudReviewNotFound = errors.New("pending fraud review not found")
+
+type FraudRepository interface {
+	RecordOrderUploads(ctx context.Context, userID int, clientIP string, outcomes []models.OrderBatchStatus, now time.Time) error
+	GetUserUploadStats(ctx context.Context, userID int, since time.Time) (models.OrderUploadStats, error)
+	GetIPUploadStats(ctx context.Context, clientIP string, since time.Time) (models.OrderUploadStats, error)
+	// Throttle keeps the later of the subject's current and new throttle.
+	Throttle(ctx context.Context, subject string, until time.Time) error
+	// GetThrottledUntil returns the latest throttle of the subjects, zero if none is throttled.
+	GetThrottledUntil(ctx context.Context, subjects []string, now time.Time) (time.Time, error)
+	// ClearUser deletes the throttle and the uploads of the user, so the user is scored afresh.
+	ClearUser(ctx context.Context, userID int) error
+	// FlagUser opens a review of the user or raises the score of the pending one.
+	FlagUser(ctx context.Context, userID int, score int, stats models.OrderUploadStats, now time.Time) error
+	GetFraudReviews(ctx context.Context, status models.FraudReviewStatus) ([]models.FraudReview, error)
+	// ResolveFraudReview returns ErrFraudReviewNotFound unless the review is pending.
+	ResolveFraudReview(ctx context.Context, reviewID int, status models.FraudReviewStatus, resolvedBy int, now time.Time) (*models.FraudReview, error)
+	// PruneFraudData deletes uploads older than before and throttles that have ended.
+	PruneFraudData(ctx context.Context, before time.Time, now time.Time) error
+}
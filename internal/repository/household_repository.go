@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrHouseholdNotFound       = errors.New("household not found")
+	ErrNotHouseholdOwner       = errors.New("only the household owner can do this")
+	ErrAlreadyInHousehold      = errors.New("user already belongs or is invited to a household")
+	ErrInviteeNotFound         = errors.New("user to invite not found")
+	ErrHouseholdMemberNotFound = errors.New("household member not found")
+	ErrOwnerHasMembers         = errors.New("household owner cannot leave while other members remain")
+	ErrAllowanceExceeded       = errors.New("withdrawal exceeds the household allowance")
+	ErrInvalidWithdrawSum      = errors.New("withdrawal sum must be positive")
+)
+
+type HouseholdRepository interface {
+	// CreateHousehold opens a pool account with the user as its owner.
+	CreateHousehold(ctx context.Context, ownerID int, name string) (*models.Household, error)
+	// GetUserHousehold returns the household the user belongs or is invited to.
+	GetUserHousehold(ctx context.Context, userID int) (*models.Household, error)
+	InviteMember(ctx context.Context, ownerID int, login string) (*models.Household, error)
+	AcceptInvitation(ctx context.Context, userID int) (*models.Household, error)
+	// LeaveHousehold declines an invitation or ends a membership. The owner can only
+	// leave last, which closes the household and moves the pool to the owner's account.
+	LeaveHousehold(ctx context.Context, userID int) error
+	RemoveMember(ctx context.Context, ownerID int, memberID int) error
+	// SetAllowance sets what a member may still withdraw, nil for no limit.
+	SetAllowance(ctx context.Context, ownerID int, memberID int, allowance *decimal.Decimal) (*models.Household, error)
+	// WithdrawFromHousehold debits the pool and the allowance of an active member.
+	WithdrawFromHousehold(ctx context.Context, userID int, orderNum string, amount decimal.Decimal, guard WithdrawalGuard) error
+}
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrIdentityNotFound = errors.New("external identity is not linked")
+	ErrIdentityConflict = errors.New("external identity is linked to another user")
+)
+
+type IdentityRepository interface {
+	FindUserByIdentity(ctx context.Context, issuer string, subject string) (userID int, err error)
+	LinkIdentity(ctx context.Context, userID int, issuer string, subject string) error
+	// RegisterExternalUser creates a user without a password and links the identity to it.
+	RegisterExternalUser(ctx context.Context, login string, issuer string, subject string) (userID int, err error)
+}
@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rovany706/loyalty-gopher/internal/models"
+)
+
+var (
+	ErrMerchantNotFound = errors.New("merchant not found")
+	ErrAPIKeyNotFound   = errors.New("api key not found")
+)
+
+type MerchantRepository interface {
+	CreateMerchant(ctx context.Context, name string) (*models.Merchant, error)
+	GetMerchant(ctx context.Context, merchantID int) (*models.Merchant, error)
+	CreateAPIKey(ctx context.Context, key models.APIKey) (*models.APIKey, error)
+	GetMerchantAPIKeys(ctx context.Context, merchantID int) ([]models.APIKey, error)
+	// GetActiveAPIKeyByPrefix returns nil for unknown and revoked keys.
+	GetActiveAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, merchantID int, keyID int) error
+	TouchAPIKey(ctx context.Context, keyID int) error
+}
@@ -10,11 +10,27 @@ import (
 
 var (
 	ErrOrderConflict = errors.New("order already exists")
+	ErrOrderNotFound = errors.New("order not found")
 )
 
 type OrderRepository interface {
 	GetOrder(ctx context.Context, orderNum string) (*models.Order, error)
 	GetUserOrders(ctx context.Context, userID int) ([]models.Order, error)
+	// GetUserOrdersPage returns orders newest first with their stored statuses and
+	// the total number of the user's orders.
+	GetUserOrdersPage(ctx context.Context, userID int, limit int, offset int) ([]models.Order, int, error)
+	// StreamUserOrders calls fn for every order of the user newest first, shown the
+	// same way as GetUserOrders shows them, while reading them from the database.
+	StreamUserOrders(ctx context.Context, userID int, fn func(models.Order) error) error
+	// GetOrdersByUserIDs returns the orders of every given user newest first,
+	// shown the same way as GetUserOrders shows them.
+	GetOrdersByUserIDs(ctx context.Context, userIDs []int) (map[int][]models.Order, error)
+	// GetUserPendingOrders returns the orders of the user the accrual system has not
+	// finished with, with the statuses as stored.
+	GetUserPendingOrders(ctx context.Context, userID int) ([]models.Order, error)
 	AddOrder(ctx context.Context, userID int, orderNum string) error
+	// AddOrders inserts the orders in one transaction and reports for each number
+	// whether it was accepted, already uploaded by the user or taken by another user.
+	AddOrders(ctx context.Context, userID int, orderNums []string) ([]models.OrderBatchResult, error)
 	UpdateOrderStatus(ctx context.Context, orderNum string, newAccrualStatus models.AccrualStatus, accrualAmount *decimal.Decimal) error
 }
@@ -13,5 +13,15 @@ var ErrNotEnoughPoints = errors.New("not enough points to withdraw")
 type PointsRepository interface {
 	GetUserBalance(ctx context.Context, userID int) (decimal.Decimal, error)
 	GetUserWithdrawalHistory(ctx context.Context, userID int) ([]models.WithdrawHistoryEntry, error)
-	WithdrawPoints(ctx context.Context, userID int, orderNum string, amount decimal.Decimal) error
+	GetUserWithdrawalsPage(ctx context.Context, userID int, limit int, offset int) ([]models.WithdrawHistoryEntry, int, error)
+	// StreamUserWithdrawals calls fn for every withdrawal of the user newest first
+	// while reading them from the database.
+	StreamUserWithdrawals(ctx context.Context, userID int, fn func(models.WithdrawHistoryEntry) error) error
+	// GetBalancesByUserIDs and GetWithdrawalsByUserIDs load the data of several users in one query.
+	GetBalancesByUserIDs(ctx context.Context, userIDs []int) (map[int]models.GetUserBalanceResponse, error)
+	GetWithdrawalsByUserIDs(ctx context.Context, userIDs []int) (map[int][]models.WithdrawHistoryEntry, error)
+	// WithdrawPoints calls guard before taking the amount from the locked account.
+	WithdrawPoints(ctx context.Context, userID int, orderNum string, amount decimal.Decimal, guard WithdrawalGuard) error
+	AdjustBalance(ctx context.Context, userID int, adminUserID int, amount decimal.Decimal, reason string) error
+	GetUserBalanceAdjustments(ctx context.Context, userID int) ([]models.BalanceAdjustment, error)
 }
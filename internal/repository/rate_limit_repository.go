@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/rovany706/loyalty-gopher/internal/models"
+)
+
+type RateLimitRepository interface {
+	// TakeToken takes a token from the bucket stored under key, shared by every
+	// instance using the database.
+	TakeToken(ctx context.Context, key string, limit models.RateLimit, now time.Time) (models.RateLimitDecision, error)
+}
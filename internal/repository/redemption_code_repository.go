@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrInvalidRedemptionCode = errors.New("invalid or expired redemption code")
+
+type RedemptionCodeRepository interface {
+	CreateRedemptionCode(ctx context.Context, userID int, codeHash string, expiresAt time.Time) error
+	// UseRedemptionCode marks an unused and unexpired code of the user as used
+	// and reports false if there is none.
+	UseRedemptionCode(ctx context.Context, userID int, codeHash string) (bool, error)
+}
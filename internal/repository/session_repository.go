@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rovany706/loyalty-gopher/internal/models"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+type SessionRepository interface {
+	CreateSession(ctx context.Context, userID int, userAgent string, ip string) (sessionID int, err error)
+	GetUserSessions(ctx context.Context, userID int) ([]models.Session, error)
+	RevokeSession(ctx context.Context, userID int, sessionID int) error
+	// TouchSession reports whether the session is still active and its user
+	// is not disabled, and updates last_seen_at.
+	TouchSession(ctx context.Context, userID int, sessionID int) (bool, error)
+}
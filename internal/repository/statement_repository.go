@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/rovany706/loyalty-gopher/internal/models"
+)
+
+type StatementRepository interface {
+	// GetLedger returns the balance changes of the user in [from, to) oldest first,
+	// with the balance before from.
+	GetLedger(ctx context.Context, userID int, from time.Time, to time.Time) (models.Ledger, error)
+	// GetStatement returns nil if no statement was generated for the month starting at periodStart.
+	GetStatement(ctx context.Context, userID int, periodStart time.Time) (*models.StatementDocument, error)
+	// SaveStatement keeps the statement already saved for the month, if any.
+	SaveStatement(ctx context.Context, userID int, periodStart time.Time, document models.StatementDocument) error
+	ListStatementPeriods(ctx context.Context, userID int) ([]time.Time, error)
+	// GetUserIDsWithoutStatement returns the active users that have no statement for the month yet.
+	GetUserIDsWithoutStatement(ctx context.Context, periodStart time.Time) ([]int, error)
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrRecipientNotFound  = errors.New("recipient not found")
+	ErrTransferToSelf     = errors.New("cannot transfer points to yourself")
+	ErrDailyTransferLimit = errors.New("daily transfer limit exceeded")
+	ErrTransferNotFound   = errors.New("pending transfer not found")
+)
+
+type TransferRepository interface {
+	// CreateTransfer debits the sender and, unless the transfer requires acceptance,
+	// credits the recipient in the same transaction. The limits are checked while
+	// the account of the sender is locked.
+	CreateTransfer(ctx context.Context, senderID int, recipientLogin string, amount decimal.Decimal, requireAcceptance bool, limits models.TransferLimits) (*models.Transfer, error)
+	// AcceptTransfer credits the recipient of a pending transfer, DeclineTransfer
+	// and CancelTransfer return the points to the sender. All of them return
+	// ErrTransferNotFound unless the transfer is pending and the user is on the
+	// accepting, declining or cancelling side of it.
+	AcceptTransfer(ctx context.Context, recipientID int, transferID int) (*models.Transfer, error)
+	DeclineTransfer(ctx context.Context, recipientID int, transferID int) (*models.Transfer, error)
+	CancelTransfer(ctx context.Context, senderID int, transferID int) (*models.Transfer, error)
+	// GetUserTransfers returns the transfers sent and received by the user newest first.
+	GetUserTransfers(ctx context.Context, userID int) ([]models.Transfer, error)
+}
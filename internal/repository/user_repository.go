@@ -3,13 +3,36 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
+
+	"github.com/rovany706/loyalty-gopher/internal/models"
 )
 
 const UnauthorizedUserID = -1
 
-var ErrUserConfict = errors.New("username already registered")
+var (
+	ErrUserConfict  = errors.New("username already registered")
+	ErrUserDisabled = errors.New("user is disabled")
+	ErrUserNotFound = errors.New("user not found")
+
+	ErrDeletionNotRequested = errors.New("account deletion was not requested")
+)
 
 type UserRepository interface {
 	Register(ctx context.Context, login string, password string) (userID int, err error)
 	Login(ctx context.Context, login string, password string) (userID int, err error)
+	GetUser(ctx context.Context, userID int) (*models.User, error)
+	FindUserByLogin(ctx context.Context, login string) (*models.User, error)
+	FindUserByLoyaltyCard(ctx context.Context, cardID string) (*models.User, error)
+	SetUserRole(ctx context.Context, userID int, role models.Role) error
+	SetUserDisabled(ctx context.Context, userID int, disabled bool) error
+	// RequestDeletion schedules the account for anonymization and returns when
+	// deletion was first requested, so repeated requests keep the original date.
+	RequestDeletion(ctx context.Context, userID int) (time.Time, error)
+	CancelDeletion(ctx context.Context, userID int) error
+	// GetDataVersion returns the version of the user's orders and balance, which
+	// every change of them bumps in the same transaction.
+	GetDataVersion(ctx context.Context, userID int) (models.DataVersion, error)
+	// AnonymizeUsers anonymizes accounts whose deletion was requested before the given time.
+	AnonymizeUsers(ctx context.Context, requestedBefore time.Time) (int, error)
 }
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/rovany706/loyalty-gopher/internal/models"
+)
+
+// WithdrawalTotalsFunc counts and sums the user's withdrawals made since the given time.
+type WithdrawalTotalsFunc func(since time.Time) (models.WithdrawalTotals, error)
+
+// WithdrawalGuard is called by a withdrawal inside its transaction once the accounts
+// of the user are locked, so concurrent withdrawals see each other's totals.
+// A returned error refuses the withdrawal.
+type WithdrawalGuard func(totals WithdrawalTotalsFunc) error
+
+type WithdrawalRuleRepository interface {
+	RecordRuleEvaluations(ctx context.Context, evaluations []models.WithdrawalRuleEvaluation) error
+}
@@ -2,34 +2,180 @@ package routes
 
 import (
 	"github.com/gin-gonic/gin"
-	"github.com/rovany706/loyalty-gopher/internal/auth"
 	"github.com/rovany706/loyalty-gopher/internal/handlers"
 	"github.com/rovany706/loyalty-gopher/internal/middleware"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"go.uber.org/zap"
 )
 
-func RegisterAuthHandlers(r *gin.Engine, authHandlers *handlers.AuthHandlers) {
+// Streaming paths hold connections open, so middleware must not buffer their responses.
+const (
+	OrderEventsPath   = "/api/user/orders/events"
+	BalanceStreamPath = "/api/user/balance/ws"
+)
+
+func RegisterAuthHandlers(r *gin.Engine, authHandlers *handlers.AuthHandlers, rateLimit gin.HandlerFunc) {
 	authGroup := r.Group("/api/user")
 	{
+		authGroup.Use(rateLimit)
 		authGroup.POST("/register", authHandlers.RegisterHandler())
 		authGroup.POST("/login", authHandlers.LoginHandler())
+		authGroup.POST("/login/mfa", authHandlers.LoginMFAHandler())
 	}
 }
 
-func RegisterOrderHandlers(r *gin.Engine, orderHandlers *handlers.OrderHandlers, tm auth.TokenManager) {
+func RegisterMFAHandlers(r *gin.Engine, mfaHandlers *handlers.MFAHandlers, authUser gin.HandlerFunc, rateLimit gin.HandlerFunc) {
+	mfaGroup := r.Group("/api/user/2fa")
+	{
+		mfaGroup.Use(authUser, rateLimit)
+		mfaGroup.POST("/totp", mfaHandlers.EnrollTOTPHandler())
+		mfaGroup.POST("/totp/confirm", mfaHandlers.ConfirmTOTPHandler())
+		mfaGroup.POST("/totp/disable", mfaHandlers.DisableTOTPHandler())
+	}
+}
+
+func RegisterOrderHandlers(r *gin.Engine, orderHandlers *handlers.OrderHandlers, authUser gin.HandlerFunc, rateLimit gin.HandlerFunc, conditionalGET gin.HandlerFunc) {
 	orderGroup := r.Group("/api/user")
 	{
-		orderGroup.Use(middleware.AuthUser(tm))
+		orderGroup.Use(authUser, rateLimit)
 		orderGroup.POST("/orders", orderHandlers.PostNewOrderHandler())
-		orderGroup.GET("/orders", orderHandlers.GetUserOrdersHandler())
+		orderGroup.GET("/orders", orderHandlers.QueuePendingOrdersHandler(), conditionalGET, orderHandlers.GetUserOrdersHandler())
+		orderGroup.POST("/orders/batch", orderHandlers.PostOrderBatchHandler())
 	}
 }
 
-func RegisterPointsHandlers(r *gin.Engine, ph *handlers.PointsHandlers, tm auth.TokenManager) {
+func RegisterOrderEventHandlers(r *gin.Engine, oh *handlers.OrderEventHandlers, authUser gin.HandlerFunc, rateLimit gin.HandlerFunc) {
+	r.GET(OrderEventsPath, authUser, rateLimit, oh.OrderEventsHandler())
+}
+
+func RegisterBalanceStreamHandlers(r *gin.Engine, bh *handlers.BalanceStreamHandlers, authUser gin.HandlerFunc, rateLimit gin.HandlerFunc) {
+	r.GET(BalanceStreamPath, authUser, rateLimit, bh.BalanceStreamHandler())
+}
+
+func RegisterGraphQLHandlers(r *gin.Engine, gh *handlers.GraphQLHandlers, authUser gin.HandlerFunc, rateLimit gin.HandlerFunc) {
+	r.POST("/graphql", authUser, rateLimit, gh.GraphQLHandler())
+}
+
+func RegisterPointsHandlers(r *gin.Engine, ph *handlers.PointsHandlers, authUser gin.HandlerFunc, rateLimit gin.HandlerFunc, conditionalGET gin.HandlerFunc) {
 	pointsGroup := r.Group("/api/user")
 	{
-		pointsGroup.Use(middleware.AuthUser(tm))
-		pointsGroup.GET("/balance", ph.UserBalanceHandler())
+		pointsGroup.Use(authUser, rateLimit)
+		pointsGroup.GET("/balance", conditionalGET, ph.UserBalanceHandler())
 		pointsGroup.POST("/balance/withdraw", ph.WithdrawPointsHandler())
-		pointsGroup.GET("/withdrawals", ph.GetUserWithdrawalHistory())
+		pointsGroup.GET("/withdrawals", conditionalGET, ph.GetUserWithdrawalHistory())
+	}
+}
+
+func RegisterTransferHandlers(r *gin.Engine, th *handlers.TransferHandlers, authUser gin.HandlerFunc, rateLimit gin.HandlerFunc, conditionalGET gin.HandlerFunc) {
+	transferGroup := r.Group("/api/user/transfers")
+	{
+		transferGroup.Use(authUser, rateLimit)
+		transferGroup.POST("", th.CreateTransferHandler())
+		transferGroup.GET("", conditionalGET, th.ListTransfersHandler())
+		transferGroup.POST("/:id/accept", th.AcceptTransferHandler())
+		transferGroup.POST("/:id/decline", th.DeclineTransferHandler())
+		transferGroup.POST("/:id/cancel", th.CancelTransferHandler())
+	}
+}
+
+func RegisterHouseholdHandlers(r *gin.Engine, hh *handlers.HouseholdHandlers, authUser gin.HandlerFunc, rateLimit gin.HandlerFunc) {
+	householdGroup := r.Group("/api/user/household")
+	{
+		householdGroup.Use(authUser, rateLimit)
+		householdGroup.POST("", hh.CreateHouseholdHandler())
+		householdGroup.GET("", hh.GetHouseholdHandler())
+		householdGroup.POST("/accept", hh.AcceptInvitationHandler())
+		householdGroup.POST("/leave", hh.LeaveHouseholdHandler())
+		householdGroup.POST("/members", hh.InviteMemberHandler())
+		householdGroup.DELETE("/members/:id", hh.RemoveMemberHandler())
+		householdGroup.PUT("/members/:id/allowance", hh.SetAllowanceHandler())
+		householdGroup.POST("/withdraw", hh.WithdrawHandler())
+	}
+}
+
+func RegisterAdminHandlers(r *gin.Engine, ah *handlers.AdminHandlers, authUser gin.HandlerFunc, rateLimit gin.HandlerFunc) {
+	adminGroup := r.Group("/api/admin")
+	{
+		adminGroup.Use(authUser, rateLimit, middleware.RequireRole(models.RoleSupport, models.RoleAdmin))
+		adminGroup.GET("/users", ah.FindUserHandler())
+		adminGroup.GET("/users/:id", ah.GetUserHandler())
+		adminGroup.GET("/users/:id/orders", ah.GetUserOrdersHandler())
+		adminGroup.GET("/users/:id/balance", ah.GetUserBalanceHandler())
+		adminGroup.GET("/users/:id/withdrawals", ah.GetUserWithdrawalsHandler())
+		adminGroup.GET("/orders/:number", ah.GetOrderHandler())
+		adminGroup.GET("/fraud/reviews", ah.GetFraudReviewsHandler())
+
+		writeGroup := adminGroup.Group("")
+		writeGroup.Use(middleware.RequireRole(models.RoleAdmin))
+		writeGroup.POST("/users/:id/balance/adjustments", ah.AdjustBalanceHandler())
+		writeGroup.PUT("/users/:id/role", ah.SetUserRoleHandler())
+		writeGroup.POST("/users/:id/disable", ah.DisableUserHandler())
+		writeGroup.POST("/users/:id/enable", ah.EnableUserHandler())
+		writeGroup.POST("/fraud/reviews/:id/resolve", ah.ResolveFraudReviewHandler())
+		writeGroup.POST("/merchants", ah.CreateMerchantHandler())
+		writeGroup.GET("/merchants/:id/keys", ah.GetMerchantAPIKeysHandler())
+		writeGroup.POST("/merchants/:id/keys", ah.CreateAPIKeyHandler())
+		writeGroup.DELETE("/merchants/:id/keys/:keyID", ah.RevokeAPIKeyHandler())
+	}
+}
+
+func RegisterMerchantHandlers(r *gin.Engine, mh *handlers.MerchantHandlers, store middleware.APIKeyStore, authUser gin.HandlerFunc, rateLimit gin.HandlerFunc, logger *zap.Logger) {
+	r.POST("/api/user/redemption-codes", authUser, rateLimit, mh.CreateRedemptionCodeHandler())
+
+	merchantGroup := r.Group("/api/merchant")
+	{
+		merchantGroup.Use(middleware.AuthAPIKey(store, logger))
+		merchantGroup.POST("/orders", middleware.RequireScope(models.ScopeOrdersWrite), mh.PostCustomerOrderHandler())
+		merchantGroup.POST("/redemptions", middleware.RequireScope(models.ScopePointsRedeem), mh.RedeemPointsHandler())
+	}
+}
+
+func RegisterSessionHandlers(r *gin.Engine, sh *handlers.SessionHandlers, authUser gin.HandlerFunc, rateLimit gin.HandlerFunc) {
+	sessionGroup := r.Group("/api/user/sessions")
+	{
+		sessionGroup.Use(authUser, rateLimit)
+		sessionGroup.GET("", sh.GetUserSessionsHandler())
+		sessionGroup.DELETE("/:id", sh.RevokeSessionHandler())
+	}
+}
+
+func RegisterOIDCHandlers(r *gin.Engine, oh *handlers.OIDCHandlers, authUser gin.HandlerFunc, rateLimit gin.HandlerFunc) {
+	oidcGroup := r.Group("/api/user/oidc")
+	{
+		oidcGroup.GET("/login", rateLimit, oh.LoginHandler())
+		oidcGroup.GET("/callback", rateLimit, oh.CallbackHandler())
+		oidcGroup.POST("/link", authUser, rateLimit, oh.LinkHandler())
+	}
+}
+
+func RegisterAccountHandlers(r *gin.Engine, ah *handlers.AccountHandlers, authUser gin.HandlerFunc, rateLimit gin.HandlerFunc) {
+	accountGroup := r.Group("/api/user")
+	{
+		accountGroup.Use(authUser, rateLimit)
+		accountGroup.GET("/export", ah.ExportHandler())
+		accountGroup.DELETE("", ah.DeleteAccountHandler())
+		accountGroup.DELETE("/deletion", ah.CancelDeletionHandler())
+	}
+}
+
+func RegisterStatementHandlers(r *gin.Engine, sh *handlers.StatementHandlers, authUser gin.HandlerFunc, rateLimit gin.HandlerFunc) {
+	statementGroup := r.Group("/api/user/statements")
+	{
+		statementGroup.Use(authUser, rateLimit)
+		statementGroup.GET("", sh.ListStatementsHandler())
+		statementGroup.GET("/:month", sh.GetStatementHandler())
+	}
+}
+
+func RegisterV2Handlers(r *gin.Engine, vh *handlers.V2Handlers, authUser gin.HandlerFunc, rateLimit gin.HandlerFunc, conditionalGET gin.HandlerFunc) {
+	v2Group := r.Group("/api/v2")
+	{
+		v2Group.Use(authUser, rateLimit)
+		v2Group.POST("/orders", vh.CreateOrderHandler())
+		v2Group.GET("/orders", vh.QueuePendingOrdersHandler(), conditionalGET, vh.ListOrdersHandler())
+		v2Group.GET("/orders/:number", conditionalGET, vh.GetOrderHandler())
+		v2Group.GET("/balance", conditionalGET, vh.BalanceHandler())
+		v2Group.POST("/withdrawals", vh.CreateWithdrawalHandler())
+		v2Group.GET("/withdrawals", conditionalGET, vh.ListWithdrawalsHandler())
 	}
 }
@@ -2,66 +2,266 @@ package server
 
 import (
 	"errors"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
 
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
+	loyaltyv1 "github.com/rovany706/loyalty-gopher/api/loyalty/v1"
 	"github.com/rovany706/loyalty-gopher/internal/auth"
 	"github.com/rovany706/loyalty-gopher/internal/config"
 	"github.com/rovany706/loyalty-gopher/internal/database"
+	"github.com/rovany706/loyalty-gopher/internal/events"
+	"github.com/rovany706/loyalty-gopher/internal/graph"
+	"github.com/rovany706/loyalty-gopher/internal/grpcserver"
 	"github.com/rovany706/loyalty-gopher/internal/handlers"
+	"github.com/rovany706/loyalty-gopher/internal/middleware"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/openapi"
+	"github.com/rovany706/loyalty-gopher/internal/problems"
 	"github.com/rovany706/loyalty-gopher/internal/repository"
 	"github.com/rovany706/loyalty-gopher/internal/routes"
 	"github.com/rovany706/loyalty-gopher/internal/services"
+	"github.com/rovany706/loyalty-gopher/internal/validation"
 	"go.uber.org/zap"
 )
 
+const oidcRequestTimeout = 10 * time.Second
+
 type Server struct {
-	config           *config.Config
-	logger           *zap.Logger
-	database         *database.Database
-	userRepository   repository.UserRepository
-	orderRepository  repository.OrderRepository
-	pointsRepository repository.PointsRepository
-	tokenManager     auth.TokenManager
-	accrualService   services.AccrualService
+	config              *config.Config
+	logger              *zap.Logger
+	database            *database.Database
+	userRepository      repository.UserRepository
+	orderRepository     repository.OrderRepository
+	pointsRepository    repository.PointsRepository
+	merchantRepository  repository.MerchantRepository
+	sessionRepository   repository.SessionRepository
+	tokenManager        auth.TokenManager
+	accrualService      services.AccrualService
+	deletionService     services.AccountDeletionService
+	statementService    services.StatementService
+	mfaService          services.MFAService
+	withdrawalRules     services.WithdrawalRulesService
+	fraudService        services.FraudService
+	transferService     services.TransferService
+	householdRepository repository.HouseholdRepository
+	redemptionCodes     repository.RedemptionCodeRepository
+	oidcService         services.OIDCService
+	eventHub            *events.Hub
+	rateLimitStore      middleware.RateLimitStore
+
+	registrationValidator *validation.RegistrationValidator
 }
 
 func NewServer(config *config.Config, logger *zap.Logger, database *database.Database) (*Server, error) {
-	userRepository := repository.NewDBUserRepository(database)
-	orderRepository := repository.NewDBOrderRepository(database, logger)
-	pointsRepository := repository.NewDBPointsRepository(database, logger)
+	passwordHasher, err := auth.NewPasswordHasher(auth.PasswordHasherConfig{
+		Algorithm:         config.PasswordHashAlgorithm,
+		Argon2Memory:      uint32(config.Argon2Memory),
+		Argon2Iterations:  uint32(config.Argon2Iterations),
+		Argon2Parallelism: uint8(config.Argon2Parallelism),
+		BcryptCost:        config.BcryptCost,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	userRepository := repository.NewDBUserRepository(database, passwordHasher, logger)
+	eventHub := events.NewHub()
+	orderRepository := repository.NewDBOrderRepository(database, eventHub, logger)
+	pointsRepository := repository.NewDBPointsRepository(database, eventHub, logger)
+	merchantRepository := repository.NewDBMerchantRepository(database, logger)
+	sessionRepository := repository.NewDBSessionRepository(database, logger)
 	tokenManager, err := auth.NewJWTTokenManager([]byte(config.TokenSecret))
 	accrualService := services.NewAccrualService(config, orderRepository, pointsRepository, logger)
+	mfaService := services.NewMFAService(config.MFAIssuer, repository.NewDBMFARepository(database, logger), userRepository, logger)
 
 	if err != nil {
 		return nil, err
 	}
 
+	// signing in through an identity provider is enabled by configuring its issuer
+	var oidcService services.OIDCService
+	if config.OIDCIssuer != "" {
+		provider := auth.NewOIDCProvider(auth.OIDCConfig{
+			Issuer:       config.OIDCIssuer,
+			ClientID:     config.OIDCClientID,
+			ClientSecret: config.OIDCClientSecret,
+			RedirectURL:  config.OIDCRedirectURL,
+		}, &http.Client{Timeout: oidcRequestTimeout})
+		oidcService = services.NewOIDCService(provider, config.OIDCIssuer, config.OIDCAutoProvision, repository.NewDBIdentityRepository(database, logger), tokenManager, logger)
+	}
+
+	// buckets kept in the database are shared by every instance of a deployment
+	var rateLimitStore middleware.RateLimitStore = middleware.NewMemoryRateLimitStore()
+	if config.RateLimitStore == "postgres" {
+		rateLimitStore = repository.NewDBRateLimitRepository(database, logger)
+	}
+
+	registrationValidator := validation.NewRegistrationValidator(validation.RegistrationPolicy{
+		LoginMinLength:    config.LoginMinLength,
+		LoginMaxLength:    config.LoginMaxLength,
+		LoginPattern:      regexp.MustCompile(config.LoginPattern),
+		PasswordMinLength: config.PasswordMinLength,
+		PasswordMaxLength: config.PasswordMaxLength,
+		// bcrypt either hashes new passwords or verifies and rehashes old ones,
+		// a longer password would fail to hash or be cut short
+		PasswordMaxBytes:      auth.BcryptMaxPasswordBytes,
+		PasswordMinClasses:    config.PasswordMinClasses,
+		RejectCommonPasswords: config.RejectCommonPasswords,
+	})
+
 	return &Server{
-		config:           config,
-		logger:           logger,
-		database:         database,
-		tokenManager:     tokenManager,
-		userRepository:   userRepository,
-		orderRepository:  orderRepository,
-		pointsRepository: pointsRepository,
-		accrualService:   accrualService,
+		config:             config,
+		logger:             logger,
+		database:           database,
+		tokenManager:       tokenManager,
+		userRepository:     userRepository,
+		orderRepository:    orderRepository,
+		pointsRepository:   pointsRepository,
+		merchantRepository: merchantRepository,
+		sessionRepository:  sessionRepository,
+		accrualService:     accrualService,
+		deletionService:    services.NewAccountDeletionService(config.AccountDeletionGracePeriod, userRepository, logger),
+		statementService:   services.NewStatementService(userRepository, repository.NewDBStatementRepository(database, logger), logger),
+		mfaService:         mfaService,
+		withdrawalRules: services.NewWithdrawalRulesService(models.WithdrawalLimits{
+			MinSum:         config.WithdrawalMinSum,
+			MaxSum:         config.WithdrawalMaxSum,
+			DailyMaxSum:    config.WithdrawalDailyMaxSum,
+			HourlyMaxCount: config.WithdrawalHourlyMaxCount,
+			MaxDecimals:    config.WithdrawalMaxDecimals,
+		}, repository.NewDBWithdrawalRuleRepository(database, logger), logger),
+		fraudService: services.NewFraudService(models.FraudThresholds{
+			Window:        config.FraudWindow,
+			VelocityLimit: config.FraudUploadVelocity,
+			FlagScore:     config.FraudFlagScore,
+			ThrottleScore: config.FraudThrottleScore,
+		}, repository.NewDBFraudRepository(database, logger), userRepository, logger),
+		transferService: services.NewTransferService(models.TransferLimits{
+			DailyMaxSum:   config.TransferDailyMaxSum,
+			DailyMaxCount: config.TransferDailyMaxCount,
+		}, repository.NewDBTransferRepository(database, eventHub, logger)),
+		householdRepository: repository.NewDBHouseholdRepository(database, eventHub, logger),
+		redemptionCodes:     repository.NewDBRedemptionCodeRepository(database, logger),
+		oidcService:         oidcService,
+		eventHub:            eventHub,
+		rateLimitStore:      rateLimitStore,
+
+		registrationValidator: registrationValidator,
 	}, nil
 }
 
 func (s *Server) Run() (err error) {
 	s.accrualService.StartWorker()
 	defer s.accrualService.StopWorker()
+	s.deletionService.StartWorker()
+	defer s.deletionService.StopWorker()
+	s.statementService.StartWorker()
+	defer s.statementService.StopWorker()
 	defer func() {
 		err = errors.Join(err, s.database.Close())
 	}()
 
+	// the gRPC API is served on its own port only when an address is configured
+	if s.config.GRPCAddress != "" {
+		listener, err := net.Listen("tcp", s.config.GRPCAddress)
+		if err != nil {
+			return err
+		}
+
+		loyaltyServer := grpcserver.NewLoyaltyServer(s.userRepository, s.sessionRepository, s.orderRepository, s.pointsRepository, s.tokenManager, s.registrationValidator, s.mfaService, s.accrualService, s.config.MFAWithdrawThreshold, s.withdrawalRules, s.fraudService)
+		grpcServer := grpcserver.NewGRPCServer(loyaltyServer, s.sessionRepository, s.rateLimitStore, s.rateLimitPolicy(), s.logger)
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				s.logger.Error("gRPC server stopped", zap.Error(err))
+			}
+		}()
+		defer grpcServer.GracefulStop()
+	}
+
 	r := gin.Default()
 
-	r.Use(gzip.Gzip(gzip.DefaultCompression))
-	routes.RegisterAuthHandlers(r, handlers.NewAuthHandlers(s.userRepository, s.tokenManager))
-	routes.RegisterOrderHandlers(r, handlers.NewOrderHandlers(s.orderRepository, s.accrualService), s.tokenManager)
-	routes.RegisterPointsHandlers(r, handlers.NewPointsHandlers(s.pointsRepository), s.tokenManager)
+	// X-Forwarded-For is only believed from the configured proxies, otherwise clients
+	// would choose the IP that rate limits, fraud scoring and sessions see
+	if err := r.SetTrustedProxies(s.config.TrustedProxyList()); err != nil {
+		return err
+	}
+
+	// compressing streams would buffer messages until the connection closes
+	r.Use(gzip.Gzip(gzip.DefaultCompression, gzip.WithExcludedPaths([]string{routes.OrderEventsPath, routes.BalanceStreamPath})))
+	r.Use(problems.Middleware())
+	if s.config.ValidateRequests {
+		spec, err := openapi.Load()
+		if err != nil {
+			return err
+		}
+
+		r.Use(openapi.ValidateRequests(spec))
+	}
+
+	r.GET("/openapi.json", openapi.Handler())
+	cookies := middleware.CookieSettings{
+		Enabled:  s.config.CookieAuth,
+		Secure:   s.config.CookieSecure,
+		SameSite: middleware.ParseSameSite(s.config.CookieSameSite),
+	}
+	authUser := middleware.AuthUser(s.tokenManager, s.sessionRepository, cookies)
+	conditionalGET := middleware.ConditionalGET(s.userRepository)
+	rateLimit := middleware.RateLimit(s.rateLimitStore, s.rateLimitPolicy(), s.logger)
+
+	authHandlers := handlers.NewAuthHandlers(s.userRepository, s.sessionRepository, s.tokenManager, s.registrationValidator, s.mfaService, cookies)
+
+	routes.RegisterAuthHandlers(r, authHandlers, rateLimit)
+	if s.oidcService != nil {
+		routes.RegisterOIDCHandlers(r, handlers.NewOIDCHandlers(authHandlers, s.oidcService), authUser, rateLimit)
+	}
+	routes.RegisterMFAHandlers(r, handlers.NewMFAHandlers(s.mfaService), authUser, rateLimit)
+	routes.RegisterOrderHandlers(r, handlers.NewOrderHandlers(s.orderRepository, s.accrualService, s.fraudService, s.config.OrderBatchMaxSize), authUser, rateLimit, conditionalGET)
+	routes.RegisterOrderEventHandlers(r, handlers.NewOrderEventHandlers(s.eventHub), authUser, rateLimit)
+	routes.RegisterBalanceStreamHandlers(r, handlers.NewBalanceStreamHandlers(s.pointsRepository, s.eventHub, s.config.BalanceStreamMaxConnections), authUser, rateLimit)
+	routes.RegisterPointsHandlers(r, handlers.NewPointsHandlers(s.pointsRepository, s.mfaService, s.config.MFAWithdrawThreshold, s.withdrawalRules), authUser, rateLimit, conditionalGET)
+	routes.RegisterTransferHandlers(r, handlers.NewTransferHandlers(s.transferService, s.mfaService, s.config.MFAWithdrawThreshold), authUser, rateLimit, conditionalGET)
+	routes.RegisterHouseholdHandlers(r, handlers.NewHouseholdHandlers(s.householdRepository, s.mfaService, s.config.MFAWithdrawThreshold, s.withdrawalRules), authUser, rateLimit)
+	routes.RegisterV2Handlers(r, handlers.NewV2Handlers(s.orderRepository, s.pointsRepository, s.accrualService, s.mfaService, s.config.MFAWithdrawThreshold, s.withdrawalRules, s.fraudService), authUser, rateLimit, conditionalGET)
+	routes.RegisterGraphQLHandlers(r, handlers.NewGraphQLHandlers(graph.NewServer(s.userRepository, s.orderRepository, s.pointsRepository, s.accrualService, s.mfaService, s.config.MFAWithdrawThreshold, s.withdrawalRules, s.fraudService, s.config.GraphQLMaxDepth, s.config.GraphQLMaxComplexity, s.logger)), authUser, rateLimit)
+	routes.RegisterAdminHandlers(r, handlers.NewAdminHandlers(s.userRepository, s.orderRepository, s.pointsRepository, s.merchantRepository, s.fraudService, s.config.APIKeyDefaultRateLimit), authUser, rateLimit)
+	routes.RegisterAccountHandlers(r, handlers.NewAccountHandlers(s.userRepository, s.orderRepository, s.pointsRepository, s.config.AccountDeletionGracePeriod), authUser, rateLimit)
+	routes.RegisterStatementHandlers(r, handlers.NewStatementHandlers(s.statementService), authUser, rateLimit)
+	routes.RegisterSessionHandlers(r, handlers.NewSessionHandlers(s.sessionRepository), authUser, rateLimit)
+	routes.RegisterMerchantHandlers(r, handlers.NewMerchantHandlers(s.userRepository, s.orderRepository, s.pointsRepository, s.redemptionCodes, s.accrualService, s.mfaService, s.config.MFAWithdrawThreshold, s.withdrawalRules, s.fraudService, s.logger), s.merchantRepository, authUser, rateLimit, s.logger)
 
 	return r.Run(s.config.RunAddress)
 }
+
+// rateLimitPolicy gives order listing, which queues accrual lookups, and sign in
+// limits of their own, on the REST and the gRPC API.
+func (s *Server) rateLimitPolicy() middleware.RateLimitPolicy {
+	perMinute := func(requests int) models.RateLimit {
+		return models.RateLimit{Requests: requests, Period: time.Minute}
+	}
+
+	orders := perMinute(s.config.OrdersRateLimit)
+	signIn := perMinute(s.config.AuthRateLimit)
+
+	return middleware.RateLimitPolicy{
+		Default: perMinute(s.config.RateLimit),
+		Routes: map[string]models.RateLimit{
+			"GET /api/user/orders":        orders,
+			"GET /api/v2/orders":          orders,
+			"POST /graphql":               orders,
+			"POST /api/user/register":     signIn,
+			"POST /api/user/login":        signIn,
+			"POST /api/user/login/mfa":    signIn,
+			"GET /api/user/oidc/login":    signIn,
+			"GET /api/user/oidc/callback": signIn,
+
+			loyaltyv1.LoyaltyService_ListOrders_FullMethodName: orders,
+			loyaltyv1.LoyaltyService_Register_FullMethodName:   signIn,
+			loyaltyv1.LoyaltyService_Login_FullMethodName:      signIn,
+			loyaltyv1.LoyaltyService_LoginMFA_FullMethodName:   signIn,
+		},
+	}
+}
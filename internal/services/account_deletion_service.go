@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"go.uber.org/zap"
+)
+
+const accountDeletionInterval = time.Hour
+
+// AccountDeletionService anonymizes accounts once their grace period is over.
+type AccountDeletionService interface {
+	StartWorker()
+	StopWorker()
+}
+
+type AccountDeletionServiceImpl struct {
+	gracePeriod    time.Duration
+	userRepository repository.UserRepository
+	logger         *zap.Logger
+	stopCh         chan struct{}
+	doneCh         chan struct{}
+}
+
+func NewAccountDeletionService(gracePeriod time.Duration, userRepository repository.UserRepository, logger *zap.Logger) AccountDeletionService {
+	return &AccountDeletionServiceImpl{
+		gracePeriod:    gracePeriod,
+		userRepository: userRepository,
+		logger:         logger,
+		stopCh:         make(chan struct{}),
+		doneCh:         make(chan struct{}),
+	}
+}
+
+func (s *AccountDeletionServiceImpl) StartWorker() {
+	go func() {
+		defer close(s.doneCh)
+
+		ticker := time.NewTicker(accountDeletionInterval)
+		defer ticker.Stop()
+
+		for {
+			s.anonymizeExpired()
+
+			select {
+			case <-s.stopCh:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *AccountDeletionServiceImpl) StopWorker() {
+	close(s.stopCh)
+	<-s.doneCh
+}
+
+func (s *AccountDeletionServiceImpl) anonymizeExpired() {
+	ctx, cancel := context.WithTimeout(context.Background(), accountDeletionInterval)
+	defer cancel()
+
+	count, err := s.userRepository.AnonymizeUsers(ctx, time.Now().Add(-s.gracePeriod))
+	if err != nil {
+		s.logger.Error("failed to anonymize deleted accounts", zap.Int("anonymized", count), zap.Error(err))
+		return
+	}
+
+	if count > 0 {
+		s.logger.Info("anonymized deleted accounts", zap.Int("anonymized", count))
+	}
+}
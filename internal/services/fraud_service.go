@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rovany706/loyalty-gopher/internal/helpers"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"go.uber.org/zap"
+)
+
+const fraudPruneInterval = time.Minute
+
+var ErrOrderUploadsThrottled = errors.New("too many suspicious order uploads, try again later")
+
+type FraudService interface {
+	// CheckOrderUpload returns ErrOrderUploadsThrottled while the user or the
+	// client IP is throttled. A failing repository lets uploads through.
+	CheckOrderUpload(ctx context.Context, userID int, clientIP string) error
+	// RecordOrderUploads rescores the user and the client IP, throttling and
+	// flagging them over the thresholds. Failures are only logged.
+	RecordOrderUploads(ctx context.Context, userID int, clientIP string, outcomes ...models.OrderBatchStatus)
+	GetReviews(ctx context.Context, status models.FraudReviewStatus) ([]models.FraudReview, error)
+	// ResolveReview disables the user of a confirmed review, a cleared one
+	// lifts the throttle of the user and resets the score.
+	ResolveReview(ctx context.Context, reviewID int, status models.FraudReviewStatus, adminUserID int) (*models.FraudReview, error)
+}
+
+// OrderUploadOutcome names the result of a single order upload for RecordOrderUploads.
+// Failures other than an invalid or conflicting number are not scored.
+func OrderUploadOutcome(created bool, err error) (models.OrderBatchStatus, bool) {
+	switch {
+	case errors.Is(err, helpers.ErrInvalidOrderNumber):
+		return models.OrderBatchStatusInvalid, true
+	case errors.Is(err, repository.ErrOrderConflict):
+		return models.OrderBatchStatusConflict, true
+	case err != nil:
+		return "", false
+	case created:
+		return models.OrderBatchStatusAccepted, true
+	default:
+		return models.OrderBatchStatusDuplicateOwn, true
+	}
+}
+
+type FraudServiceImpl struct {
+	thresholds      models.FraudThresholds
+	fraudRepository repository.FraudRepository
+	userRepository  repository.UserRepository
+	logger          *zap.Logger
+	lastPrune       time.Time
+	mutex           sync.Mutex
+}
+
+func NewFraudService(thresholds models.FraudThresholds, fraudRepository repository.FraudRepository, userRepository repository.UserRepository, logger *zap.Logger) FraudService {
+	return &FraudServiceImpl{
+		thresholds:      thresholds,
+		fraudRepository: fraudRepository,
+		userRepository:  userRepository,
+		logger:          logger,
+	}
+}
+
+func (s *FraudServiceImpl) CheckOrderUpload(ctx context.Context, userID int, clientIP string) error {
+	subjects := []string{models.FraudUserSubject(userID)}
+	if clientIP != "" {
+		subjects = append(subjects, models.FraudIPSubject(clientIP))
+	}
+
+	until, err := s.fraudRepository.GetThrottledUntil(ctx, subjects, time.Now())
+	if err != nil {
+		s.logger.Warn("failed to check order upload throttle", zap.Int("userID", userID), zap.Error(err))
+		return nil
+	}
+
+	if !until.IsZero() {
+		return ErrOrderUploadsThrottled
+	}
+
+	return nil
+}
+
+func (s *FraudServiceImpl) RecordOrderUploads(ctx context.Context, userID int, clientIP string, outcomes ...models.OrderBatchStatus) {
+	if len(outcomes) == 0 {
+		return
+	}
+
+	now := time.Now()
+	if err := s.fraudRepository.RecordOrderUploads(ctx, userID, clientIP, outcomes, now); err != nil {
+		s.logger.Warn("failed to record order uploads", zap.Int("userID", userID), zap.Error(err))
+		return
+	}
+
+	since := now.Add(-s.thresholds.Window)
+	if err := s.scoreUser(ctx, userID, since, now); err != nil {
+		s.logger.Warn("failed to score user uploads", zap.Int("userID", userID), zap.Error(err))
+	}
+
+	if clientIP != "" {
+		if err := s.scoreIP(ctx, clientIP, since, now); err != nil {
+			s.logger.Warn("failed to score client IP uploads", zap.String("clientIP", clientIP), zap.Error(err))
+		}
+	}
+
+	s.prune(ctx, since, now)
+}
+
+func (s *FraudServiceImpl) scoreUser(ctx context.Context, userID int, since time.Time, now time.Time) error {
+	stats, err := s.fraudRepository.GetUserUploadStats(ctx, userID, since)
+	if err != nil {
+		return err
+	}
+
+	score := stats.RiskScore(s.thresholds.VelocityLimit)
+	if score >= s.thresholds.ThrottleScore {
+		if err := s.fraudRepository.Throttle(ctx, models.FraudUserSubject(userID), now.Add(s.thresholds.Window)); err != nil {
+			return err
+		}
+	}
+
+	if score >= s.thresholds.FlagScore {
+		s.logger.Info("flagged user for fraud review", zap.Int("userID", userID), zap.Int("score", score))
+		return s.fraudRepository.FlagUser(ctx, userID, score, stats, now)
+	}
+
+	return nil
+}
+
+// scoreIP only throttles: reviews are of accounts, and an IP may be shared by many.
+func (s *FraudServiceImpl) scoreIP(ctx context.Context, clientIP string, since time.Time, now time.Time) error {
+	stats, err := s.fraudRepository.GetIPUploadStats(ctx, clientIP, since)
+	if err != nil {
+		return err
+	}
+
+	if stats.RiskScore(s.thresholds.VelocityLimit) < s.thresholds.ThrottleScore {
+		return nil
+	}
+
+	return s.fraudRepository.Throttle(ctx, models.FraudIPSubject(clientIP), now.Add(s.thresholds.Window))
+}
+
+func (s *FraudServiceImpl) prune(ctx context.Context, before time.Time, now time.Time) {
+	s.mutex.Lock()
+	if now.Sub(s.lastPrune) < fraudPruneInterval {
+		s.mutex.Unlock()
+		return
+	}
+	s.lastPrune = now
+	s.mutex.Unlock()
+
+	if err := s.fraudRepository.PruneFraudData(ctx, before, now); err != nil {
+		s.logger.Warn("failed to prune fraud data", zap.Error(err))
+	}
+}
+
+func (s *FraudServiceImpl) GetReviews(ctx context.Context, status models.FraudReviewStatus) ([]models.FraudReview, error) {
+	return s.fraudRepository.GetFraudReviews(ctx, status)
+}
+
+func (s *FraudServiceImpl) ResolveReview(ctx context.Context, reviewID int, status models.FraudReviewStatus, adminUserID int) (*models.FraudReview, error) {
+	review, err := s.fraudRepository.ResolveFraudReview(ctx, reviewID, status, adminUserID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	switch status {
+	case models.FraudReviewStatusConfirmed:
+		err = s.userRepository.SetUserDisabled(ctx, review.UserID, true)
+	case models.FraudReviewStatusCleared:
+		err = s.fraudRepository.ClearUser(ctx, review.UserID)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return review, nil
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type fakeFraudRepository struct {
+	repository.FraudRepository
+	outcomes  map[string][]models.OrderBatchStatus
+	throttles map[string]time.Time
+	flagged   map[int]int
+}
+
+func newFakeFraudRepository() *fakeFraudRepository {
+	return &fakeFraudRepository{
+		outcomes:  make(map[string][]models.OrderBatchStatus),
+		throttles: make(map[string]time.Time),
+		flagged:   make(map[int]int),
+	}
+}
+
+func (r *fakeFraudRepository) RecordOrderUploads(_ context.Context, userID int, clientIP string, outcomes []models.OrderBatchStatus, _ time.Time) error {
+	r.outcomes[models.FraudUserSubject(userID)] = append(r.outcomes[models.FraudUserSubject(userID)], outcomes...)
+	r.outcomes[models.FraudIPSubject(clientIP)] = append(r.outcomes[models.FraudIPSubject(clientIP)], outcomes...)
+	return nil
+}
+
+func (r *fakeFraudRepository) stats(subject string) models.OrderUploadStats {
+	var stats models.OrderUploadStats
+	for _, outcome := range r.outcomes[subject] {
+		stats.Uploads++
+		switch outcome {
+		case models.OrderBatchStatusConflict:
+			stats.Conflicts++
+		case models.OrderBatchStatusInvalid:
+			stats.Invalid++
+		}
+	}
+
+	return stats
+}
+
+func (r *fakeFraudRepository) GetUserUploadStats(_ context.Context, userID int, _ time.Time) (models.OrderUploadStats, error) {
+	return r.stats(models.FraudUserSubject(userID)), nil
+}
+
+func (r *fakeFraudRepository) GetIPUploadStats(_ context.Context, clientIP string, _ time.Time) (models.OrderUploadStats, error) {
+	return r.stats(models.FraudIPSubject(clientIP)), nil
+}
+
+func (r *fakeFraudRepository) Throttle(_ context.Context, subject string, until time.Time) error {
+	r.throttles[subject] = until
+	return nil
+}
+
+func (r *fakeFraudRepository) GetThrottledUntil(_ context.Context, subjects []string, now time.Time) (time.Time, error) {
+	var latest time.Time
+	for _, subject := range subjects {
+		if until := r.throttles[subject]; until.After(now) && until.After(latest) {
+			latest = until
+		}
+	}
+
+	return latest, nil
+}
+
+func (r *fakeFraudRepository) FlagUser(_ context.Context, userID int, score int, _ models.OrderUploadStats, _ time.Time) error {
+	r.flagged[userID] = score
+	return nil
+}
+
+func (r *fakeFraudRepository) PruneFraudData(context.Context, time.Time, time.Time) error {
+	return nil
+}
+
+func TestFraudScoring(t *testing.T) {
+	thresholds := models.FraudThresholds{Window: time.Hour, VelocityLimit: 20, FlagScore: 40, ThrottleScore: 70}
+
+	tests := []struct {
+		name            string
+		outcomes        []models.OrderBatchStatus
+		wantFlagged     bool
+		wantThrottled   bool
+		wantIPThrottled bool
+	}{
+		{
+			"honest uploads",
+			[]models.OrderBatchStatus{"accepted", "accepted", "duplicate_own", "accepted", "accepted"},
+			false, false, false,
+		},
+		{
+			"a few mistakes",
+			[]models.OrderBatchStatus{"invalid", "conflict"},
+			false, false, false,
+		},
+		{
+			"some conflicts",
+			[]models.OrderBatchStatus{"conflict", "conflict", "conflict", "conflict", "invalid", "accepted"},
+			true, false, false,
+		},
+		{
+			"claiming orders of others",
+			append(slices.Repeat([]models.OrderBatchStatus{"conflict"}, 18), "invalid", "invalid"),
+			true, true, true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeFraudRepository()
+			service := NewFraudService(thresholds, repo, nil, zap.NewNop())
+
+			assert.NoError(t, service.CheckOrderUpload(context.Background(), 1, "192.0.2.1"))
+			service.RecordOrderUploads(context.Background(), 1, "192.0.2.1", tt.outcomes...)
+
+			_, flagged := repo.flagged[1]
+			assert.Equal(t, tt.wantFlagged, flagged)
+
+			_, ipThrottled := repo.throttles[models.FraudIPSubject("192.0.2.1")]
+			assert.Equal(t, tt.wantIPThrottled, ipThrottled)
+
+			err := service.CheckOrderUpload(context.Background(), 1, "192.0.2.1")
+			if tt.wantThrottled {
+				assert.ErrorIs(t, err, ErrOrderUploadsThrottled)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
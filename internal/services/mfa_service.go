@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rovany706/loyalty-gopher/internal/auth"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"go.uber.org/zap"
+)
+
+var ErrInvalidMFACode = errors.New("invalid two-factor authentication code")
+
+type MFAService interface {
+	IsEnabled(ctx context.Context, userID int) (bool, error)
+	BeginEnrollment(ctx context.Context, userID int) (*models.TOTPEnrollmentResponse, error)
+	ConfirmEnrollment(ctx context.Context, userID int, code string) ([]string, error)
+	Disable(ctx context.Context, userID int, code string, recoveryCode string) error
+	// Verify accepts either a TOTP code or an unused recovery code.
+	Verify(ctx context.Context, userID int, code string, recoveryCode string) error
+	// VerifyTOTP accepts only a fresh TOTP code.
+	VerifyTOTP(ctx context.Context, userID int, code string) error
+}
+
+type MFAServiceImpl struct {
+	issuer         string
+	mfaRepository  repository.MFARepository
+	userRepository repository.UserRepository
+	logger         *zap.Logger
+}
+
+func NewMFAService(issuer string, mfaRepository repository.MFARepository, userRepository repository.UserRepository, logger *zap.Logger) MFAService {
+	return &MFAServiceImpl{
+		issuer:         issuer,
+		mfaRepository:  mfaRepository,
+		userRepository: userRepository,
+		logger:         logger,
+	}
+}
+
+func (s *MFAServiceImpl) IsEnabled(ctx context.Context, userID int) (bool, error) {
+	settings, err := s.mfaRepository.GetTOTP(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return settings.IsEnabled(), nil
+}
+
+func (s *MFAServiceImpl) BeginEnrollment(ctx context.Context, userID int) (*models.TOTPEnrollmentResponse, error) {
+	user, err := s.userRepository.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepository.SaveTOTPSecret(ctx, userID, secret); err != nil {
+		return nil, err
+	}
+
+	return &models.TOTPEnrollmentResponse{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(s.issuer, user.Login, secret),
+	}, nil
+}
+
+func (s *MFAServiceImpl) ConfirmEnrollment(ctx context.Context, userID int, code string) ([]string, error) {
+	settings, err := s.mfaRepository.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if settings == nil {
+		return nil, repository.ErrMFANotEnrolled
+	}
+
+	if settings.IsEnabled() {
+		return nil, repository.ErrMFAAlreadyEnabled
+	}
+
+	step, ok := auth.ValidateTOTP(settings.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	recoveryCodes, err := auth.GenerateRecoveryCodes(auth.RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, 0, len(recoveryCodes))
+	for _, recoveryCode := range recoveryCodes {
+		hashes = append(hashes, auth.HashRecoveryCode(recoveryCode))
+	}
+
+	if err := s.mfaRepository.ConfirmTOTP(ctx, userID, step, hashes); err != nil {
+		return nil, err
+	}
+
+	return recoveryCodes, nil
+}
+
+func (s *MFAServiceImpl) Disable(ctx context.Context, userID int, code string, recoveryCode string) error {
+	if err := s.Verify(ctx, userID, code, recoveryCode); err != nil {
+		return err
+	}
+
+	return s.mfaRepository.DisableTOTP(ctx, userID)
+}
+
+func (s *MFAServiceImpl) Verify(ctx context.Context, userID int, code string, recoveryCode string) error {
+	if recoveryCode == "" {
+		return s.VerifyTOTP(ctx, userID, code)
+	}
+
+	settings, err := s.mfaRepository.GetTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !settings.IsEnabled() {
+		return repository.ErrMFANotEnrolled
+	}
+
+	ok, err := s.mfaRepository.UseRecoveryCode(ctx, userID, auth.HashRecoveryCode(recoveryCode))
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+func (s *MFAServiceImpl) VerifyTOTP(ctx context.Context, userID int, code string) error {
+	settings, err := s.mfaRepository.GetTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !settings.IsEnabled() {
+		return repository.ErrMFANotEnrolled
+	}
+
+	step, ok := auth.ValidateTOTP(settings.Secret, code, time.Now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	ok, err = s.mfaRepository.UseTOTPStep(ctx, userID, step)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		s.logger.Info("rejected replayed totp code", zap.Int("user_id", userID))
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
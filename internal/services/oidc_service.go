@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+
+	"github.com/rovany706/loyalty-gopher/internal/auth"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/validation"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidOIDCState  = errors.New("unknown or expired OIDC login state")
+	ErrOIDCUserNotLinked = errors.New("external identity is not linked to a user")
+	ErrOIDCNoLogin       = errors.New("identity provider returned no usable login")
+)
+
+// OIDCResult tells the caller whether the flow signed a user in or
+// linked the identity to an already authenticated user.
+type OIDCResult struct {
+	UserID int
+	Linked bool
+}
+
+type OIDCService interface {
+	// BeginLogin returns the provider URL to send the browser to and the signed
+	// login token the same browser has to present on the callback. A non-zero
+	// linkUserID links the external identity to that user instead of signing in.
+	BeginLogin(ctx context.Context, linkUserID int) (authURL string, loginToken string, err error)
+	CompleteLogin(ctx context.Context, loginToken string, state string, code string) (*OIDCResult, error)
+}
+
+type OIDCServiceImpl struct {
+	provider           *auth.OIDCProvider
+	issuer             string
+	autoProvision      bool
+	identityRepository repository.IdentityRepository
+	tokenManager       auth.TokenManager
+	logger             *zap.Logger
+}
+
+func NewOIDCService(provider *auth.OIDCProvider, issuer string, autoProvision bool, identityRepository repository.IdentityRepository, tokenManager auth.TokenManager, logger *zap.Logger) OIDCService {
+	return &OIDCServiceImpl{
+		provider:           provider,
+		issuer:             issuer,
+		autoProvision:      autoProvision,
+		identityRepository: identityRepository,
+		tokenManager:       tokenManager,
+		logger:             logger,
+	}
+}
+
+func (s *OIDCServiceImpl) BeginLogin(ctx context.Context, linkUserID int) (string, string, error) {
+	state, err := auth.GeneratePKCEVerifier()
+	if err != nil {
+		return "", "", err
+	}
+
+	nonce, err := auth.GeneratePKCEVerifier()
+	if err != nil {
+		return "", "", err
+	}
+
+	codeVerifier, err := auth.GeneratePKCEVerifier()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := s.provider.AuthCodeURL(ctx, state, nonce, codeVerifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	loginToken, err := s.tokenManager.CreateOIDCLoginToken(auth.OIDCLoginClaims{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		LinkUserID:   linkUserID,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return authURL, loginToken, nil
+}
+
+func (s *OIDCServiceImpl) CompleteLogin(ctx context.Context, loginToken string, state string, code string) (*OIDCResult, error) {
+	login, err := s.tokenManager.GetClaimsFromOIDCLoginToken(loginToken)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidOIDCState, err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(login.State), []byte(state)) != 1 {
+		return nil, ErrInvalidOIDCState
+	}
+
+	claims, err := s.provider.Exchange(ctx, code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	if login.LinkUserID != 0 {
+		if err := s.identityRepository.LinkIdentity(ctx, login.LinkUserID, s.issuer, claims.Subject); err != nil {
+			return nil, err
+		}
+
+		return &OIDCResult{UserID: login.LinkUserID, Linked: true}, nil
+	}
+
+	userID, err := s.identityRepository.FindUserByIdentity(ctx, s.issuer, claims.Subject)
+	if err == nil {
+		return &OIDCResult{UserID: userID}, nil
+	}
+
+	if !errors.Is(err, repository.ErrIdentityNotFound) {
+		return nil, err
+	}
+
+	if !s.autoProvision {
+		return nil, ErrOIDCUserNotLinked
+	}
+
+	userLogin := validation.NormalizeLogin(claims.PreferredUsername)
+	if userLogin == "" {
+		userLogin = validation.NormalizeLogin(claims.Email)
+	}
+
+	if userLogin == "" {
+		return nil, ErrOIDCNoLogin
+	}
+
+	userID, err = s.identityRepository.RegisterExternalUser(ctx, userLogin, s.issuer, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OIDCResult{UserID: userID}, nil
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/rovany706/loyalty-gopher/internal/statements"
+	"go.uber.org/zap"
+)
+
+const statementInterval = time.Hour
+
+var ErrStatementPeriodOpen = errors.New("statement period has not ended")
+
+// StatementService renders monthly account statements and keeps them once the month is over.
+type StatementService interface {
+	// GetStatement returns the statement of a past month, generating it if the
+	// month-end job has not got to the user yet.
+	GetStatement(ctx context.Context, userID int, periodStart time.Time) (models.StatementDocument, error)
+	ListStatementPeriods(ctx context.Context, userID int) ([]time.Time, error)
+	// StartWorker pre-generates the statements of the month that ended last for every user.
+	StartWorker()
+	StopWorker()
+}
+
+type StatementServiceImpl struct {
+	userRepository      repository.UserRepository
+	statementRepository repository.StatementRepository
+	logger              *zap.Logger
+	now                 func() time.Time
+	stopCh              chan struct{}
+	doneCh              chan struct{}
+}
+
+func NewStatementService(userRepository repository.UserRepository, statementRepository repository.StatementRepository, logger *zap.Logger) StatementService {
+	return &StatementServiceImpl{
+		userRepository:      userRepository,
+		statementRepository: statementRepository,
+		logger:              logger,
+		now:                 time.Now,
+		stopCh:              make(chan struct{}),
+		doneCh:              make(chan struct{}),
+	}
+}
+
+func (s *StatementServiceImpl) GetStatement(ctx context.Context, userID int, periodStart time.Time) (models.StatementDocument, error) {
+	periodStart = statements.MonthStart(periodStart)
+	if !periodStart.Before(statements.MonthStart(s.now())) {
+		return models.StatementDocument{}, ErrStatementPeriodOpen
+	}
+
+	document, err := s.statementRepository.GetStatement(ctx, userID, periodStart)
+	if err != nil || document != nil {
+		return derefDocument(document), err
+	}
+
+	generated, err := s.generate(ctx, userID, periodStart)
+	if err != nil {
+		return models.StatementDocument{}, err
+	}
+
+	if err := s.statementRepository.SaveStatement(ctx, userID, periodStart, generated); err != nil {
+		return models.StatementDocument{}, err
+	}
+
+	return generated, nil
+}
+
+func (s *StatementServiceImpl) ListStatementPeriods(ctx context.Context, userID int) ([]time.Time, error) {
+	return s.statementRepository.ListStatementPeriods(ctx, userID)
+}
+
+func (s *StatementServiceImpl) StartWorker() {
+	go func() {
+		defer close(s.doneCh)
+
+		ticker := time.NewTicker(statementInterval)
+		defer ticker.Stop()
+
+		for {
+			s.generateMonthEnd()
+
+			select {
+			case <-s.stopCh:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *StatementServiceImpl) StopWorker() {
+	close(s.stopCh)
+	<-s.doneCh
+}
+
+func (s *StatementServiceImpl) generateMonthEnd() {
+	ctx, cancel := context.WithTimeout(context.Background(), statementInterval)
+	defer cancel()
+
+	periodStart := statements.MonthStart(s.now()).AddDate(0, -1, 0)
+	userIDs, err := s.statementRepository.GetUserIDsWithoutStatement(ctx, periodStart)
+	if err != nil {
+		s.logger.Error("failed to find users without statements", zap.Error(err))
+		return
+	}
+
+	generated := 0
+	for _, userID := range userIDs {
+		select {
+		case <-s.stopCh:
+			return
+		default:
+		}
+
+		document, err := s.generate(ctx, userID, periodStart)
+		if err == nil {
+			err = s.statementRepository.SaveStatement(ctx, userID, periodStart, document)
+		}
+
+		if err != nil {
+			s.logger.Error("failed to generate statement", zap.Int("user_id", userID), zap.Time("period_start", periodStart), zap.Error(err))
+			continue
+		}
+
+		generated++
+	}
+
+	if generated > 0 {
+		s.logger.Info("generated statements", zap.Time("period_start", periodStart), zap.Int("generated", generated))
+	}
+}
+
+func (s *StatementServiceImpl) generate(ctx context.Context, userID int, periodStart time.Time) (models.StatementDocument, error) {
+	user, err := s.userRepository.GetUser(ctx, userID)
+	if err != nil {
+		return models.StatementDocument{}, err
+	}
+
+	ledger, err := s.statementRepository.GetLedger(ctx, userID, periodStart, periodStart.AddDate(0, 1, 0))
+	if err != nil {
+		return models.StatementDocument{}, err
+	}
+
+	return statements.Render(statements.Build(user, periodStart, ledger, s.now()))
+}
+
+func derefDocument(document *models.StatementDocument) models.StatementDocument {
+	if document == nil {
+		return models.StatementDocument{}
+	}
+
+	return *document
+}
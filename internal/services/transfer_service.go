@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+)
+
+// transferMaxDecimals is the precision point accounts are stored with.
+const transferMaxDecimals = 2
+
+var ErrInvalidTransferSum = errors.New("transfer sum must be positive with at most two decimal places")
+
+type TransferService interface {
+	Transfer(ctx context.Context, senderID int, request models.CreateTransferRequest) (*models.Transfer, error)
+	Accept(ctx context.Context, userID int, transferID int) (*models.Transfer, error)
+	Decline(ctx context.Context, userID int, transferID int) (*models.Transfer, error)
+	Cancel(ctx context.Context, userID int, transferID int) (*models.Transfer, error)
+	GetTransfers(ctx context.Context, userID int) ([]models.Transfer, error)
+}
+
+type TransferServiceImpl struct {
+	limits             models.TransferLimits
+	transferRepository repository.TransferRepository
+}
+
+func NewTransferService(limits models.TransferLimits, transferRepository repository.TransferRepository) TransferService {
+	return &TransferServiceImpl{
+		limits:             limits,
+		transferRepository: transferRepository,
+	}
+}
+
+func (s *TransferServiceImpl) Transfer(ctx context.Context, senderID int, request models.CreateTransferRequest) (*models.Transfer, error) {
+	if !request.Sum.IsPositive() || decimalPlaces(request.Sum) > transferMaxDecimals {
+		return nil, ErrInvalidTransferSum
+	}
+
+	if request.Recipient == "" {
+		return nil, repository.ErrRecipientNotFound
+	}
+
+	return s.transferRepository.CreateTransfer(ctx, senderID, request.Recipient, request.Sum, request.RequireAcceptance, s.limits)
+}
+
+func (s *TransferServiceImpl) Accept(ctx context.Context, userID int, transferID int) (*models.Transfer, error) {
+	return s.transferRepository.AcceptTransfer(ctx, userID, transferID)
+}
+
+func (s *TransferServiceImpl) Decline(ctx context.Context, userID int, transferID int) (*models.Transfer, error) {
+	return s.transferRepository.DeclineTransfer(ctx, userID, transferID)
+}
+
+func (s *TransferServiceImpl) Cancel(ctx context.Context, userID int, transferID int) (*models.Transfer, error) {
+	return s.transferRepository.CancelTransfer(ctx, userID, transferID)
+}
+
+func (s *TransferServiceImpl) GetTransfers(ctx context.Context, userID int) ([]models.Transfer, error) {
+	return s.transferRepository.GetUserTransfers(ctx, userID)
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeTransferRepository struct {
+	repository.TransferRepository
+	created []models.CreateTransferRequest
+	limits  models.TransferLimits
+}
+
+func (r *fakeTransferRepository) CreateTransfer(_ context.Context, _ int, recipientLogin string, amount decimal.Decimal, requireAcceptance bool, limits models.TransferLimits) (*models.Transfer, error) {
+	r.created = append(r.created, models.CreateTransferRequest{Recipient: recipientLogin, Sum: amount, RequireAcceptance: requireAcceptance})
+	r.limits = limits
+
+	return &models.Transfer{Direction: models.TransferOutgoing, Counterparty: recipientLogin, Sum: amount, Status: models.TransferStatusCompleted}, nil
+}
+
+func TestTransfer(t *testing.T) {
+	limits := models.TransferLimits{DailyMaxSum: decimal.NewFromInt(1000), DailyMaxCount: 10}
+
+	tests := []struct {
+		name    string
+		request models.CreateTransferRequest
+		wantErr error
+	}{
+		{"valid transfer", models.CreateTransferRequest{Recipient: "relative", Sum: decimal.RequireFromString("25.50")}, nil},
+		{"trailing zeros are not precision", models.CreateTransferRequest{Recipient: "relative", Sum: decimal.RequireFromString("25.500")}, nil},
+		{"zero sum", models.CreateTransferRequest{Recipient: "relative", Sum: decimal.Zero}, ErrInvalidTransferSum},
+		{"negative sum", models.CreateTransferRequest{Recipient: "relative", Sum: decimal.NewFromInt(-5)}, ErrInvalidTransferSum},
+		{"too precise", models.CreateTransferRequest{Recipient: "relative", Sum: decimal.RequireFromString("0.001")}, ErrInvalidTransferSum},
+		{"no recipient", models.CreateTransferRequest{Sum: decimal.NewFromInt(5)}, repository.ErrRecipientNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeTransferRepository{}
+			service := NewTransferService(limits, repo)
+
+			transfer, err := service.Transfer(context.Background(), 1, tt.request)
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				assert.Empty(t, repo.created)
+				return
+			}
+
+			assert.Equal(t, []models.CreateTransferRequest{tt.request}, repo.created)
+			assert.Equal(t, limits, repo.limits)
+			assert.Equal(t, tt.request.Recipient, transfer.Counterparty)
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+const (
+	rulePositiveSum = "positive_sum"
+	ruleMinSum      = "min_sum"
+	ruleMaxSum      = "max_sum"
+	ruleMaxDecimals = "max_decimals"
+	ruleDailyMaxSum = "daily_max_sum"
+	ruleHourlyCount = "hourly_max_count"
+)
+
+// ErrWithdrawalRefused is wrapped by the errors of every withdrawal rule.
+var ErrWithdrawalRefused = errors.New("withdrawal refused")
+
+var (
+	ErrWithdrawalNotPositive  = fmt.Errorf("%w: sum must be positive", ErrWithdrawalRefused)
+	ErrWithdrawalBelowMinimum = fmt.Errorf("%w: sum is below the minimum", ErrWithdrawalRefused)
+	ErrWithdrawalAboveMaximum = fmt.Errorf("%w: sum is above the maximum", ErrWithdrawalRefused)
+	ErrWithdrawalTooPrecise   = fmt.Errorf("%w: sum has too many decimal places", ErrWithdrawalRefused)
+	ErrDailyWithdrawalLimit   = fmt.Errorf("%w: daily withdrawal limit exceeded", ErrWithdrawalRefused)
+	ErrHourlyWithdrawalLimit  = fmt.Errorf("%w: hourly withdrawal limit exceeded", ErrWithdrawalRefused)
+)
+
+type WithdrawalRulesService interface {
+	// Withdraw calls withdraw with a guard that evaluates every enabled rule inside the
+	// withdrawal transaction, records the evaluations and returns the error of the first
+	// violated rule or the error of withdraw.
+	Withdraw(ctx context.Context, userID int, orderNum string, sum decimal.Decimal, withdraw func(guard repository.WithdrawalGuard) error) error
+}
+
+type WithdrawalRulesServiceImpl struct {
+	limits                   models.WithdrawalLimits
+	withdrawalRuleRepository repository.WithdrawalRuleRepository
+	logger                   *zap.Logger
+}
+
+func NewWithdrawalRulesService(limits models.WithdrawalLimits, withdrawalRuleRepository repository.WithdrawalRuleRepository, logger *zap.Logger) WithdrawalRulesService {
+	return &WithdrawalRulesServiceImpl{
+		limits:                   limits,
+		withdrawalRuleRepository: withdrawalRuleRepository,
+		logger:                   logger,
+	}
+}
+
+type ruleResult struct {
+	rule   string
+	passed bool
+	limit  string
+	actual string
+	err    error
+}
+
+func (s *WithdrawalRulesServiceImpl) Withdraw(ctx context.Context, userID int, orderNum string, sum decimal.Decimal, withdraw func(guard repository.WithdrawalGuard) error) error {
+	now := time.Now()
+
+	var results []ruleResult
+	err := withdraw(func(totals repository.WithdrawalTotalsFunc) error {
+		var err error
+		results, err = s.evaluate(sum, now, totals)
+		if err != nil {
+			return err
+		}
+
+		for _, result := range results {
+			if !result.passed {
+				s.logger.Info("withdrawal refused by rule", zap.Int("userID", userID), zap.Error(result.err))
+				return result.err
+			}
+		}
+
+		return nil
+	})
+
+	if len(results) > 0 {
+		evaluations := make([]models.WithdrawalRuleEvaluation, 0, len(results))
+		for _, result := range results {
+			evaluations = append(evaluations, models.WithdrawalRuleEvaluation{
+				UserID:      userID,
+				OrderNum:    orderNum,
+				Sum:         sum,
+				Rule:        result.rule,
+				Passed:      result.passed,
+				Limit:       result.limit,
+				Actual:      result.actual,
+				EvaluatedAt: now,
+			})
+		}
+
+		// the withdrawal is settled by now, a lost audit record must not fail it
+		if rerr := s.withdrawalRuleRepository.RecordRuleEvaluations(ctx, evaluations); rerr != nil {
+			s.logger.Error("failed to record withdrawal rule evaluations", zap.Int("userID", userID), zap.Error(rerr))
+		}
+	}
+
+	return err
+}
+
+func (s *WithdrawalRulesServiceImpl) evaluate(sum decimal.Decimal, now time.Time, withdrawalTotals repository.WithdrawalTotalsFunc) ([]ruleResult, error) {
+	limits := s.limits
+	var results []ruleResult
+
+	// always on, the other rules and the repositories expect a positive sum
+	results = append(results, ruleResult{
+		rule:   rulePositiveSum,
+		passed: sum.IsPositive(),
+		limit:  "0",
+		actual: sum.String(),
+		err:    ErrWithdrawalNotPositive,
+	})
+
+	if limits.MinSum.IsPositive() {
+		results = append(results, ruleResult{
+			rule:   ruleMinSum,
+			passed: !sum.LessThan(limits.MinSum),
+			limit:  limits.MinSum.String(),
+			actual: sum.String(),
+			err:    ErrWithdrawalBelowMinimum,
+		})
+	}
+
+	if limits.MaxSum.IsPositive() {
+		results = append(results, ruleResult{
+			rule:   ruleMaxSum,
+			passed: !sum.GreaterThan(limits.MaxSum),
+			limit:  limits.MaxSum.String(),
+			actual: sum.String(),
+			err:    ErrWithdrawalAboveMaximum,
+		})
+	}
+
+	places := decimalPlaces(sum)
+	results = append(results, ruleResult{
+		rule:   ruleMaxDecimals,
+		passed: places <= limits.MaxDecimals,
+		limit:  strconv.Itoa(limits.MaxDecimals),
+		actual: strconv.Itoa(places),
+		err:    ErrWithdrawalTooPrecise,
+	})
+
+	if limits.DailyMaxSum.IsPositive() {
+		totals, err := withdrawalTotals(now.Add(-24 * time.Hour))
+		if err != nil {
+			return nil, err
+		}
+
+		daySum := totals.Sum.Add(sum)
+		results = append(results, ruleResult{
+			rule:   ruleDailyMaxSum,
+			passed: !daySum.GreaterThan(limits.DailyMaxSum),
+			limit:  limits.DailyMaxSum.String(),
+			actual: daySum.String(),
+			err:    ErrDailyWithdrawalLimit,
+		})
+	}
+
+	if limits.HourlyMaxCount > 0 {
+		totals, err := withdrawalTotals(now.Add(-time.Hour))
+		if err != nil {
+			return nil, err
+		}
+
+		hourCount := totals.Count + 1
+		results = append(results, ruleResult{
+			rule:   ruleHourlyCount,
+			passed: hourCount <= limits.HourlyMaxCount,
+			limit:  strconv.Itoa(limits.HourlyMaxCount),
+			actual: strconv.Itoa(hourCount),
+			err:    ErrHourlyWithdrawalLimit,
+		})
+	}
+
+	return results, nil
+}
+
+// decimalPlaces counts the significant decimal places, so 1.50 has one.
+func decimalPlaces(d decimal.Decimal) int {
+	places := 0
+	for !d.Equal(d.Truncate(int32(places))) {
+		places++
+	}
+
+	return places
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/rovany706/loyalty-gopher/internal/repository"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type fakeWithdrawalRuleRepository struct {
+	repository.WithdrawalRuleRepository
+	evaluations []models.WithdrawalRuleEvaluation
+}
+
+func (r *fakeWithdrawalRuleRepository) RecordRuleEvaluations(_ context.Context, evaluations []models.WithdrawalRuleEvaluation) error {
+	r.evaluations = append(r.evaluations, evaluations...)
+	return nil
+}
+
+func TestWithdrawalRulesWithdraw(t *testing.T) {
+	limits := models.WithdrawalLimits{
+		MinSum:         decimal.NewFromInt(10),
+		MaxSum:         decimal.NewFromInt(500),
+		DailyMaxSum:    decimal.NewFromInt(1000),
+		HourlyMaxCount: 3,
+		MaxDecimals:    2,
+	}
+
+	tests := []struct {
+		name    string
+		sum     string
+		day     models.WithdrawalTotals
+		hour    models.WithdrawalTotals
+		wantErr error
+	}{
+		{"within limits", "100.50", models.WithdrawalTotals{}, models.WithdrawalTotals{}, nil},
+		{"below minimum", "9.99", models.WithdrawalTotals{}, models.WithdrawalTotals{}, ErrWithdrawalBelowMinimum},
+		{"above maximum", "500.01", models.WithdrawalTotals{}, models.WithdrawalTotals{}, ErrWithdrawalAboveMaximum},
+		{"too precise", "10.001", models.WithdrawalTotals{}, models.WithdrawalTotals{}, ErrWithdrawalTooPrecise},
+		{"trailing zeros are not precision", "10.000", models.WithdrawalTotals{}, models.WithdrawalTotals{}, nil},
+		{"daily sum exceeded", "300", models.WithdrawalTotals{Count: 2, Sum: decimal.NewFromInt(800)}, models.WithdrawalTotals{}, ErrDailyWithdrawalLimit},
+		{"daily sum reached exactly", "200", models.WithdrawalTotals{Count: 2, Sum: decimal.NewFromInt(800)}, models.WithdrawalTotals{}, nil},
+		{"hourly count exceeded", "50", models.WithdrawalTotals{Count: 3, Sum: decimal.NewFromInt(150)}, models.WithdrawalTotals{Count: 3, Sum: decimal.NewFromInt(150)}, ErrHourlyWithdrawalLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeWithdrawalRuleRepository{}
+			service := NewWithdrawalRulesService(limits, repo, zap.NewNop())
+
+			withdrawn := false
+			err := service.Withdraw(context.Background(), 1, "2377225624", decimal.RequireFromString(tt.sum), func(guard repository.WithdrawalGuard) error {
+				err := guard(func(since time.Time) (models.WithdrawalTotals, error) {
+					if time.Since(since) > time.Hour {
+						return tt.day, nil
+					}
+
+					return tt.hour, nil
+				})
+				if err != nil {
+					return err
+				}
+
+				withdrawn = true
+				return nil
+			})
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantErr == nil, withdrawn)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, ErrWithdrawalRefused)
+			}
+
+			// every enabled rule is recorded, passed or not
+			assert.Len(t, repo.evaluations, 6)
+		})
+	}
+}
+
+func TestWithdrawalRulesRejectNonPositiveSum(t *testing.T) {
+	for _, sum := range []string{"0", "-500"} {
+		t.Run(sum, func(t *testing.T) {
+			repo := &fakeWithdrawalRuleRepository{}
+			// the default limits enable no sum rule
+			service := NewWithdrawalRulesService(models.WithdrawalLimits{MaxDecimals: 2}, repo, zap.NewNop())
+
+			withdrawn := false
+			err := service.Withdraw(context.Background(), 1, "2377225624", decimal.RequireFromString(sum), func(guard repository.WithdrawalGuard) error {
+				if err := guard(func(time.Time) (models.WithdrawalTotals, error) {
+					return models.WithdrawalTotals{}, nil
+				}); err != nil {
+					return err
+				}
+
+				withdrawn = true
+				return nil
+			})
+			assert.ErrorIs(t, err, ErrWithdrawalNotPositive)
+			assert.False(t, withdrawn)
+
+			if assert.NotEmpty(t, repo.evaluations) {
+				assert.Equal(t, rulePositiveSum, repo.evaluations[0].Rule)
+				assert.False(t, repo.evaluations[0].Passed)
+			}
+		})
+	}
+}
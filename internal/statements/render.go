@@ -0,0 +1,151 @@
+package statements
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/shopspring/decimal"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+const (
+	ContentTypeHTML = "text/html"
+	ContentTypePDF  = "application/pdf"
+)
+
+const (
+	amountPlaces = 2
+	dateLayout   = "2006-01-02"
+	periodLayout = "January 2006"
+	// pdfFont covers the characters logins may use, unlike the core PDF fonts
+	pdfFont = "Go"
+)
+
+//go:embed statement.html.tmpl
+var htmlTemplateText string
+
+var htmlTemplate = template.Must(template.New("statement").Funcs(template.FuncMap{
+	"amount":  formatAmount,
+	"date":    func(t time.Time) string { return t.Format(dateLayout) },
+	"period":  func(t time.Time) string { return t.Format(periodLayout) },
+	"lastDay": lastDay,
+	"sub":     func(a, b decimal.Decimal) decimal.Decimal { return a.Sub(b) },
+}).Parse(htmlTemplateText))
+
+// Render returns the statement as HTML and PDF.
+func Render(statement models.Statement) (models.StatementDocument, error) {
+	var html, pdf bytes.Buffer
+	if err := RenderHTML(&html, statement); err != nil {
+		return models.StatementDocument{}, err
+	}
+
+	if err := RenderPDF(&pdf, statement); err != nil {
+		return models.StatementDocument{}, err
+	}
+
+	return models.StatementDocument{HTML: html.Bytes(), PDF: pdf.Bytes()}, nil
+}
+
+func RenderHTML(w io.Writer, statement models.Statement) error {
+	return htmlTemplate.Execute(w, statement)
+}
+
+// RenderPDF lays out the same sections as the HTML template on A4 pages.
+func RenderPDF(w io.Writer, statement models.Statement) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(pdfFont, "", goregular.TTF)
+	pdf.AddUTF8FontFromBytes(pdfFont, "B", gobold.TTF)
+	pdf.SetTitle("Statement "+statement.PeriodStart.Format(periodLayout), true)
+	pdf.AddPage()
+
+	pdf.SetFont(pdfFont, "B", 16)
+	pdf.CellFormat(0, 10, "Gophermart loyalty statement", "", 1, "L", false, 0, "")
+	pdf.SetFont(pdfFont, "", 10)
+	pdf.CellFormat(0, 6, fmt.Sprintf("%s, card %s", statement.Login, statement.CardID), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 6, fmt.Sprintf("%s to %s", statement.PeriodStart.Format(dateLayout), lastDay(statement.PeriodEnd).Format(dateLayout)), "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	summary := [][2]string{
+		{"Opening balance", formatAmount(statement.OpeningBalance)},
+		{"Accrued", formatAmount(statement.TotalAccrued)},
+		{"Withdrawn", formatAmount(statement.TotalWithdrawn)},
+	}
+	if !statement.TotalAdjusted.IsZero() {
+		summary = append(summary, [2]string{"Adjustments", formatAmount(statement.TotalAdjusted)})
+	}
+	if !statement.TotalTransferred.IsZero() {
+		summary = append(summary, [2]string{"Transfers", formatAmount(statement.TotalTransferred)})
+	}
+	closing := len(summary)
+	summary = append(summary, [2]string{"Closing balance", formatAmount(statement.ClosingBalance)})
+	if !statement.TotalHouseholdWithdrawn.IsZero() {
+		summary = append(summary, [2]string{"Paid from household", formatAmount(statement.TotalHouseholdWithdrawn)})
+	}
+
+	for i, line := range summary {
+		style := ""
+		if i == closing {
+			style = "B"
+		}
+		pdf.SetFont(pdfFont, style, 10)
+		pdf.CellFormat(60, 6, line[0], "", 0, "L", false, 0, "")
+		pdf.CellFormat(40, 6, line[1], "", 1, "R", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	pdf.SetFont(pdfFont, "B", 12)
+	pdf.CellFormat(0, 8, "Activity", "", 1, "L", false, 0, "")
+	pdf.SetFont(pdfFont, "", 10)
+	if len(statement.Entries) == 0 {
+		pdf.CellFormat(0, 6, "No activity in this period.", "", 1, "L", false, 0, "")
+	} else {
+		widths := []float64{28, 28, 64, 30, 30}
+		header := []string{"Date", "Type", "Reference", "Amount", "Balance"}
+		aligns := []string{"L", "L", "L", "R", "R"}
+
+		pdf.SetFont(pdfFont, "B", 10)
+		for i, title := range header {
+			pdf.CellFormat(widths[i], 7, title, "B", 0, aligns[i], false, 0, "")
+		}
+		pdf.Ln(-1)
+
+		pdf.SetFont(pdfFont, "", 10)
+		for _, entry := range statement.Entries {
+			cells := []string{entry.Date.Format(dateLayout), string(entry.Kind), entry.Reference, formatAmount(entry.Amount), formatAmount(entry.Balance)}
+			for i, cell := range cells {
+				pdf.CellFormat(widths[i], 6, cell, "", 0, aligns[i], false, 0, "")
+			}
+			pdf.Ln(-1)
+		}
+	}
+	pdf.Ln(4)
+
+	pdf.SetFont(pdfFont, "B", 12)
+	pdf.CellFormat(0, 8, "Tier", "", 1, "L", false, 0, "")
+	pdf.SetFont(pdfFont, "", 10)
+	tier := fmt.Sprintf("%s, %s points accrued in total.", statement.Tier.Name, formatAmount(statement.LifetimeAccrued))
+	if statement.NextTier != nil {
+		tier += fmt.Sprintf(" %s more to reach %s.", formatAmount(statement.NextTier.MinAccrued.Sub(statement.LifetimeAccrued)), statement.NextTier.Name)
+	}
+	pdf.MultiCell(0, 6, tier, "", "L", false)
+	pdf.Ln(4)
+	pdf.CellFormat(0, 6, "Generated "+statement.GeneratedAt.Format("2006-01-02 15:04 MST"), "", 1, "L", false, 0, "")
+
+	return pdf.Output(w)
+}
+
+func formatAmount(amount decimal.Decimal) string {
+	return amount.StringFixed(amountPlaces)
+}
+
+// lastDay returns the last day covered by a period ending before end.
+func lastDay(end time.Time) time.Time {
+	return end.AddDate(0, 0, -1)
+}
@@ -0,0 +1,60 @@
+package statements
+
+import (
+	"time"
+
+	"github.com/rovany706/loyalty-gopher/internal/models"
+)
+
+// MonthLayout is how statement periods are named in URLs.
+const MonthLayout = "2006-01"
+
+// MonthStart returns the first instant of the UTC month containing t.
+func MonthStart(t time.Time) time.Time {
+	t = t.UTC()
+
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Build assembles the statement of the month starting at periodStart, adding the
+// running balance to every entry of the ledger. Household withdrawals do not move it.
+func Build(user *models.User, periodStart time.Time, ledger models.Ledger, generatedAt time.Time) models.Statement {
+	statement := models.Statement{
+		Login:           user.Login,
+		CardID:          user.LoyaltyCardID,
+		PeriodStart:     periodStart,
+		PeriodEnd:       periodStart.AddDate(0, 1, 0),
+		GeneratedAt:     generatedAt,
+		OpeningBalance:  ledger.OpeningBalance,
+		LifetimeAccrued: ledger.LifetimeAccrued,
+		Entries:         make([]models.StatementEntry, 0, len(ledger.Entries)),
+	}
+
+	balance := ledger.OpeningBalance
+	for _, entry := range ledger.Entries {
+		if entry.Kind != models.StatementEntryHouseholdWithdrawal {
+			balance = balance.Add(entry.Amount)
+		}
+		entry.Balance = balance
+
+		switch entry.Kind {
+		case models.StatementEntryAccrual:
+			statement.TotalAccrued = statement.TotalAccrued.Add(entry.Amount)
+		case models.StatementEntryWithdrawal:
+			statement.TotalWithdrawn = statement.TotalWithdrawn.Sub(entry.Amount)
+		case models.StatementEntryAdjustment:
+			statement.TotalAdjusted = statement.TotalAdjusted.Add(entry.Amount)
+		case models.StatementEntryTransfer:
+			statement.TotalTransferred = statement.TotalTransferred.Add(entry.Amount)
+		case models.StatementEntryHouseholdWithdrawal:
+			statement.TotalHouseholdWithdrawn = statement.TotalHouseholdWithdrawn.Sub(entry.Amount)
+		}
+
+		statement.Entries = append(statement.Entries, entry)
+	}
+
+	statement.ClosingBalance = balance
+	statement.Tier, statement.NextTier = models.TierFor(ledger.LifetimeAccrued)
+
+	return statement
+}
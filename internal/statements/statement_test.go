@@ -0,0 +1,64 @@
+package statements
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/rovany706/loyalty-gopher/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testStatement() models.Statement {
+	periodStart := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	ledger := models.Ledger{
+		OpeningBalance:  decimal.NewFromInt(100),
+		LifetimeAccrued: decimal.NewFromInt(4800),
+		Entries: []models.StatementEntry{
+			{Date: periodStart.AddDate(0, 0, 2), Kind: models.StatementEntryAccrual, Reference: "12345678903", Amount: decimal.NewFromFloat(729.98)},
+			{Date: periodStart.AddDate(0, 0, 5), Kind: models.StatementEntryWithdrawal, Reference: "2377225624", Amount: decimal.NewFromInt(-500)},
+			{Date: periodStart.AddDate(0, 0, 9), Kind: models.StatementEntryAdjustment, Reference: "goodwill", Amount: decimal.NewFromInt(20)},
+			{Date: periodStart.AddDate(0, 0, 12), Kind: models.StatementEntryTransfer, Reference: "relative", Amount: decimal.RequireFromString("-49.98")},
+			{Date: periodStart.AddDate(0, 0, 14), Kind: models.StatementEntryHouseholdWithdrawal, Reference: "4561261212345467", Amount: decimal.NewFromInt(-80)},
+		},
+	}
+
+	return Build(&models.User{Login: "gopher", LoyaltyCardID: "GM-0001"}, periodStart, ledger, periodStart.AddDate(0, 1, 0))
+}
+
+func TestBuild(t *testing.T) {
+	statement := testStatement()
+
+	balances := make([]string, 0, len(statement.Entries))
+	for _, entry := range statement.Entries {
+		balances = append(balances, entry.Balance.StringFixed(2))
+	}
+
+	assert.Equal(t, []string{"829.98", "329.98", "349.98", "300.00", "300.00"}, balances)
+	assert.Equal(t, "300.00", statement.ClosingBalance.StringFixed(2))
+	assert.Equal(t, "729.98", statement.TotalAccrued.StringFixed(2))
+	assert.Equal(t, "500.00", statement.TotalWithdrawn.StringFixed(2))
+	assert.Equal(t, "20.00", statement.TotalAdjusted.StringFixed(2))
+	assert.Equal(t, "-49.98", statement.TotalTransferred.StringFixed(2))
+	assert.Equal(t, "80.00", statement.TotalHouseholdWithdrawn.StringFixed(2))
+	assert.Equal(t, "Bronze", statement.Tier.Name)
+	require.NotNil(t, statement.NextTier)
+	assert.Equal(t, "Silver", statement.NextTier.Name)
+}
+
+func TestRender(t *testing.T) {
+	document, err := Render(testStatement())
+	require.NoError(t, err)
+
+	html := string(document.HTML)
+	assert.Contains(t, html, "2024-03-01 to 2024-03-31")
+	assert.Contains(t, html, "2377225624")
+	assert.Contains(t, html, "349.98")
+	assert.Contains(t, html, "Transfers")
+	assert.Contains(t, html, "Paid from household")
+	assert.Contains(t, html, "200.00 more to reach Silver")
+
+	assert.True(t, bytes.HasPrefix(document.PDF, []byte("%PDF")))
+}
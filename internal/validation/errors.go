@@ -0,0 +1,53 @@
+package validation
+
+import (
+	"strings"
+)
+
+const (
+	CodeRequired       = "required"
+	CodeTooShort       = "too_short"
+	CodeTooLong        = "too_long"
+	CodeInvalidChars   = "invalid_characters"
+	CodeTooWeak        = "too_weak"
+	CodeCommonPassword = "common_password"
+	CodeMalformedBody  = "malformed_body"
+	CodeInvalidType    = "invalid_type"
+	CodeInvalidFormat  = "invalid_format"
+	CodeNotAllowed     = "not_allowed"
+	CodeOutOfRange     = "out_of_range"
+	CodeUnknownField   = "unknown_field"
+)
+
+const (
+	FieldLogin    = "login"
+	FieldPassword = "password"
+	FieldBody     = "body"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Errors collects every rule violation found in a request, so that
+// clients can show all of them at once.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fieldErr := range e {
+		messages = append(messages, fieldErr.Field+": "+fieldErr.Message)
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func (e *Errors) add(field string, code string, message string) {
+	*e = append(*e, FieldError{
+		Field:   field,
+		Code:    code,
+		Message: message,
+	})
+}
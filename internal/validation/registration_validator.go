@@ -0,0 +1,146 @@
+package validation
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+type RegistrationPolicy struct {
+	LoginMinLength    int
+	LoginMaxLength    int
+	LoginPattern      *regexp.Regexp
+	PasswordMinLength int
+	PasswordMaxLength int
+	// PasswordMaxBytes limits the UTF-8 encoded password, 0 means no limit.
+	PasswordMaxBytes      int
+	PasswordMinClasses    int
+	RejectCommonPasswords bool
+}
+
+type RegistrationValidator struct {
+	policy          RegistrationPolicy
+	commonPasswords map[string]struct{}
+}
+
+func NewRegistrationValidator(policy RegistrationPolicy) *RegistrationValidator {
+	return &RegistrationValidator{
+		policy:          policy,
+		commonPasswords: parseCommonPasswords(commonPasswordsFile),
+	}
+}
+
+// NormalizeLogin brings a login to Unicode NFKC form, so that visually
+// identical logins are stored and looked up the same way. Case is kept
+// as typed: case-insensitive uniqueness is enforced by the database.
+func NormalizeLogin(login string) string {
+	return norm.NFKC.String(strings.TrimSpace(login))
+}
+
+// Validate checks an already normalized login and a password against the policy.
+// It returns Errors listing every violation, or nil.
+func (v *RegistrationValidator) Validate(login string, password string) error {
+	var errs Errors
+
+	v.validateLogin(login, &errs)
+	v.validatePassword(login, password, &errs)
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (v *RegistrationValidator) validateLogin(login string, errs *Errors) {
+	length := utf8.RuneCountInString(login)
+
+	switch {
+	case length == 0:
+		errs.add(FieldLogin, CodeRequired, "login is required")
+	case length < v.policy.LoginMinLength:
+		errs.add(FieldLogin, CodeTooShort, fmt.Sprintf("login must be at least %d characters long", v.policy.LoginMinLength))
+	case length > v.policy.LoginMaxLength:
+		errs.add(FieldLogin, CodeTooLong, fmt.Sprintf("login must be at most %d characters long", v.policy.LoginMaxLength))
+	case v.policy.LoginPattern != nil && !v.policy.LoginPattern.MatchString(login):
+		errs.add(FieldLogin, CodeInvalidChars, "login contains characters that are not allowed")
+	}
+}
+
+func (v *RegistrationValidator) validatePassword(login string, password string, errs *Errors) {
+	length := utf8.RuneCountInString(password)
+
+	switch {
+	case length == 0:
+		errs.add(FieldPassword, CodeRequired, "password is required")
+	case length < v.policy.PasswordMinLength:
+		errs.add(FieldPassword, CodeTooShort, fmt.Sprintf("password must be at least %d characters long", v.policy.PasswordMinLength))
+	case length > v.policy.PasswordMaxLength:
+		errs.add(FieldPassword, CodeTooLong, fmt.Sprintf("password must be at most %d characters long", v.policy.PasswordMaxLength))
+	case v.policy.PasswordMaxBytes > 0 && len(password) > v.policy.PasswordMaxBytes:
+		errs.add(FieldPassword, CodeTooLong, fmt.Sprintf("password must be at most %d bytes long", v.policy.PasswordMaxBytes))
+	case countCharacterClasses(password) < v.policy.PasswordMinClasses:
+		errs.add(FieldPassword, CodeTooWeak, fmt.Sprintf("password must contain at least %d of: lowercase letters, uppercase letters, digits, symbols", v.policy.PasswordMinClasses))
+	case v.policy.RejectCommonPasswords && v.isCommonPassword(login, password):
+		errs.add(FieldPassword, CodeCommonPassword, "password is too common")
+	}
+}
+
+func (v *RegistrationValidator) isCommonPassword(login string, password string) bool {
+	lowered := strings.ToLower(password)
+	if lowered == strings.ToLower(login) {
+		return true
+	}
+
+	_, ok := v.commonPasswords[lowered]
+	return ok
+}
+
+func countCharacterClasses(password string) int {
+	var hasLower, hasUpper, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		default:
+			hasSymbol = true
+		}
+	}
+
+	count := 0
+	for _, has := range []bool{hasLower, hasUpper, hasDigit, hasSymbol} {
+		if has {
+			count++
+		}
+	}
+
+	return count
+}
+
+func parseCommonPasswords(file string) map[string]struct{} {
+	passwords := make(map[string]struct{})
+	scanner := bufio.NewScanner(strings.NewReader(file))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		passwords[strings.ToLower(line)] = struct{}{}
+	}
+
+	return passwords
+}
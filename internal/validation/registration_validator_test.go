@@ -0,0 +1,159 @@
+package validation
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRegistrationValidator() *RegistrationValidator {
+	return NewRegistrationValidator(RegistrationPolicy{
+		LoginMinLength:        3,
+		LoginMaxLength:        16,
+		LoginPattern:          regexp.MustCompile(`^[\p{L}\p{N}._@+-]+$`),
+		PasswordMinLength:     8,
+		PasswordMaxLength:     72,
+		PasswordMaxBytes:      72,
+		PasswordMinClasses:    2,
+		RejectCommonPasswords: true,
+	})
+}
+
+func TestRegistrationValidatorValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		login    string
+		password string
+		wantErrs Errors
+	}{
+		{
+			name:     "valid",
+			login:    "gopher2006",
+			password: "Dont-Panic-42",
+			wantErrs: nil,
+		},
+		{
+			name:     "valid unicode login",
+			login:    "суслик",
+			password: "Dont-Panic-42",
+			wantErrs: nil,
+		},
+		{
+			name:     "empty fields",
+			login:    "",
+			password: "",
+			wantErrs: Errors{
+				{Field: FieldLogin, Code: CodeRequired},
+				{Field: FieldPassword, Code: CodeRequired},
+			},
+		},
+		{
+			name:     "short login",
+			login:    "go",
+			password: "Dont-Panic-42",
+			wantErrs: Errors{{Field: FieldLogin, Code: CodeTooShort}},
+		},
+		{
+			name:     "long login",
+			login:    "gophergophergopher",
+			password: "Dont-Panic-42",
+			wantErrs: Errors{{Field: FieldLogin, Code: CodeTooLong}},
+		},
+		{
+			name:     "login with spaces",
+			login:    "go pher",
+			password: "Dont-Panic-42",
+			wantErrs: Errors{{Field: FieldLogin, Code: CodeInvalidChars}},
+		},
+		{
+			name:     "short password",
+			login:    "gopher2006",
+			password: "Pa55",
+			wantErrs: Errors{{Field: FieldPassword, Code: CodeTooShort}},
+		},
+		{
+			name:     "password over byte limit",
+			login:    "gopher2006",
+			password: strings.Repeat("пароль", 6) + "-Dont-Panic-42",
+			wantErrs: Errors{{Field: FieldPassword, Code: CodeTooLong}},
+		},
+		{
+			name:     "single character class",
+			login:    "gopher2006",
+			password: "dontpanicplease",
+			wantErrs: Errors{{Field: FieldPassword, Code: CodeTooWeak}},
+		},
+		{
+			name:     "common password",
+			login:    "gopher2006",
+			password: "Password123",
+			wantErrs: Errors{{Field: FieldPassword, Code: CodeCommonPassword}},
+		},
+		{
+			name:     "password equals login",
+			login:    "Gopher2006",
+			password: "gopher2006",
+			wantErrs: Errors{{Field: FieldPassword, Code: CodeCommonPassword}},
+		},
+	}
+
+	validator := newTestRegistrationValidator()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.Validate(tt.login, tt.password)
+
+			if tt.wantErrs == nil {
+				require.NoError(t, err)
+				return
+			}
+
+			var errs Errors
+			require.ErrorAs(t, err, &errs)
+			require.Len(t, errs, len(tt.wantErrs))
+			for i, wantErr := range tt.wantErrs {
+				assert.Equal(t, wantErr.Field, errs[i].Field)
+				assert.Equal(t, wantErr.Code, errs[i].Code)
+				assert.NotEmpty(t, errs[i].Message)
+			}
+		})
+	}
+}
+
+func TestNormalizeLogin(t *testing.T) {
+	tests := []struct {
+		name  string
+		login string
+		want  string
+	}{
+		{
+			name:  "already normalized",
+			login: "gopher",
+			want:  "gopher",
+		},
+		{
+			name:  "surrounding spaces",
+			login: "  gopher ",
+			want:  "gopher",
+		},
+		{
+			name:  "fullwidth characters",
+			login: "ｇｏｐｈｅｒ",
+			want:  "gopher",
+		},
+		{
+			name:  "combining characters",
+			login: "é",
+			want:  "é",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, NormalizeLogin(tt.login))
+		})
+	}
+}